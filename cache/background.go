@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/AccursedGalaxy/streakode/config"
+)
+
+// Files of the background refresh, next to the cache. The refresh lock
+// holds the PID of whichever process is refreshing.
+const (
+	statusSuffix = ".status" // BackgroundStatus of the last background refresh, as JSON
+	logSuffix    = ".log"    // output of background refreshes
+)
+
+// maxLogSize is the size above which the log is started over
+const maxLogSize = 1 << 20
+
+// BackgroundStatus describes the last background refresh
+type BackgroundStatus struct {
+	PID      int       `json:"pid"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"` // zero while running
+	Error    string    `json:"error,omitempty"`
+
+	// Running is set while any process, in the background or not, is
+	// refreshing the cache
+	Running bool `json:"-"`
+}
+
+// LogPath returns the log of background refreshes of the cache at cachePath
+func LogPath(cacheFilePath string) string {
+	return cacheFilePath + logSuffix
+}
+
+// SpawnRefresh starts `streakode cache refresh --background` as a process
+// detached from this one, so the refresh outlives the command that needed
+// it. Nothing is started while a refresh is already running.
+func SpawnRefresh(cacheFilePath string) error {
+	if refreshRunning(cacheFilePath) {
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the streakode executable: %v", err)
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if info, err := os.Stat(LogPath(cacheFilePath)); err == nil && info.Size() > maxLogSize {
+		flags |= os.O_TRUNC
+	}
+	logFile, err := os.OpenFile(LogPath(cacheFilePath), flags, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh log: %v", err)
+	}
+	defer logFile.Close()
+
+	args := []string{"cache", "refresh", "--background"}
+	if config.AppConfig.Debug {
+		args = append(args, "--debug")
+	}
+	child := exec.Command(exe, args...)
+	child.Stdout = logFile
+	child.Stderr = logFile
+	detach(child)
+	if err := child.Start(); err != nil {
+		return fmt.Errorf("failed to start background refresh: %v", err)
+	}
+	return child.Process.Release()
+}
+
+// RunBackgroundRefresh refreshes the cache in a process started by
+// SpawnRefresh, recording its progress in the status file
+func RunBackgroundRefresh(ctx context.Context, cacheFilePath string) error {
+	status := BackgroundStatus{PID: os.Getpid(), Started: time.Now()}
+	fmt.Printf("Refresh started %s (pid %d)\n", status.Started.Format(time.RFC3339), status.PID)
+
+	// The status names this process as running once it holds the refresh
+	// lock, so readers never see it running without the lock
+	err := refreshCache(
+		ctx,
+		config.AppConfig.ScanDirectories,
+		config.AppConfig.Author,
+		cacheFilePath,
+		config.AppConfig.ScanSettings.ExcludedPatterns,
+		config.AppConfig.ScanSettings.ExcludedPaths,
+		nil,
+		func() {
+			if err := writeBackgroundStatus(cacheFilePath, status); err != nil {
+				fmt.Printf("Error writing refresh status: %v\n", err)
+			}
+		},
+	)
+	status.Finished = time.Now()
+	if err != nil {
+		status.Error = err.Error()
+		fmt.Printf("Refresh failed after %s: %v\n", status.Finished.Sub(status.Started).Round(time.Millisecond), err)
+	} else {
+		fmt.Printf("Refresh finished after %s\n", status.Finished.Sub(status.Started).Round(time.Millisecond))
+	}
+
+	if writeErr := writeBackgroundStatus(cacheFilePath, status); writeErr != nil && err == nil {
+		return writeErr
+	}
+	return err
+}
+
+// RefreshRunning reports whether any process, in the background or not, is
+// refreshing the cache at cachePath
+func RefreshRunning(cacheFilePath string) bool {
+	return refreshRunning(cacheFilePath)
+}
+
+// GetBackgroundStatus returns the status of the last background refresh of
+// the cache at cachePath, or nil when there was none
+func GetBackgroundStatus(cacheFilePath string) (*BackgroundStatus, error) {
+	data, err := os.ReadFile(cacheFilePath + statusSuffix)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read refresh status: %v", err)
+	}
+
+	var status BackgroundStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("failed to decode refresh status: %v", err)
+	}
+	status.Running = refreshRunning(cacheFilePath)
+	// The process was killed before it could record the outcome
+	if !status.Running && status.Finished.IsZero() && status.Error == "" {
+		status.Error = "stopped before finishing"
+	}
+	return &status, nil
+}
+
+// writeBackgroundStatus replaces the status file
+func writeBackgroundStatus(cacheFilePath string, status BackgroundStatus) error {
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode refresh status: %v", err)
+	}
+
+	path := cacheFilePath + statusSuffix
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write refresh status: %v", err)
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to write refresh status: %v", err)
+	}
+	return nil
+}
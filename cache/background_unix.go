@@ -0,0 +1,14 @@
+//go:build !windows
+
+package cache
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach starts the child in a session of its own, so it neither receives
+// the terminal's signals nor exits with it
+func detach(child *exec.Cmd) {
+	child.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package cache
+
+import (
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// detach starts the child without a console of its own or the parent's, so
+// it outlives the parent's console window
+func detach(child *exec.Cmd) {
+	child.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: windows.DETACHED_PROCESS | windows.CREATE_NEW_PROCESS_GROUP,
+		HideWindow:    true,
+	}
+}
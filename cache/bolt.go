@@ -0,0 +1,413 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/AccursedGalaxy/streakode/scan"
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltSuffix is appended to the cache path to name the bolt database
+const boltSuffix = ".db"
+
+var (
+	bucketMeta        = []byte("meta")         // format version and summary
+	bucketRepos       = []byte("repos")        // path -> repository without its commits
+	bucketCommits     = []byte("commits")      // path -> commits of the repository
+	bucketCommitIndex = []byte("commit_index") // hash -> repositories holding the commit
+	bucketDateIndex   = []byte("date_index")   // YYYY-MM-DD -> commit hashes
+	bucketAuthorIndex = []byte("author_index") // canonical identity -> commit hashes
+	bucketBlobs       = []byte("blobs")        // blob hash -> line counts
+
+	keyVersion = []byte("version")
+	keySummary = []byte("summary")
+)
+
+// boltStorage keeps the cache in a bolt database. The summary record holds
+// what the stats views show: display and author statistics, repository
+// states and the last scan report. Load reads only the summary; every
+// repository, commit list, index entry and blob is a record of its own, read
+// by LoadDetails and written only when it changed. Records are JSON.
+type boltStorage struct {
+	path       string
+	legacyPath string // file cache imported while the database does not exist
+	rewrite    bool   // write every record on the next save
+}
+
+func (s *boltStorage) open(readOnly bool) (*bolt.DB, error) {
+	db, err := bolt.Open(s.path, 0644, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: readOnly})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache database: %v", err)
+	}
+	return db, nil
+}
+
+func (s *boltStorage) Load() (*CommitCache, error) {
+	if _, err := os.Stat(s.path); os.IsNotExist(err) {
+		// Start from the file cache when switching backends
+		cache, err := (&gobStorage{path: s.legacyPath}).Load()
+		if err != nil {
+			return nil, err
+		}
+		s.rewrite = true
+		return cache, nil
+	}
+
+	db, err := s.open(true)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	cache := &CommitCache{
+		Authors:    make(map[string]AuthorStats),
+		RepoStates: make(map[string]RepoState),
+	}
+	version := cacheFormatVersion
+	err = db.View(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(bucketMeta)
+		if meta == nil {
+			cache = newCommitCache()
+			return nil
+		}
+		version, err = strconv.Atoi(string(meta.Get(keyVersion)))
+		if err != nil || version < 1 {
+			return fmt.Errorf("invalid cache database version %q", meta.Get(keyVersion))
+		}
+		if version > cacheFormatVersion {
+			return newerCacheError(version)
+		}
+		if err := decodeRecord(meta.Get(keySummary), cache); err != nil {
+			return fmt.Errorf("failed to decode cache summary: %v", err)
+		}
+		if version < cacheFormatVersion {
+			// Migrations work on the whole cache
+			return readDetails(tx, cache)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if version < cacheFormatVersion {
+		if err := migrateCache(cache, version); err != nil {
+			return nil, err
+		}
+		s.rewrite = true
+	}
+	return cache, nil
+}
+
+func (s *boltStorage) LoadDetails(cache *CommitCache) error {
+	if cache.Repositories != nil {
+		return nil
+	}
+	if _, err := os.Stat(s.path); os.IsNotExist(err) {
+		return readDetails(nil, cache)
+	}
+
+	db, err := s.open(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		return readDetails(tx, cache)
+	})
+}
+
+// readDetails reads the records of repositories, commits, indexes and blobs
+// into cache. A nil tx leaves them empty.
+func readDetails(tx *bolt.Tx, cache *CommitCache) error {
+	resetDetails(cache)
+	if tx == nil {
+		return nil
+	}
+
+	err := forEachRecord(tx, bucketRepos, func(key string, data []byte) error {
+		var repo scan.RepoMetadata
+		if err := decodeRecord(data, &repo); err != nil {
+			return fmt.Errorf("failed to decode repository %s: %v", key, err)
+		}
+		cache.Repositories[key] = repo
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = forEachRecord(tx, bucketCommits, func(key string, data []byte) error {
+		repo, ok := cache.Repositories[key]
+		if !ok {
+			return nil
+		}
+		var commits []scan.CommitHistory
+		if err := decodeRecord(data, &commits); err != nil {
+			return fmt.Errorf("failed to decode commits of %s: %v", key, err)
+		}
+		cache.Commits[key] = commits
+		repo.CommitHistory = append([]scan.CommitHistory(nil), commits...)
+		cache.Repositories[key] = repo
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	indexes := []struct {
+		bucket []byte
+		read   func(key string, data []byte) error
+	}{
+		{bucketCommitIndex, func(key string, data []byte) error {
+			var repos map[string]bool
+			err := decodeRecord(data, &repos)
+			cache.CommitIndex[key] = repos
+			return err
+		}},
+		{bucketDateIndex, func(key string, data []byte) error {
+			var hashes []string
+			err := decodeRecord(data, &hashes)
+			cache.DateIndex[key] = hashes
+			return err
+		}},
+		{bucketAuthorIndex, func(key string, data []byte) error {
+			var hashes []string
+			err := decodeRecord(data, &hashes)
+			cache.AuthorIndex[key] = hashes
+			return err
+		}},
+		{bucketBlobs, func(key string, data []byte) error {
+			var record scan.BlobRecord
+			err := decodeRecord(data, &record)
+			cache.BlobStats[key] = record
+			return err
+		}},
+	}
+	for _, index := range indexes {
+		if err := forEachRecord(tx, index.bucket, index.read); err != nil {
+			return fmt.Errorf("failed to decode %s: %v", index.bucket, err)
+		}
+	}
+	return nil
+}
+
+func (s *boltStorage) Save(cache *CommitCache, changed map[string]bool) error {
+	cache.Version = strconv.Itoa(cacheFormatVersion)
+
+	db, err := s.open(false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if err := writeSummary(tx, cache); err != nil {
+			return err
+		}
+		// Without its details loaded, only the summary can have changed
+		if cache.Repositories == nil {
+			return nil
+		}
+
+		written, err := s.writeRepos(tx, cache, changed)
+		if err != nil {
+			return err
+		}
+		if written || s.rewrite {
+			if err := writeIndexes(tx, cache); err != nil {
+				return err
+			}
+		}
+		return writeBlobs(tx, cache.BlobStats)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save cache database: %v", err)
+	}
+
+	s.rewrite = false
+	return nil
+}
+
+// writeSummary writes the version and the cache without its details
+func writeSummary(tx *bolt.Tx, cache *CommitCache) error {
+	meta, err := tx.CreateBucketIfNotExists(bucketMeta)
+	if err != nil {
+		return err
+	}
+
+	summary := *cache
+	summary.Repositories = nil
+	summary.Commits = nil
+	summary.CommitIndex = nil
+	summary.DateIndex = nil
+	summary.AuthorIndex = nil
+	summary.BlobStats = nil
+	if err := putRecord(meta, keySummary, summary); err != nil {
+		return err
+	}
+	return meta.Put(keyVersion, []byte(cache.Version))
+}
+
+// writeRepos writes the repositories in changed, or all of them when
+// rewriting, and deletes those no longer cached. It reports whether any
+// record was written or deleted.
+func (s *boltStorage) writeRepos(tx *bolt.Tx, cache *CommitCache, changed map[string]bool) (bool, error) {
+	repos, err := tx.CreateBucketIfNotExists(bucketRepos)
+	if err != nil {
+		return false, err
+	}
+	commits, err := tx.CreateBucketIfNotExists(bucketCommits)
+	if err != nil {
+		return false, err
+	}
+
+	written := false
+	var gone []string
+	err = repos.ForEach(func(key, _ []byte) error {
+		if _, ok := cache.Repositories[string(key)]; !ok {
+			gone = append(gone, string(key))
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, repoPath := range gone {
+		if err := repos.Delete([]byte(repoPath)); err != nil {
+			return false, err
+		}
+		if err := commits.Delete([]byte(repoPath)); err != nil {
+			return false, err
+		}
+		written = true
+	}
+
+	for repoPath, repo := range cache.Repositories {
+		key := []byte(repoPath)
+		if !s.rewrite && !changed[repoPath] && repos.Get(key) != nil {
+			continue
+		}
+		if err := putRecord(commits, key, repo.CommitHistory); err != nil {
+			return false, err
+		}
+		repo.CommitHistory = nil
+		if err := putRecord(repos, key, repo); err != nil {
+			return false, err
+		}
+		written = true
+	}
+	return written, nil
+}
+
+// writeIndexes replaces the index records
+func writeIndexes(tx *bolt.Tx, cache *CommitCache) error {
+	commitIndex, err := replaceBucket(tx, bucketCommitIndex)
+	if err != nil {
+		return err
+	}
+	for hash, repos := range cache.CommitIndex {
+		if err := putRecord(commitIndex, []byte(hash), repos); err != nil {
+			return err
+		}
+	}
+
+	dateIndex, err := replaceBucket(tx, bucketDateIndex)
+	if err != nil {
+		return err
+	}
+	for day, hashes := range cache.DateIndex {
+		if err := putRecord(dateIndex, []byte(day), hashes); err != nil {
+			return err
+		}
+	}
+
+	authorIndex, err := replaceBucket(tx, bucketAuthorIndex)
+	if err != nil {
+		return err
+	}
+	for author, hashes := range cache.AuthorIndex {
+		if err := putRecord(authorIndex, []byte(author), hashes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceBucket returns an empty bucket in place of the named one
+func replaceBucket(tx *bolt.Tx, name []byte) (*bolt.Bucket, error) {
+	if err := tx.DeleteBucket(name); err != nil && err != bolt.ErrBucketNotFound {
+		return nil, err
+	}
+	return tx.CreateBucket(name)
+}
+
+// writeBlobs writes the blob records that changed and deletes expired ones
+func writeBlobs(tx *bolt.Tx, blobs map[string]scan.BlobRecord) error {
+	bucket, err := tx.CreateBucketIfNotExists(bucketBlobs)
+	if err != nil {
+		return err
+	}
+
+	var expired []string
+	err = bucket.ForEach(func(key, _ []byte) error {
+		if _, ok := blobs[string(key)]; !ok {
+			expired = append(expired, string(key))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, hash := range expired {
+		if err := bucket.Delete([]byte(hash)); err != nil {
+			return err
+		}
+	}
+
+	for hash, record := range blobs {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(bucket.Get([]byte(hash)), data) {
+			continue
+		}
+		if err := bucket.Put([]byte(hash), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forEachRecord calls read with every record of a bucket, if it exists
+func forEachRecord(tx *bolt.Tx, name []byte, read func(key string, data []byte) error) error {
+	bucket := tx.Bucket(name)
+	if bucket == nil {
+		return nil
+	}
+	return bucket.ForEach(func(key, data []byte) error {
+		return read(string(key), data)
+	})
+}
+
+func putRecord(bucket *bolt.Bucket, key []byte, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", key, err)
+	}
+	return bucket.Put(key, data)
+}
+
+func decodeRecord(data []byte, value interface{}) error {
+	if data == nil {
+		return fmt.Errorf("record is missing")
+	}
+	return json.Unmarshal(data, value)
+}
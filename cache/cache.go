@@ -1,11 +1,11 @@
 package cache
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	"strings"
 	"sync"
 	"time"
 
@@ -58,8 +58,21 @@ func SaveCache(filePath string) error {
 	return manager.Save()
 }
 
-// RefreshCache - updates the cache with fresh data
-func RefreshCache(dirs []string, author string, cacheFilePath string, excludedPatterns []string, excludedPaths []string) error {
+// RefreshCache - updates the cache with fresh data. Cancelling ctx stops the
+// scan early; repositories read by then are saved, the rest keep their
+// cached data.
+func RefreshCache(ctx context.Context, dirs []string, author string, cacheFilePath string, excludedPatterns []string, excludedPaths []string) error {
+	return RefreshCacheWithProgress(ctx, dirs, author, cacheFilePath, excludedPatterns, excludedPaths, nil)
+}
+
+// RefreshCacheWithProgress - like RefreshCache, reporting scan progress to the callback
+func RefreshCacheWithProgress(ctx context.Context, dirs []string, author string, cacheFilePath string, excludedPatterns []string, excludedPaths []string, progress scan.ProgressFunc) error {
+	return refreshCache(ctx, dirs, author, cacheFilePath, excludedPatterns, excludedPaths, progress, nil)
+}
+
+// refreshCache refreshes the cache, calling locked, if set, once this
+// process holds the refresh lock
+func refreshCache(ctx context.Context, dirs []string, author string, cacheFilePath string, excludedPatterns []string, excludedPaths []string, progress scan.ProgressFunc, locked func()) error {
 	mutex.Lock()
 	defer mutex.Unlock()
 
@@ -67,27 +80,40 @@ func RefreshCache(dirs []string, author string, cacheFilePath string, excludedPa
 		manager = NewCacheManager(cacheFilePath)
 	}
 
-	// Create exclusion function
-	shouldExclude := func(path string) bool {
-		// Check full path exclusions
-		for _, excludedPath := range excludedPaths {
-			if strings.HasPrefix(path, excludedPath) {
-				return true
-			}
+	// One process refreshes at a time. The others wait and reuse its result,
+	// unless it failed to save one.
+	lastSync := manager.cache.LastSync
+	lock, waited, err := lockRefresh(ctx, manager.path)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	if locked != nil {
+		locked()
+	}
+	if waited {
+		if err := manager.Load(); err != nil {
+			return err
 		}
-
-		// Check pattern-based exclusions
-		for _, pattern := range excludedPatterns {
-			if strings.Contains(path, pattern) {
-				return true
-			}
+		if manager.cache.LastSync.After(lastSync) {
+			return nil
 		}
-		return false
 	}
 
-	// Scan directories for repositories
-	repos, err := scan.ScanDirectories(dirs, author, shouldExclude)
-	if err != nil {
+	// Exclusions follow .gitignore rules, extended by .streakodeignore files
+	exclusions := scan.NewExclusions(dirs, excludedPatterns, excludedPaths)
+
+	// Scan directories for repositories, reusing what the cache already knows
+	manager.loadDetails()
+	memo := scan.NewBlobMemo(manager.cache.BlobStats)
+	repos, report, err := scan.ScanDirectories(scan.WithBlobMemo(ctx, memo), dirs, author, exclusions, manager.cache.Repositories, progress)
+	manager.cache.LastScan = report
+	manager.cache.BlobStats = memo.Records(blobMemoMaxAge)
+	if err != nil && ctx.Err() == nil {
+		// Keep the report so that 'cache report' can show what went wrong
+		if saveErr := manager.Save(); saveErr != nil && config.AppConfig.Debug {
+			fmt.Printf("Debug: Saving scan report failed: %v\n", saveErr)
+		}
 		return fmt.Errorf("error scanning directories: %v", err)
 	}
 
@@ -95,21 +121,53 @@ func RefreshCache(dirs []string, author string, cacheFilePath string, excludedPa
 	reposMap := make(map[string]scan.RepoMetadata)
 	for _, repo := range repos {
 		reposMap[repo.Path] = repo
+		manager.markChanged(repo.Path)
+	}
+
+	// An interrupted scan keeps the cached data of repositories it did not get to
+	for _, outcome := range report.Repos {
+		if outcome.Status != scan.StatusInterrupted {
+			continue
+		}
+		if repo, ok := manager.cache.Repositories[outcome.Path]; ok {
+			reposMap[outcome.Path] = repo
+		}
 	}
 
 	// Update cache with new data using the manager's method
 	manager.updateCacheData(reposMap)
+	manager.moveRepoStates(report)
+	manager.recordRepoStates(reposMap)
 
-	return manager.Save()
+	if saveErr := manager.Save(); saveErr != nil {
+		return saveErr
+	}
+	return err
 }
 
-// AsyncRefreshCache performs a non-blocking cache refresh
-func AsyncRefreshCache(dirs []string, author string, cacheFilePath string, excludedPatterns []string, excludedPaths []string) {
-	go func() {
-		if err := RefreshCache(dirs, author, cacheFilePath, excludedPatterns, excludedPaths); err != nil {
-			log.Printf("Background cache refresh failed: %v", err)
-		}
-	}()
+// RefreshRepos refreshes the given cached repositories incrementally and
+// saves the cache, leaving the other repositories as they are on disk
+func RefreshRepos(ctx context.Context, paths []string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if manager == nil {
+		return fmt.Errorf("cache manager not initialized")
+	}
+
+	return manager.RefreshRepos(ctx, paths)
+}
+
+// Notifications returns the updates of repositories refreshed incrementally
+func Notifications() <-chan CacheUpdate {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if manager == nil {
+		return nil
+	}
+
+	return manager.notifications
 }
 
 // QuickNeedsRefresh performs a fast check if refresh is needed
@@ -131,6 +189,8 @@ func CleanCache(cacheFilePath string) error {
 
 	if manager != nil {
 		manager.cache = newCommitCache()
+		manager.loadErr = nil
+		manager.changed = make(map[string]bool)
 	}
 
 	// Remove cache file if present
@@ -140,6 +200,13 @@ func CleanCache(cacheFilePath string) error {
 		}
 	}
 
+	// Remove the bolt database if present
+	if err := os.Remove(cacheFilePath + boltSuffix); err != nil {
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("error removing cache database: %v", err)
+		}
+	}
+
 	// Remove metadata file if present
 	metaFile := cacheFilePath + ".meta"
 	if err := os.Remove(metaFile); err != nil {
@@ -176,6 +243,7 @@ func (cp *cacheProxy) Get(key string) (scan.RepoMetadata, bool) {
 	if manager == nil || manager.cache == nil {
 		return scan.RepoMetadata{}, false
 	}
+	manager.loadDetails()
 
 	repo, exists := manager.cache.Repositories[key]
 	return repo, exists
@@ -192,6 +260,32 @@ func (cp *cacheProxy) GetDisplayStats() *DisplayStats {
 	return &manager.cache.DisplayStats
 }
 
+// GetLastSync returns when the cache was last refreshed, zero if never
+func (cp *cacheProxy) GetLastSync() time.Time {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if manager == nil || manager.cache == nil {
+		return time.Time{}
+	}
+
+	return manager.cache.LastSync
+}
+
+// GetScanReport returns the report of the last full scan, or nil when the
+// cache has none
+func (cp *cacheProxy) GetScanReport() *scan.ScanReport {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if manager == nil || manager.cache == nil || manager.cache.LastScan.Started.IsZero() {
+		return nil
+	}
+
+	report := manager.cache.LastScan
+	return &report
+}
+
 func (cp *cacheProxy) Set(key string, value scan.RepoMetadata) {
 	mutex.Lock()
 	defer mutex.Unlock()
@@ -199,8 +293,10 @@ func (cp *cacheProxy) Set(key string, value scan.RepoMetadata) {
 	if manager == nil || manager.cache == nil {
 		return
 	}
+	manager.loadDetails()
 
 	manager.cache.Repositories[key] = value
+	manager.markChanged(key)
 }
 
 func (cp *cacheProxy) Delete(key string) {
@@ -210,6 +306,7 @@ func (cp *cacheProxy) Delete(key string) {
 	if manager == nil || manager.cache == nil {
 		return
 	}
+	manager.loadDetails()
 
 	delete(manager.cache.Repositories, key)
 }
@@ -221,6 +318,7 @@ func (cp *cacheProxy) Range(f func(key string, value scan.RepoMetadata) bool) {
 	if manager == nil || manager.cache == nil {
 		return
 	}
+	manager.loadDetails()
 
 	for k, v := range manager.cache.Repositories {
 		if !f(k, v) {
@@ -236,6 +334,7 @@ func (cp *cacheProxy) Len() int {
 	if manager == nil || manager.cache == nil {
 		return 0
 	}
+	manager.loadDetails()
 
 	return len(manager.cache.Repositories)
 }
@@ -0,0 +1,458 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AccursedGalaxy/streakode/config"
+	"github.com/AccursedGalaxy/streakode/scan"
+)
+
+const fixtureRepo = "/home/dev/projects/tool"
+
+// copyFixture copies a cache from testdata to a temporary file
+func copyFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "streakode.cache")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	return path
+}
+
+// The fixtures hold one repository with three commits. They were written
+// by the streakode of their format version; the display stats were saved
+// stale on purpose.
+func TestLoadCacheFormats(t *testing.T) {
+	tests := []struct {
+		fixture      string
+		recalculated bool
+	}{
+		{"format0-original.cache", true}, // before any scan improvements
+		{"format0.cache", true},          // last unversioned format
+		{"format1.cache", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			cm := NewCacheManager(copyFixture(t, tt.fixture))
+			if err := cm.Load(); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			c := cm.cache
+
+			repo, ok := c.Repositories[fixtureRepo]
+			if !ok || len(repo.CommitHistory) != 3 {
+				t.Fatalf("repository not preserved: %+v", c.Repositories)
+			}
+			if len(c.Commits[fixtureRepo]) != 3 {
+				t.Errorf("Commits = %d, want 3", len(c.Commits[fixtureRepo]))
+			}
+			if c.RepoStates == nil || c.CommitIndex == nil {
+				t.Errorf("maps not initialized")
+			}
+			if want := time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC); !c.LastSync.Equal(want) {
+				t.Errorf("LastSync = %v, want %v", c.LastSync, want)
+			}
+
+			if tt.recalculated {
+				if c.DisplayStats.WeeklyTotal != 0 || c.DisplayStats.TotalAdditions != 168 {
+					t.Errorf("display stats not recalculated: weekly %d, additions %d",
+						c.DisplayStats.WeeklyTotal, c.DisplayStats.TotalAdditions)
+				}
+				if len(c.CommitIndex) != 3 {
+					t.Errorf("CommitIndex = %d entries, want 3", len(c.CommitIndex))
+				}
+			} else if c.DisplayStats.WeeklyTotal != 99 {
+				t.Errorf("display stats were recalculated: weekly %d", c.DisplayStats.WeeklyTotal)
+			}
+
+			// Saving writes the current format
+			if err := cm.Save(); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			data, err := os.ReadFile(cm.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := cacheMagic + " v" + strconv.Itoa(cacheFormatVersion) + "\n"
+			if !bytes.HasPrefix(data, []byte(header)) {
+				t.Errorf("saved cache starts with %q, want %q", data[:len(header)], header)
+			}
+			if cm.cache.Version != strconv.Itoa(cacheFormatVersion) {
+				t.Errorf("Version = %q", cm.cache.Version)
+			}
+		})
+	}
+}
+
+func TestMigrateLineCounts(t *testing.T) {
+	cache := newCommitCache()
+	cache.BlobStats = map[string]scan.BlobRecord{"blob": {Stats: scan.BlobStats{Lines: 7}}}
+	cache.Repositories[fixtureRepo] = scan.RepoMetadata{Path: fixtureRepo, TreeHash: "tree", CodeLines: 6}
+
+	var data bytes.Buffer
+	data.WriteString(cacheMagic + " v1\n")
+	if err := gob.NewEncoder(&data).Encode(cache); err != nil {
+		t.Fatal(err)
+	}
+	migrated, err := readCache(&data)
+	if err != nil {
+		t.Fatalf("readCache() error = %v", err)
+	}
+	if len(migrated.BlobStats) != 0 {
+		t.Errorf("BlobStats = %+v, want none", migrated.BlobStats)
+	}
+	if repo := migrated.Repositories[fixtureRepo]; repo.TreeHash != "" || repo.CodeLines != 6 {
+		t.Errorf("repository = %+v, want its tree to be counted again", repo)
+	}
+}
+
+func TestLoadNewerCache(t *testing.T) {
+	path := copyFixture(t, "format99.cache")
+	before, _ := os.ReadFile(path)
+
+	cm := NewCacheManager(path)
+	err := cm.Load()
+	if !errors.Is(err, ErrNewerCache) {
+		t.Fatalf("Load() error = %v, want ErrNewerCache", err)
+	}
+	if !strings.Contains(err.Error(), "format 99") {
+		t.Errorf("error does not name the format: %v", err)
+	}
+
+	if err := cm.Save(); !errors.Is(err, ErrNewerCache) {
+		t.Errorf("Save() error = %v, want ErrNewerCache", err)
+	}
+	after, _ := os.ReadFile(path)
+	if !bytes.Equal(before, after) {
+		t.Errorf("newer cache was overwritten")
+	}
+}
+
+func TestReadCacheErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"bad header", "streakode-cache vX\n"},
+		{"truncated header", "streakode-cache v1"},
+		{"garbage", "not a cache"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readCache(strings.NewReader(tt.data)); err == nil {
+				t.Errorf("readCache(%q) succeeded", tt.data)
+			}
+		})
+	}
+
+	// An empty file is an empty cache
+	c, err := readCache(strings.NewReader(""))
+	if err != nil || c == nil || len(c.Repositories) != 0 {
+		t.Errorf("readCache(\"\") = %v, %v", c, err)
+	}
+}
+
+func testRepo(repoPath string, commits int) scan.RepoMetadata {
+	repo := scan.RepoMetadata{
+		Path:           repoPath,
+		AuthorVerified: true,
+		Contributors:   map[string]int{"Dev": commits},
+	}
+	for i := 0; i < commits; i++ {
+		repo.CommitHistory = append(repo.CommitHistory, scan.CommitHistory{
+			Date:      time.Date(2024, 3, 4+i, 10, 0, 0, 0, time.UTC),
+			Hash:      fmt.Sprintf("%s-%d", filepath.Base(repoPath), i),
+			Author:    "Dev <dev@example.com>",
+			Additions: 10,
+		})
+	}
+	return repo
+}
+
+func TestBoltStorage(t *testing.T) {
+	config.AppConfig.CacheSettings.Backend = BackendBolt
+	defer func() { config.AppConfig.CacheSettings.Backend = "" }()
+
+	path := filepath.Join(t.TempDir(), "streakode.cache")
+	load := func() *CacheManager {
+		t.Helper()
+		cm := NewCacheManager(path)
+		if err := cm.Load(); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		return cm
+	}
+	save := func(cm *CacheManager) {
+		t.Helper()
+		if err := cm.Save(); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	cm := load()
+	cm.updateCacheData(map[string]scan.RepoMetadata{
+		"/src/a": testRepo("/src/a", 2),
+		"/src/b": testRepo("/src/b", 3),
+	})
+	cm.cache.BlobStats = map[string]scan.BlobRecord{"blob": {Stats: scan.BlobStats{Lines: 7}}}
+	save(cm)
+	if _, err := os.Stat(path + boltSuffix); err != nil {
+		t.Fatalf("database not written: %v", err)
+	}
+
+	// Load reads only the summary
+	cm = load()
+	if cm.cache.Repositories != nil {
+		t.Errorf("Load() read the repositories")
+	}
+	if cm.cache.DisplayStats.TotalAdditions != 50 {
+		t.Errorf("TotalAdditions = %d, want 50", cm.cache.DisplayStats.TotalAdditions)
+	}
+
+	cm.loadDetails()
+	if len(cm.cache.Repositories) != 2 || len(cm.cache.Repositories["/src/b"].CommitHistory) != 3 {
+		t.Fatalf("repositories not read: %+v", cm.cache.Repositories)
+	}
+	if len(cm.cache.Commits["/src/a"]) != 2 || len(cm.cache.CommitIndex) != 5 || len(cm.cache.DateIndex) != 3 {
+		t.Errorf("commits or indexes not read: %d commits, %d hashes, %d days",
+			len(cm.cache.Commits["/src/a"]), len(cm.cache.CommitIndex), len(cm.cache.DateIndex))
+	}
+	if cm.cache.BlobStats["blob"].Stats.Lines != 7 {
+		t.Errorf("BlobStats = %+v", cm.cache.BlobStats)
+	}
+
+	// Only repositories marked as changed are written
+	for _, repoPath := range []string{"/src/a", "/src/b"} {
+		repo := cm.cache.Repositories[repoPath]
+		repo.Contributors = map[string]int{"Changed": 1}
+		cm.cache.Repositories[repoPath] = repo
+	}
+	cm.markChanged("/src/b")
+	save(cm)
+
+	cm = load()
+	cm.loadDetails()
+	if got := cm.cache.Repositories["/src/a"].Contributors; got["Dev"] != 2 {
+		t.Errorf("unchanged repository was rewritten: %v", got)
+	}
+	if got := cm.cache.Repositories["/src/b"].Contributors; got["Changed"] != 1 {
+		t.Errorf("changed repository was not written: %v", got)
+	}
+
+	// Repositories no longer cached are deleted
+	delete(cm.cache.Repositories, "/src/a")
+	save(cm)
+	cm = load()
+	if cm.cache.Version != strconv.Itoa(cacheFormatVersion) {
+		t.Errorf("Version = %q", cm.cache.Version)
+	}
+	cm.loadDetails()
+	if _, ok := cm.cache.Repositories["/src/a"]; ok || len(cm.cache.Repositories) != 1 {
+		t.Errorf("Repositories = %v, want only /src/b", cm.cache.Repositories)
+	}
+}
+
+func TestBoltStorageImportsFileCache(t *testing.T) {
+	path := copyFixture(t, "format1.cache")
+
+	config.AppConfig.CacheSettings.Backend = BackendBolt
+	defer func() { config.AppConfig.CacheSettings.Backend = "" }()
+
+	cm := NewCacheManager(path)
+	if err := cm.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := cm.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	cm = NewCacheManager(path)
+	if err := cm.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	cm.loadDetails()
+	if repo := cm.cache.Repositories[fixtureRepo]; len(repo.CommitHistory) != 3 {
+		t.Errorf("file cache not imported: %+v", cm.cache.Repositories)
+	}
+}
+
+func TestDisplayStatsCoAuthored(t *testing.T) {
+	original := config.AppConfig.CoAuthorSettings.CountTowardStreaks
+	defer func() { config.AppConfig.CoAuthorSettings.CountTowardStreaks = original }()
+
+	repo := testRepo("/src/a", 3)
+	repo.CommitHistory[0].CoAuthored = true
+	repos := map[string]scan.RepoMetadata{repo.Path: repo}
+
+	tests := []struct {
+		countCoAuthored bool
+		additions       int
+	}{
+		{false, 20},
+		{true, 30},
+	}
+	for _, tt := range tests {
+		config.AppConfig.CoAuthorSettings.CountTowardStreaks = tt.countCoAuthored
+		cm := &CacheManager{cache: newCommitCache()}
+		cm.updateCacheData(repos)
+
+		if got := cm.cache.DisplayStats.TotalAdditions; got != tt.additions {
+			t.Errorf("count co-authored %v: TotalAdditions = %d, want %d", tt.countCoAuthored, got, tt.additions)
+		}
+		if got := cm.cache.Authors["Dev <dev@example.com>"].TotalCommits; got != tt.additions/10 {
+			t.Errorf("count co-authored %v: author commits = %d, want %d", tt.countCoAuthored, got, tt.additions/10)
+		}
+		if len(cm.cache.Commits[repo.Path]) != 3 {
+			t.Errorf("count co-authored %v: Commits = %d, want all 3", tt.countCoAuthored, len(cm.cache.Commits[repo.Path]))
+		}
+	}
+}
+
+func TestGetCommitsAcrossClones(t *testing.T) {
+	// Both clones hold the commits tool-0 and tool-1
+	repos := map[string]scan.RepoMetadata{
+		"/src/a/tool": testRepo("/src/a/tool", 2),
+		"/src/b/tool": testRepo("/src/b/tool", 2),
+	}
+	cm := &CacheManager{cache: newCommitCache()}
+	cm.updateCacheData(repos)
+
+	since := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		options QueryOptions
+	}{
+		{"author", QueryOptions{Author: "Dev <dev@example.com>", Since: since}},
+		{"repository", QueryOptions{Repository: "/src/b/tool", Since: since}},
+		{"date", QueryOptions{Since: since, Until: until}},
+	}
+	for _, tt := range tests {
+		commits := cm.GetCommits(tt.options)
+		var hashes []string
+		for _, commit := range commits {
+			hashes = append(hashes, commit.Hash)
+		}
+		sort.Strings(hashes)
+		if want := []string{"tool-0", "tool-1"}; !reflect.DeepEqual(hashes, want) {
+			t.Errorf("GetCommits() by %s = %v, want %v", tt.name, hashes, want)
+		}
+	}
+}
+
+func TestRefreshLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "streakode.cache")
+
+	held, waited, err := lockRefresh(context.Background(), path)
+	if err != nil || waited {
+		t.Fatalf("lockRefresh() = %v, %v", waited, err)
+	}
+	if !refreshRunning(path) {
+		t.Errorf("refreshRunning() = false while the lock is held")
+	}
+
+	// Waiting ends with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 3*refreshPollInterval)
+	defer cancel()
+	if _, waited, err := lockRefresh(ctx, path); !waited || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("lockRefresh() = %v, %v, want waited and deadline exceeded", waited, err)
+	}
+
+	// or once the refresh finishes
+	go func() {
+		time.Sleep(2 * refreshPollInterval)
+		held.Unlock()
+	}()
+	lock, waited, err := lockRefresh(context.Background(), path)
+	if err != nil || !waited {
+		t.Fatalf("lockRefresh() = %v, %v, want waited", waited, err)
+	}
+	lock.Unlock()
+	if refreshRunning(path) {
+		t.Errorf("refreshRunning() = true after the lock was released")
+	}
+}
+
+func TestConcurrentSaves(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "streakode.cache")
+
+	// Left behind by a writer killed midway
+	orphan := path + ".12345.tmp"
+	if err := os.WriteFile(orphan, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		go func(i int) {
+			cm := NewCacheManager(path)
+			repoPath := fmt.Sprintf("/src/%d", i)
+			cm.updateCacheData(map[string]scan.RepoMetadata{repoPath: testRepo(repoPath, i+1)})
+			errs <- cm.Save()
+		}(i)
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Save() error = %v", err)
+		}
+	}
+
+	cm := NewCacheManager(path)
+	if err := cm.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cm.cache.Repositories) != 1 {
+		t.Errorf("Repositories = %d, want the one of the last save", len(cm.cache.Repositories))
+	}
+	if temps, _ := filepath.Glob(path + ".*.tmp"); len(temps) != 0 {
+		t.Errorf("temp files left behind: %v", temps)
+	}
+}
+
+func TestBackgroundStatus(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "streakode.cache")
+
+	if status, err := GetBackgroundStatus(path); status != nil || err != nil {
+		t.Fatalf("GetBackgroundStatus() = %+v, %v, want none", status, err)
+	}
+
+	started := time.Now().Add(-time.Minute).Round(0)
+	if err := writeBackgroundStatus(path, BackgroundStatus{PID: 42, Started: started}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Running while the refresh lock is held
+	lock, _, err := lockRefresh(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := GetBackgroundStatus(path)
+	if err != nil || !status.Running || status.Error != "" || status.PID != 42 || !status.Started.Equal(started) {
+		t.Errorf("GetBackgroundStatus() = %+v, %v, want running", status, err)
+	}
+
+	// A process killed midway never records its outcome
+	lock.Unlock()
+	status, err = GetBackgroundStatus(path)
+	if err != nil || status.Running || status.Error == "" {
+		t.Errorf("GetBackgroundStatus() = %+v, %v, want stopped", status, err)
+	}
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/AccursedGalaxy/streakode/scan"
+)
+
+// cacheMagic starts the header line of every cache file written with a
+// format version, "streakode-cache v1\n", followed by the gob encoded
+// CommitCache. Files without it were written before caches were versioned
+// and are format 0.
+const cacheMagic = "streakode-cache"
+
+// cacheFormatVersion is the format Save writes. Bump it, and register a
+// migration from the previous version, whenever a change to CommitCache or
+// the types it holds would leave data from older files wrong or missing.
+const cacheFormatVersion = 2
+
+// cacheMigration upgrades a cache decoded from one format version to the
+// next. Caches are decoded into the current types first, so a migration
+// repairs what older files leave zero or stale.
+type cacheMigration func(*CommitCache) error
+
+// cacheMigrations maps a format version to the migration to the next one
+var cacheMigrations = map[int]cacheMigration{
+	0: migrateUnversioned,
+	1: migrateLineCounts,
+}
+
+// ErrNewerCache is returned for cache files written by a newer streakode.
+// They are left untouched.
+var ErrNewerCache = errors.New("cache was written by a newer version of streakode")
+
+func newerCacheError(version int) error {
+	return fmt.Errorf("%w (format %d, this version reads up to %d); upgrade streakode or run 'streakode cache clean'",
+		ErrNewerCache, version, cacheFormatVersion)
+}
+
+// writeCache writes the header and the cache
+func writeCache(w io.Writer, cache *CommitCache) error {
+	cache.Version = strconv.Itoa(cacheFormatVersion)
+	if _, err := fmt.Fprintf(w, "%s v%d\n", cacheMagic, cacheFormatVersion); err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(cache)
+}
+
+// readCache reads a cache of any known format version and migrates it to
+// the current one
+func readCache(r io.Reader) (*CommitCache, error) {
+	reader := bufio.NewReader(r)
+	version, err := readCacheHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+	if version > cacheFormatVersion {
+		return nil, newerCacheError(version)
+	}
+
+	cache := newCommitCache()
+	if err := gob.NewDecoder(reader).Decode(cache); err != nil {
+		if err == io.EOF {
+			return newCommitCache(), nil
+		}
+		return nil, fmt.Errorf("failed to decode cache (format %d): %v", version, err)
+	}
+
+	if err := migrateCache(cache, version); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// migrateCache runs the migrations from version to the current format
+func migrateCache(cache *CommitCache, version int) error {
+	for ; version < cacheFormatVersion; version++ {
+		migrate, ok := cacheMigrations[version]
+		if !ok {
+			return fmt.Errorf("no migration from cache format %d", version)
+		}
+		if err := migrate(cache); err != nil {
+			return fmt.Errorf("failed to migrate cache from format %d: %v", version, err)
+		}
+	}
+	return nil
+}
+
+// readCacheHeader returns the format version of the cache, consuming the
+// header line if there is one
+func readCacheHeader(reader *bufio.Reader) (int, error) {
+	prefix, err := reader.Peek(len(cacheMagic))
+	if err != nil || string(prefix) != cacheMagic {
+		// Unversioned caches start right away with gob data
+		return 0, nil
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return 0, fmt.Errorf("failed to read cache header: %v", err)
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(line), cacheMagic+" v"))
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid cache header %q", strings.TrimSpace(line))
+	}
+	return version, nil
+}
+
+// migrateUnversioned upgrades caches from before the format was versioned.
+// They may lack maps added since, and their indexes and display statistics
+// predate several fields, so both are rebuilt from the repositories.
+func migrateUnversioned(cache *CommitCache) error {
+	if cache.RepoStates == nil {
+		cache.RepoStates = make(map[string]RepoState)
+	}
+	repos := cache.Repositories
+	if repos == nil {
+		repos = make(map[string]scan.RepoMetadata)
+	}
+	lastSync := cache.LastSync
+	(&CacheManager{cache: cache}).updateCacheData(repos)
+	cache.LastSync = lastSync
+	return nil
+}
+
+// migrateLineCounts drops line counts from format 1, which counted the
+// newline ending a file as the start of one more, blank, line. Without a
+// tree hash the next refresh counts every tree again.
+func migrateLineCounts(cache *CommitCache) error {
+	cache.BlobStats = nil
+	for path, repo := range cache.Repositories {
+		repo.TreeHash = ""
+		cache.Repositories[path] = repo
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Lock files next to the cache, shared by every streakode process using it
+const (
+	storeLockSuffix   = ".lock"    // held while the cache is read or written
+	refreshLockSuffix = ".refresh" // held by the process refreshing the cache
+)
+
+// refreshPollInterval is how often a process waiting for the refresh of
+// another one checks whether it finished
+const refreshPollInterval = 100 * time.Millisecond
+
+// errLocked is returned by tryLockFile while another process holds the lock
+var errLocked = errors.New("locked by another process")
+
+// fileLock is an advisory lock on a file. The operating system releases it
+// when the process exits, so a killed process never leaves it held.
+type fileLock struct {
+	file *os.File
+}
+
+// lockFile waits for the lock at path, shared with other readers or
+// exclusive
+func lockFile(path string, exclusive bool) (*fileLock, error) {
+	return openLock(path, exclusive, true)
+}
+
+// tryLockFile takes the exclusive lock at path, returning errLocked instead
+// of waiting when another process holds it
+func tryLockFile(path string) (*fileLock, error) {
+	return openLock(path, true, false)
+}
+
+func openLock(path string, exclusive, wait bool) (*fileLock, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %v", err)
+	}
+	if err := lockHandle(file, exclusive, wait); err != nil {
+		file.Close()
+		if err == errLocked {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to lock %s: %v", path, err)
+	}
+	return &fileLock{file: file}, nil
+}
+
+// Unlock releases the lock
+func (l *fileLock) Unlock() {
+	unlockHandle(l.file)
+	l.file.Close()
+}
+
+// lockRefresh makes this process the one refreshing the cache at cachePath.
+// While another process refreshes it, lockRefresh waits for it to finish and
+// reports that it waited, so the caller can reuse the result instead of
+// scanning again.
+func lockRefresh(ctx context.Context, cachePath string) (*fileLock, bool, error) {
+	waited := false
+	for {
+		lock, err := tryLockFile(cachePath + refreshLockSuffix)
+		if err == nil {
+			// Name the refreshing process for anyone looking
+			lock.file.Truncate(0)
+			fmt.Fprintf(lock.file, "%d %s\n", os.Getpid(), time.Now().Format(time.RFC3339))
+			return lock, waited, nil
+		}
+		if err != errLocked {
+			return nil, waited, err
+		}
+
+		// Only someone at a terminal is told, so command output, pipes and
+		// the background refresh log stay clean
+		if !waited && term.IsTerminal(int(os.Stderr.Fd())) {
+			fmt.Fprintln(os.Stderr, "⏳ Waiting for another streakode process to finish refreshing the cache...")
+		}
+		waited = true
+		select {
+		case <-ctx.Done():
+			return nil, waited, ctx.Err()
+		case <-time.After(refreshPollInterval):
+		}
+	}
+}
+
+// refreshRunning reports whether a process is refreshing the cache at
+// cachePath
+func refreshRunning(cachePath string) bool {
+	lock, err := tryLockFile(cachePath + refreshLockSuffix)
+	if err != nil {
+		return err == errLocked
+	}
+	lock.Unlock()
+	return false
+}
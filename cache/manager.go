@@ -1,11 +1,11 @@
 package cache
 
 import (
+	"context"
 	"encoding/gob"
+	"errors"
 	"fmt"
-	"io"
-	"os"
-	"os/exec"
+	"log"
 	"runtime"
 	"sort"
 	"strings"
@@ -33,12 +33,12 @@ type CommitCache struct {
 	Commits  map[string][]scan.CommitHistory // repo -> commits
 	Authors  map[string]AuthorStats          // author -> stats
 	LastSync time.Time
-	Version  string
+	Version  string // format version the cache was saved in
 
 	// Performance optimizations
 	CommitIndex map[string]map[string]bool // hash -> repo -> exists
 	DateIndex   map[string][]string        // YYYY-MM-DD -> commit hashes
-	AuthorIndex map[string][]string        // author -> commit hashes
+	AuthorIndex map[string][]string        // canonical identity -> commit hashes
 
 	// Pre-calculated display data
 	DisplayStats DisplayStats
@@ -48,6 +48,13 @@ type CommitCache struct {
 
 	// Track repo states for incremental updates
 	RepoStates map[string]RepoState
+
+	// Outcome of the last full scan
+	LastScan scan.ScanReport
+
+	// Line counts of file contents by blob hash, so unchanged files are not
+	// counted again
+	BlobStats map[string]scan.BlobRecord
 }
 
 // AuthorStats holds aggregated statistics for an author
@@ -61,16 +68,29 @@ type AuthorStats struct {
 	LastActivity  time.Time
 }
 
+// blobMemoMaxAge is how long line counts of blobs no scan needed are kept
+const blobMemoMaxAge = 30 * 24 * time.Hour
+
 // DisplayStats holds pre-calculated statistics for display
 type DisplayStats struct {
 	WeeklyTotal    int
 	WeeklyDiff     int
 	DailyAverage   float64
-	TotalAdditions int
+	TotalAdditions int // after change rules, without excluded outliers
 	TotalDeletions int
+	RawAdditions   int // every changed line
+	RawDeletions   int
+	Outliers       int // abnormally large commits
 	PeakHour       int
 	PeakCommits    int
-	LanguageStats  map[string]int
+	LanguageStats  map[string]int // lines per language in the checked out trees
+	WrittenStats   map[string]int // changed lines per language in your commits
+	CommitTypes    map[string]int // commits per conventional type, "" for the rest
+	Collapsed      int            // rebased or cherry-picked copies counted once by patch-id
+	CodeLines      int            // lines of the checked in trees holding code
+	CommentLines   int
+	BlankLines     int
+	TypeTrend      []scan.TypePeriod
 	RepoStats      []RepoDisplayStats
 	LastUpdate     time.Time
 }
@@ -78,9 +98,11 @@ type DisplayStats struct {
 // RepoDisplayStats holds pre-calculated statistics for a repository
 type RepoDisplayStats struct {
 	Name           string
+	Clones         int // clones and forks grouped into this project
 	WeeklyCommits  int
 	CurrentStreak  int
 	LongestStreak  int
+	FrozenDays     []string // days of the current streak covered by a freeze
 	Additions      int
 	Deletions      int
 	LastCommitTime time.Time
@@ -102,6 +124,10 @@ type CacheManager struct {
 	updates       chan *CommitCache
 	notifications chan CacheUpdate
 	path          string
+	loadErr       error // set when the cache on disk must not be overwritten
+	storage       Storage
+	changed       map[string]bool // repositories modified since the last load or save
+	detailsMu     sync.Mutex      // serializes reading details left on disk by Load
 }
 
 // CacheUpdate represents a cache update notification
@@ -111,6 +137,10 @@ type CacheUpdate struct {
 	Changes int
 }
 
+// UpdateRescan is the type of the update sent for every repository
+// refreshed incrementally; Changes counts its new commits
+const UpdateRescan = "rescan"
+
 // NewCacheManager creates a new cache manager instance
 func NewCacheManager(cachePath string) *CacheManager {
 	return &CacheManager{
@@ -118,6 +148,8 @@ func NewCacheManager(cachePath string) *CacheManager {
 		path:          cachePath,
 		updates:       make(chan *CommitCache, 10),
 		notifications: make(chan CacheUpdate, 100),
+		storage:       newStorage(cachePath),
+		changed:       make(map[string]bool),
 	}
 }
 
@@ -146,77 +178,182 @@ func (cm *CacheManager) StartBackgroundRefresh(interval time.Duration) {
 // RefreshInBackground performs a non-blocking cache refresh
 func (cm *CacheManager) RefreshInBackground() {
 	go func() {
-		if err := cm.Refresh(); err != nil {
+		if err := cm.Refresh(context.Background()); err != nil {
 			fmt.Printf("Background refresh failed: %v\n", err)
 		}
 	}()
 }
 
-// Refresh updates the cache with fresh data
-func (cm *CacheManager) Refresh() error {
+// Refresh updates the cache with fresh data. Repositories due for a scan are
+// refreshed incrementally from the ref tips recorded at their last scan.
+// Repositories not refreshed before ctx is cancelled keep their cached data.
+func (cm *CacheManager) Refresh(ctx context.Context) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	// Reuse the result of a refresh running in another process
+	lock, waited, err := lockRefresh(ctx, cm.path)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	if waited {
+		return cm.Load()
+	}
+	cm.loadDetails()
+
+	return cm.rescan(ctx, cm.isDue)
+}
+
+// RefreshRepos refreshes the given repositories incrementally, due or not.
+// It starts from the cache on disk, which other processes may have saved
+// since this one loaded it.
+func (cm *CacheManager) RefreshRepos(ctx context.Context, paths []string) error {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
+	lock, _, err := lockRefresh(ctx, cm.path)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	if err := cm.Load(); err != nil {
+		return err
+	}
+	cm.loadDetails()
+
+	wanted := make(map[string]bool, len(paths))
+	for _, repoPath := range paths {
+		wanted[repoPath] = true
+	}
+	return cm.rescan(ctx, func(repoPath string) bool { return wanted[repoPath] })
+}
+
+// rescan refreshes the cached repositories selected by due and saves the
+// cache, queueing an update notification for each repository scanned. The
+// caller holds the refresh lock.
+func (cm *CacheManager) rescan(ctx context.Context, due func(repoPath string) bool) error {
+	updatedRepos := make(map[string]scan.RepoMetadata, len(cm.cache.Repositories))
+	var selected []scan.RepoMetadata
+	for repoPath, repo := range cm.cache.Repositories {
+		if due(repoPath) {
+			selected = append(selected, repo)
+		} else {
+			updatedRepos[repoPath] = repo
+		}
+	}
+
+	memo := scan.NewBlobMemo(cm.cache.BlobStats)
+	ctx = scan.WithBlobMemo(ctx, memo)
+
 	workerCount := runtime.NumCPU()
-	jobs := make(chan string, len(cm.cache.Repositories))
-	results := make(chan *scan.RepoMetadata, len(cm.cache.Repositories))
+	jobs := make(chan scan.RepoMetadata, len(selected))
+	results := make(chan scan.RepoMetadata, len(selected))
 
 	// Start workers
 	for i := 0; i < workerCount; i++ {
-		go cm.repoWorker(jobs, results)
+		go repoWorker(ctx, jobs, results, config.AppConfig.Author)
 	}
 
 	// Queue jobs
-	for repoPath := range cm.cache.Repositories {
-		jobs <- repoPath
-		// Adjust scan interval for next time
-		cm.adjustScanInterval(repoPath)
+	for _, repo := range selected {
+		jobs <- repo
 	}
 	close(jobs)
 
-	// Collect results
-	updatedRepos := make(map[string]scan.RepoMetadata)
-	for i := 0; i < len(cm.cache.Repositories); i++ {
-		if result := <-results; result != nil {
-			updatedRepos[result.Path] = *result
+	// Collect results, dropping repos that no longer qualify as ScanDirectories would
+	scanned := make(map[string]scan.RepoMetadata, len(selected))
+	for range selected {
+		result := <-results
+		// Repositories interrupted or timed out keep their cached data
+		if len(result.ScanErrors) > 0 && (ctx.Err() != nil || !result.AuthorVerified) {
+			updatedRepos[result.Path] = cm.cache.Repositories[result.Path]
+			continue
+		}
+		if result.AuthorVerified && !result.Dormant {
+			scanned[result.Path] = result
+			updatedRepos[result.Path] = result
+			cm.markChanged(result.Path)
 		}
+		cm.notify(CacheUpdate{
+			Type:    UpdateRescan,
+			RepoID:  result.Path,
+			Changes: newCommits(cm.cache.Repositories[result.Path], result),
+		})
 	}
 
 	// Update cache with new data
 	cm.updateCacheData(updatedRepos)
+	cm.recordRepoStates(scanned)
+	cm.cache.BlobStats = memo.Records(blobMemoMaxAge)
+
+	// Adjust scan interval for next time
+	for repoPath := range scanned {
+		cm.adjustScanInterval(repoPath)
+	}
 
 	return cm.Save()
 }
 
-// checkRepoState determines if a repo needs updating
-func (cm *CacheManager) checkRepoState(repoPath string) (bool, error) {
-	// Get current state
+// newCommits counts the commits of after that before did not have
+func newCommits(before, after scan.RepoMetadata) int {
+	known := make(map[string]bool, len(before.CommitHistory))
+	for _, commit := range before.CommitHistory {
+		known[commit.Hash] = true
+	}
+	count := 0
+	for _, commit := range after.CommitHistory {
+		if !known[commit.Hash] {
+			count++
+		}
+	}
+	return count
+}
+
+// notify queues an update, dropping it when nobody reads the notifications
+func (cm *CacheManager) notify(update CacheUpdate) {
+	select {
+	case cm.notifications <- update:
+	default:
+	}
+}
+
+// isDue reports whether the minimum scan interval of a repo has elapsed
+func (cm *CacheManager) isDue(repoPath string) bool {
 	state := cm.cache.RepoStates[repoPath]
-	
-	// Check if minimum scan interval has elapsed
-	if time.Since(state.LastScan) < state.ScanInterval {
-		return false, nil
+	return time.Since(state.LastScan) >= state.ScanInterval
+}
+
+// moveRepoStates carries the state of moved or renamed repositories over to
+// their new path and forgets the state of paths that are no longer cached
+func (cm *CacheManager) moveRepoStates(report scan.ScanReport) {
+	if cm.cache.RepoStates == nil {
+		cm.cache.RepoStates = make(map[string]RepoState)
+	}
+	for _, outcome := range report.Repos {
+		if state, ok := cm.cache.RepoStates[outcome.MovedFrom]; ok && outcome.MovedFrom != "" {
+			cm.cache.RepoStates[outcome.Path] = state
+		}
 	}
+	for repoPath := range cm.cache.RepoStates {
+		if _, ok := cm.cache.Repositories[repoPath]; !ok {
+			delete(cm.cache.RepoStates, repoPath)
+		}
+	}
+}
 
-	// Get latest commit hash
-	cmd := exec.Command("git", "-C", repoPath, "rev-parse", "HEAD")
-	output, err := cmd.Output()
-	if err != nil {
-		return false, fmt.Errorf("failed to get latest hash: %v", err)
+// recordRepoStates remembers when repos were scanned and where HEAD was
+func (cm *CacheManager) recordRepoStates(repos map[string]scan.RepoMetadata) {
+	if cm.cache.RepoStates == nil {
+		cm.cache.RepoStates = make(map[string]RepoState)
+	}
+	for repoPath, repo := range repos {
+		state := cm.cache.RepoStates[repoPath]
+		state.LastHash = repo.RefTips["HEAD"]
+		state.LastScan = repo.LastAnalyzed
+		state.IsStale = false
+		cm.cache.RepoStates[repoPath] = state
 	}
-	
-	latestHash := strings.TrimSpace(string(output))
-	
-	// Repo needs update if hash changed
-	needsUpdate := latestHash != state.LastHash
-	
-	// Update state
-	state.LastHash = latestHash
-	state.LastScan = time.Now()
-	state.IsStale = needsUpdate
-	cm.cache.RepoStates[repoPath] = state
-	
-	return needsUpdate, nil
 }
 
 // adjustScanInterval updates the scan interval based on repo activity
@@ -247,29 +384,13 @@ func (cm *CacheManager) adjustScanInterval(repoPath string) {
 	cm.cache.RepoStates[repoPath] = state
 }
 
-// Update repoWorker to use incremental scanning
-func (cm *CacheManager) repoWorker(jobs <-chan string, results chan<- *scan.RepoMetadata) {
-	for repoPath := range jobs {
-		// Check if repo needs update
-		needsUpdate, err := cm.checkRepoState(repoPath)
-		if err != nil {
-			if config.AppConfig.Debug {
-				fmt.Printf("Error checking repo state: %v\n", err)
-			}
-			results <- nil
-			continue
-		}
-
-		// Return existing metadata if no update needed
-		if !needsUpdate {
-			existing := cm.cache.Repositories[repoPath]
-			results <- &existing
-			continue
-		}
-
-		// Fetch fresh metadata if update needed
-		meta := scan.FetchRepoMetadata(repoPath)
-		results <- &meta
+// repoWorker refreshes repositories incrementally, each within the
+// configured per-repository timeout
+func repoWorker(ctx context.Context, jobs <-chan scan.RepoMetadata, results chan<- scan.RepoMetadata, author string) {
+	for repo := range jobs {
+		repoCtx, cancel := scan.WithRepoTimeout(ctx)
+		results <- scan.UpdateRepoMeta(repoCtx, repo, author)
+		cancel()
 	}
 }
 
@@ -285,32 +406,54 @@ func (cm *CacheManager) updateCacheData(newRepos map[string]scan.RepoMetadata) {
 	// Pre-calculated display stats
 	displayStats := DisplayStats{
 		LanguageStats: make(map[string]int),
+		WrittenStats:  make(map[string]int),
+		CommitTypes:   make(map[string]int),
 		LastUpdate:    time.Now(),
 	}
+	var allCommits []scan.CommitHistory
 
 	hourStats := make(map[int]int)
 	var repoStats []RepoDisplayStats
 
-	// Process repositories sequentially for better memory usage
+	// Commits stay listed under every clone that contains them
 	for path, repo := range newRepos {
-		commitStats := make([]scan.CommitHistory, 0, len(repo.CommitHistory))
-		repoAdditions := 0
-		repoDeletions := 0
-
+		commitsByRepo[path] = append([]scan.CommitHistory(nil), repo.CommitHistory...)
 		for _, commit := range repo.CommitHistory {
-			commitStats = append(commitStats, commit)
-
-			// Update indexes
 			if commitIndex[commit.Hash] == nil {
 				commitIndex[commit.Hash] = make(map[string]bool)
 			}
 			commitIndex[commit.Hash][path] = true
+		}
+	}
 
-			dateKey := commit.Date.Format("2006-01-02")
+	// Statistics count clones and forks of a project once, so a commit
+	// pushed to several of them is counted once
+	projects := scan.GroupProjects(newRepos)
+	for _, project := range projects {
+		repo := project.Meta
+		repoAdditions := 0
+		repoDeletions := 0
+
+		for _, commit := range repo.CommitHistory {
+			dateKey := scan.DayKey(commit.Date)
 			dateIndex[dateKey] = append(dateIndex[dateKey], commit.Hash)
 
+			// Update author index under the canonical identity
+			author := commit.Identity
+			if author == "" {
+				author = commit.Author
+			}
+			authorIndex[author] = append(authorIndex[author], commit.Hash)
+
+			// Statistics only include co-authored commits when configured
+			// to, like streaks
+			if commit.CoAuthored && !config.AppConfig.CoAuthorSettings.CountTowardStreaks {
+				continue
+			}
+			allCommits = append(allCommits, commit)
+
 			// Update author stats
-			stats := authorStats[commit.Author]
+			stats := authorStats[author]
 			stats.TotalCommits++
 			if stats.ActiveDays == nil {
 				stats.ActiveDays = make(map[string]bool)
@@ -322,22 +465,32 @@ func (cm *CacheManager) updateCacheData(newRepos map[string]scan.RepoMetadata) {
 			if stats.PeakHours == nil {
 				stats.PeakHours = make(map[int]int)
 			}
-			stats.PeakHours[commit.Date.Hour()]++
-			authorStats[commit.Author] = stats
-
-			// Update author index
-			authorIndex[commit.Author] = append(authorIndex[commit.Author], commit.Hash)
+			stats.PeakHours[scan.LocalTime(commit.Date).Hour()]++
+			if stats.Languages == nil {
+				stats.Languages = make(map[string]int)
+			}
+			for lang, lines := range commit.Languages {
+				stats.Languages[lang] += lines
+				displayStats.WrittenStats[lang] += lines
+			}
+			authorStats[author] = stats
+			displayStats.CommitTypes[commit.Type]++
 
 			// Update display stats
-			hourStats[commit.Date.Hour()]++
-			repoAdditions += commit.Additions
-			repoDeletions += commit.Deletions
-			displayStats.TotalAdditions += commit.Additions
-			displayStats.TotalDeletions += commit.Deletions
+			hourStats[scan.LocalTime(commit.Date).Hour()]++
+			additions, deletions := commit.CountedChanges()
+			repoAdditions += additions
+			repoDeletions += deletions
+			displayStats.TotalAdditions += additions
+			displayStats.TotalDeletions += deletions
+			rawAdditions, rawDeletions := commit.RawChanges()
+			displayStats.RawAdditions += rawAdditions
+			displayStats.RawDeletions += rawDeletions
+			if commit.Outlier {
+				displayStats.Outliers++
+			}
 		}
 
-		commitsByRepo[path] = commitStats
-
 		// Update language stats
 		for lang, lines := range repo.Languages {
 			displayStats.LanguageStats[lang] += lines
@@ -345,10 +498,12 @@ func (cm *CacheManager) updateCacheData(newRepos map[string]scan.RepoMetadata) {
 
 		// Create repo display stats
 		repoStats = append(repoStats, RepoDisplayStats{
-			Name:           path[strings.LastIndex(path, "/")+1:],
+			Name:           project.Path[strings.LastIndex(project.Path, "/")+1:],
+			Clones:         len(project.Clones),
 			WeeklyCommits:  repo.WeeklyCommits,
 			CurrentStreak:  repo.CurrentStreak,
 			LongestStreak:  repo.LongestStreak,
+			FrozenDays:     repo.FrozenDays,
 			Additions:      repoAdditions,
 			Deletions:      repoDeletions,
 			LastCommitTime: repo.LastCommit,
@@ -373,9 +528,13 @@ func (cm *CacheManager) updateCacheData(newRepos map[string]scan.RepoMetadata) {
 	weeklyTotal := 0
 	lastWeekTotal := 0
 
-	for _, repo := range newRepos {
-		weeklyTotal += repo.WeeklyCommits
-		lastWeekTotal += repo.LastWeeksCommits
+	for _, project := range projects {
+		weeklyTotal += project.Meta.WeeklyCommits
+		lastWeekTotal += project.Meta.LastWeeksCommits
+		displayStats.Collapsed += project.Meta.CollapsedCommits
+		displayStats.CodeLines += project.Meta.CodeLines
+		displayStats.CommentLines += project.Meta.CommentLines
+		displayStats.BlankLines += project.Meta.BlankLines
 	}
 
 	// Update display stats
@@ -385,6 +544,7 @@ func (cm *CacheManager) updateCacheData(newRepos map[string]scan.RepoMetadata) {
 	displayStats.PeakHour = peakHour
 	displayStats.PeakCommits = peakCommits
 	displayStats.RepoStats = repoStats
+	displayStats.TypeTrend = scan.TypeTrend(allCommits, scan.TypeTrendWeeks)
 
 	// Update cache with all data
 	cm.cache.Commits = commitsByRepo
@@ -399,56 +559,81 @@ func (cm *CacheManager) updateCacheData(newRepos map[string]scan.RepoMetadata) {
 
 // Save persists the cache to disk
 func (cm *CacheManager) Save() error {
-	tempFile := cm.path + ".tmp"
-
-	file, err := os.Create(tempFile)
-	if err != nil {
-		return fmt.Errorf("failed to create temp file: %v", err)
+	// A cache written by a newer version is never overwritten
+	if cm.loadErr != nil {
+		return cm.loadErr
 	}
-	defer file.Close()
 
-	// Use gob encoding for efficient binary serialization
-	encoder := gob.NewEncoder(file)
-	if err := encoder.Encode(cm.cache); err != nil {
-		return fmt.Errorf("failed to encode cache: %v", err)
+	lock, err := lockFile(cm.path+storeLockSuffix, true)
+	if err != nil {
+		return err
 	}
+	defer lock.Unlock()
 
-	// Atomic rename
-	if err := os.Rename(tempFile, cm.path); err != nil {
-		return fmt.Errorf("failed to save cache file: %v", err)
+	if err := cm.storage.Save(cm.cache, cm.changed); err != nil {
+		return err
 	}
-
+	cm.changed = make(map[string]bool)
 	return nil
 }
 
-// Load reads the cache from disk
+// Load reads the cache from disk, migrating caches written by older versions
 func (cm *CacheManager) Load() error {
-	file, err := os.Open(cm.path)
+	lock, err := lockFile(cm.path+storeLockSuffix, false)
 	if err != nil {
-		if os.IsNotExist(err) {
-			cm.cache = newCommitCache()
-			return nil
-		}
-		return fmt.Errorf("failed to open cache file: %v", err)
+		return err
 	}
-	defer file.Close()
+	defer lock.Unlock()
 
-	decoder := gob.NewDecoder(file)
-	if err := decoder.Decode(cm.cache); err != nil {
-		if err == io.EOF {
-			cm.cache = newCommitCache()
-			return nil
+	cache, err := cm.storage.Load()
+	if err != nil {
+		if errors.Is(err, ErrNewerCache) {
+			cm.loadErr = err
 		}
-		return fmt.Errorf("failed to decode cache: %v", err)
+		return err
 	}
+	cm.cache = cache
+	cm.loadErr = nil
+	cm.changed = make(map[string]bool)
 
 	return nil
 }
 
-// GetCommits retrieves commits based on query options
+// loadDetails reads the repositories, commits and indexes if Load left them
+// on disk. A cache whose details can't be read is rebuilt by the next scan.
+func (cm *CacheManager) loadDetails() {
+	cm.detailsMu.Lock()
+	defer cm.detailsMu.Unlock()
+
+	if cm.cache.Repositories != nil {
+		return
+	}
+	lock, err := lockFile(cm.path+storeLockSuffix, false)
+	if err == nil {
+		defer lock.Unlock()
+		err = cm.storage.LoadDetails(cm.cache)
+	}
+	if err != nil {
+		log.Printf("Error loading cache: %v\n", err)
+		resetDetails(cm.cache)
+	}
+}
+
+// markChanged records that repositories were modified, so storages with
+// records per repository rewrite them
+func (cm *CacheManager) markChanged(repoPath string) {
+	if cm.changed == nil {
+		cm.changed = make(map[string]bool)
+	}
+	cm.changed[repoPath] = true
+}
+
+// GetCommits retrieves commits based on query options. A commit found in
+// several clones is listed once.
 func (cm *CacheManager) GetCommits(options QueryOptions) []scan.CommitHistory {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
+	cm.loadDetails()
 
 	var commits []scan.CommitHistory
 
@@ -473,15 +658,15 @@ type QueryOptions struct {
 
 func (cm *CacheManager) getCommitsByAuthor(author string, since time.Time) []scan.CommitHistory {
 	var commits []scan.CommitHistory
-	hashes := cm.cache.AuthorIndex[author]
-
-	for _, hash := range hashes {
-		for repoPath := range cm.cache.CommitIndex[hash] {
-			for _, commit := range cm.cache.Commits[repoPath] {
-				if commit.Hash == hash && commit.Date.After(since) {
-					commits = append(commits, commit)
-				}
-			}
+	seen := make(map[string]bool)
+
+	for _, hash := range cm.cache.AuthorIndex[author] {
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		if commit, ok := cm.commitByHash(hash); ok && commit.Date.After(since) {
+			commits = append(commits, commit)
 		}
 	}
 
@@ -505,19 +690,18 @@ func (cm *CacheManager) getCommitsByRepo(repo string, since time.Time) []scan.Co
 
 func (cm *CacheManager) getCommitsByDate(since, until time.Time) []scan.CommitHistory {
 	var commits []scan.CommitHistory
+	seen := make(map[string]bool)
 	current := since
 
 	for !current.After(until) {
-		dateKey := current.Format("2006-01-02")
-		if hashes, exists := cm.cache.DateIndex[dateKey]; exists {
-			for _, hash := range hashes {
-				for repoPath := range cm.cache.CommitIndex[hash] {
-					for _, commit := range cm.cache.Commits[repoPath] {
-						if commit.Hash == hash {
-							commits = append(commits, commit)
-						}
-					}
-				}
+		dateKey := scan.DayKey(current)
+		for _, hash := range cm.cache.DateIndex[dateKey] {
+			if seen[hash] {
+				continue
+			}
+			seen[hash] = true
+			if commit, ok := cm.commitByHash(hash); ok {
+				commits = append(commits, commit)
 			}
 		}
 		current = current.AddDate(0, 0, 1)
@@ -525,3 +709,22 @@ func (cm *CacheManager) getCommitsByDate(since, until time.Time) []scan.CommitHi
 
 	return commits
 }
+
+// commitByHash returns a commit as listed by the first clone, by path, that
+// contains it
+func (cm *CacheManager) commitByHash(hash string) (scan.CommitHistory, bool) {
+	paths := make([]string, 0, len(cm.cache.CommitIndex[hash]))
+	for repoPath := range cm.cache.CommitIndex[hash] {
+		paths = append(paths, repoPath)
+	}
+	sort.Strings(paths)
+
+	for _, repoPath := range paths {
+		for _, commit := range cm.cache.Commits[repoPath] {
+			if commit.Hash == hash {
+				return commit, true
+			}
+		}
+	}
+	return scan.CommitHistory{}, false
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/AccursedGalaxy/streakode/config"
+	"github.com/AccursedGalaxy/streakode/scan"
+)
+
+// Storage keeps the cache on disk
+type Storage interface {
+	// Load reads the cache; a missing store is an empty cache. Stores that
+	// keep repositories in records of their own may leave Repositories,
+	// Commits, the indexes and BlobStats nil until LoadDetails fills them.
+	Load() (*CommitCache, error)
+	LoadDetails(cache *CommitCache) error
+
+	// Save writes the cache. Stores with records per repository only
+	// rewrite those of the repositories in changed and drop those of
+	// repositories no longer cached.
+	Save(cache *CommitCache, changed map[string]bool) error
+}
+
+// Storage backends, selected by cache_settings.backend
+const (
+	BackendGob  = "gob"  // the whole cache in one gob encoded file
+	BackendBolt = "bolt" // records per repository in an embedded database
+)
+
+// newStorage returns the configured storage for the cache at cachePath
+func newStorage(cachePath string) Storage {
+	switch config.AppConfig.CacheSettings.Backend {
+	case BackendBolt:
+		return &boltStorage{path: cachePath + boltSuffix, legacyPath: cachePath}
+	default:
+		return &gobStorage{path: cachePath}
+	}
+}
+
+// gobStorage writes the whole cache as one file on every save
+type gobStorage struct {
+	path string
+}
+
+func (s *gobStorage) Load() (*CommitCache, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return newCommitCache(), nil
+		}
+		return nil, fmt.Errorf("failed to open cache file: %v", err)
+	}
+	defer file.Close()
+
+	return readCache(file)
+}
+
+// LoadDetails has nothing to do: Load reads everything
+func (s *gobStorage) LoadDetails(cache *CommitCache) error {
+	return nil
+}
+
+// Save writes a temp file of its own, flushes it and renames it over the
+// cache, so readers and writers killed midway never see a partial cache. The
+// caller holds the store lock.
+func (s *gobStorage) Save(cache *CommitCache, changed map[string]bool) error {
+	// Temp files left by writers killed midway are no longer in use
+	if stale, err := filepath.Glob(s.path + ".*.tmp"); err == nil {
+		for _, name := range stale {
+			os.Remove(name)
+		}
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %v", err)
+	}
+	tempFile := file.Name()
+
+	// Use gob encoding for efficient binary serialization, behind a header
+	// naming the format version
+	if err := writeCache(file, cache); err != nil {
+		file.Close()
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to encode cache: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to write temp file: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to write temp file: %v", err)
+	}
+
+	// Atomic rename
+	if err := os.Rename(tempFile, s.path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to save cache file: %v", err)
+	}
+	if err := syncDir(filepath.Dir(s.path)); err != nil && config.AppConfig.Debug {
+		fmt.Printf("Debug: Syncing cache directory failed: %v\n", err)
+	}
+
+	return nil
+}
+
+// resetDetails replaces the details of cache with empty maps
+func resetDetails(cache *CommitCache) {
+	cache.Repositories = make(map[string]scan.RepoMetadata)
+	cache.Commits = make(map[string][]scan.CommitHistory)
+	cache.CommitIndex = make(map[string]map[string]bool)
+	cache.DateIndex = make(map[string][]string)
+	cache.AuthorIndex = make(map[string][]string)
+	cache.BlobStats = make(map[string]scan.BlobRecord)
+}
@@ -1,8 +1,8 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"os/exec"
 	"sort"
 	"strings"
 	"time"
@@ -10,6 +10,7 @@ import (
 	"github.com/AccursedGalaxy/streakode/cache"
 	"github.com/AccursedGalaxy/streakode/config"
 	"github.com/AccursedGalaxy/streakode/scan"
+	"github.com/AccursedGalaxy/streakode/scan/gitcmd"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"golang.org/x/term"
@@ -21,14 +22,37 @@ type AuthorStats struct {
 	TotalCommits    int
 	CurrentStreak   int
 	LongestStreak   int
+	FrozenDays      []string // days of the current streak covered by a freeze
 	WeeklyCommits   int
 	MonthlyCommits  int
-	TotalAdditions  int
+	TotalAdditions  int // after change rules, without excluded outliers
 	TotalDeletions  int
+	RawAdditions    int // every changed line
+	RawDeletions    int
+	Outliers        int // abnormally large commits
 	TopRepositories []RepoActivity
 	PeakHour        int
 	PeakCommits     int
-	Languages       map[string]int
+	Languages       map[string]int // lines per language in the repositories worked on
+	Written         map[string]int // changed lines per language in the author's commits
+	CommitTypes     map[string]int // commits per conventional type, "" for the rest
+	Breaking        int            // commits marked as breaking changes
+	TypeTrend       []scan.TypePeriod
+	CoAuthored      int            // commits someone else drove with the author as co-author
+	Pairs           []PairActivity // people the author pairs with, most frequent first
+}
+
+// maxPairs is how many pairing partners the author view lists
+const maxPairs = 5
+
+// PairActivity describes how often the author pairs with someone, counted
+// from Co-authored-by trailers in either direction
+type PairActivity struct {
+	Partner    string // partner's name, or email when the name is missing
+	Commits    int
+	YouDrove   int // the author committed and credited the partner
+	TheyDrove  int // the partner committed and credited the author
+	LastCommit time.Time
 }
 
 type RepoActivity struct {
@@ -42,15 +66,15 @@ type RepoActivity struct {
 }
 
 // DisplayAuthorInfo shows detailed information about the specified author or the configured author
-func DisplayAuthorInfo(targetAuthor string) {
+func DisplayAuthorInfo(ctx context.Context, targetAuthor string) {
 	// If no target author is specified, use the configured author
 	if targetAuthor == "" {
 		targetAuthor = config.AppConfig.Author
 	}
 
 	// Get git configuration
-	globalName, _ := exec.Command("git", "config", "--global", "user.name").Output()
-	globalEmail, _ := exec.Command("git", "config", "--global", "user.email").Output()
+	globalName, _ := gitcmd.Output(ctx, "", "config", "--global", "user.name")
+	globalEmail, _ := gitcmd.Output(ctx, "", "config", "--global", "user.email")
 
 	// Calculate author statistics
 	stats := calculateAuthorStats(targetAuthor)
@@ -61,16 +85,33 @@ func DisplayAuthorInfo(targetAuthor string) {
 	displayAuthorStats(stats)
 }
 
+// authorMatcher returns the identities an author argument stands for. The
+// configured author brings along all of their configured identities.
+func authorMatcher(author string) *scan.IdentityMatcher {
+	if author == config.AppConfig.Author {
+		return scan.ConfiguredIdentities()
+	}
+	return scan.NewIdentityMatcher(author, nil)
+}
+
 func calculateAuthorStats(author string) AuthorStats {
 	stats := AuthorStats{
-		Languages: make(map[string]int),
+		Languages:   make(map[string]int),
+		Written:     make(map[string]int),
+		CommitTypes: make(map[string]int),
 	}
+	matcher := authorMatcher(author)
+	var history []scan.CommitHistory
+	pairs := make(map[string]*PairActivity)
 
 	repoActivities := make(map[string]*RepoActivity)
 	now := time.Now()
 	lookbackTime := now.AddDate(0, 0, -config.AppConfig.AuthorSettings.LookbackDays)
-	weekAgo := now.AddDate(0, 0, -7)
-	monthAgo := now.AddDate(0, 0, -30)
+	// Weekly and monthly counts cover the past 7 and 30 days, today
+	// included, bucketed like streaks
+	today := scan.DayOf(now)
+	weekStart := today.AddDate(0, 0, -6)
+	monthStart := today.AddDate(0, 0, -29)
 
 	// Debug output
 	if config.AppConfig.Debug {
@@ -86,8 +127,15 @@ func calculateAuthorStats(author string) AuthorStats {
 		repo      string
 	}
 
-	// Process each repository
+	repos := make(map[string]scan.RepoMetadata)
 	cache.Cache.Range(func(path string, repo scan.RepoMetadata) bool {
+		repos[path] = repo
+		return true
+	})
+
+	// Process each project, counting commits shared by clones once
+	for _, project := range scan.GroupProjects(repos) {
+		path, repo := project.Path, project.Meta
 		repoName := path[strings.LastIndex(path, "/")+1:]
 		activity := &RepoActivity{
 			Name:       repoName,
@@ -96,8 +144,12 @@ func calculateAuthorStats(author string) AuthorStats {
 
 		// Process commit history
 		for _, commit := range repo.CommitHistory {
-			if !strings.Contains(commit.Author, author) {
-				continue
+			coAuthored := false
+			if !matcher.MatchesCommit(commit) {
+				if !matcher.MatchesCoAuthor(scan.CoAuthors(commit)) {
+					continue
+				}
+				coAuthored = true
 			}
 
 			// Only process commits within lookback period
@@ -108,6 +160,18 @@ func calculateAuthorStats(author string) AuthorStats {
 				continue
 			}
 
+			recordPairing(pairs, matcher, commit, coAuthored)
+			if coAuthored {
+				stats.CoAuthored++
+			}
+
+			// Streaks and totals only include co-authored commits when
+			// configured to
+			if coAuthored && !config.AppConfig.CoAuthorSettings.CountTowardStreaks {
+				continue
+			}
+
+			additions, deletions := commit.CountedChanges()
 			allCommits = append(allCommits, struct {
 				date      time.Time
 				additions int
@@ -115,32 +179,47 @@ func calculateAuthorStats(author string) AuthorStats {
 				repo      string
 			}{
 				date:      commit.Date,
-				additions: commit.Additions,
-				deletions: commit.Deletions,
+				additions: additions,
+				deletions: deletions,
 				repo:      repoName,
 			})
 
 			activity.Commits++
-			activity.Additions += commit.Additions
-			activity.Deletions += commit.Deletions
+			activity.Additions += additions
+			activity.Deletions += deletions
 			stats.TotalCommits++
-			stats.TotalAdditions += commit.Additions
-			stats.TotalDeletions += commit.Deletions
+			stats.TotalAdditions += additions
+			stats.TotalDeletions += deletions
+			rawAdditions, rawDeletions := commit.RawChanges()
+			stats.RawAdditions += rawAdditions
+			stats.RawDeletions += rawDeletions
+			if commit.Outlier {
+				stats.Outliers++
+			}
+			for lang, lines := range commit.Languages {
+				stats.Written[lang] += lines
+			}
+			stats.CommitTypes[commit.Type]++
+			if commit.Breaking {
+				stats.Breaking++
+			}
+			history = append(history, commit)
 
 			// Calculate weekly and monthly stats
 			// Only count if within the lookback period
-			if !commit.Date.Before(weekAgo) && !commit.Date.After(now) {
+			day := scan.DayOf(commit.Date)
+			if !day.Before(weekStart) && !day.After(today) {
 				stats.WeeklyCommits++
 			}
-			if !commit.Date.Before(monthAgo) && !commit.Date.After(now) {
+			if !day.Before(monthStart) && !day.After(today) {
 				stats.MonthlyCommits++
 			}
 
 			// Track peak coding hour
-			hour := commit.Date.Hour()
+			hour := scan.LocalTime(commit.Date).Hour()
 			commitCount := 1
 			for _, c := range allCommits {
-				if c.date.Hour() == hour {
+				if scan.LocalTime(c.date).Hour() == hour {
 					commitCount++
 				}
 			}
@@ -160,9 +239,7 @@ func calculateAuthorStats(author string) AuthorStats {
 		if activity.Commits > 0 {
 			repoActivities[repoName] = activity
 		}
-
-		return true
-	})
+	}
 
 	// Debug output
 	if config.AppConfig.Debug {
@@ -176,55 +253,26 @@ func calculateAuthorStats(author string) AuthorStats {
 		return allCommits[i].date.After(allCommits[j].date)
 	})
 
-	// Calculate streaks
-	if len(allCommits) > 0 {
-		currentStreak := 0
-		longestStreak := 0
-		currentStreakStart := time.Now()
-		lastDate := time.Now()
-
-		// Check if there's a commit today to start the streak
-		if time.Since(allCommits[0].date) < 24*time.Hour {
-			currentStreak = 1
-			currentStreakStart = allCommits[0].date
-			lastDate = allCommits[0].date
-		}
-
-		// Process all commits for streaks
-		for i := 1; i < len(allCommits); i++ {
-			commitDate := allCommits[i].date
-			dayDiff := lastDate.Sub(commitDate).Hours() / 24
-
-			if dayDiff <= 1 { // Same day or consecutive days
-				if currentStreak == 0 {
-					currentStreak = 2
-					currentStreakStart = lastDate
-				} else {
-					currentStreak++
-				}
-			} else if dayDiff > 1 {
-				// Break in streak
-				if currentStreak > longestStreak {
-					longestStreak = currentStreak
-				}
-				currentStreak = 0
-			}
-			lastDate = commitDate
-		}
-
-		// Update final streak counts
-		if currentStreak > longestStreak {
-			longestStreak = currentStreak
-		}
-
-		// Only count current streak if it's active (includes today)
-		if time.Since(currentStreakStart) > 24*time.Hour {
-			currentStreak = 0
-		}
-
-		stats.CurrentStreak = currentStreak
-		stats.LongestStreak = longestStreak
+	// Calculate streaks with the same day buckets and rules as the scanner
+	activity := make([]scan.Activity, len(allCommits))
+	for i, c := range allCommits {
+		activity[i] = scan.Activity{Date: c.date, Lines: c.additions + c.deletions}
 	}
+	streaks := scan.ConfiguredStreakPolicy().Calculate(activity)
+	stats.CurrentStreak = streaks.Current
+	stats.LongestStreak = streaks.Longest
+	stats.FrozenDays = streaks.FrozenDays
+	stats.TypeTrend = scan.TypeTrend(history, scan.TypeTrendWeeks)
+
+	for _, pair := range pairs {
+		stats.Pairs = append(stats.Pairs, *pair)
+	}
+	sort.Slice(stats.Pairs, func(i, j int) bool {
+		if stats.Pairs[i].Commits != stats.Pairs[j].Commits {
+			return stats.Pairs[i].Commits > stats.Pairs[j].Commits
+		}
+		return stats.Pairs[i].Partner < stats.Pairs[j].Partner
+	})
 
 	// Convert map to slice and sort by activity
 	for _, activity := range repoActivities {
@@ -245,6 +293,62 @@ func calculateAuthorStats(author string) AuthorStats {
 	return stats
 }
 
+// recordPairing credits a commit to the people the author paired with on it:
+// the committer when the author was only a co-author, otherwise every
+// co-author who is not the author
+func recordPairing(pairs map[string]*PairActivity, matcher *scan.IdentityMatcher, commit scan.CommitHistory, coAuthored bool) {
+	credit := func(identity string, youDrove bool) {
+		name, email := scan.ParseIdentity(identity)
+		key := strings.ToLower(email)
+		if key == "" {
+			key = strings.ToLower(name)
+		}
+		pair := pairs[key]
+		if pair == nil {
+			pair = &PairActivity{Partner: name}
+			if name == "" {
+				pair.Partner = email
+			}
+			pairs[key] = pair
+		}
+		pair.Commits++
+		if youDrove {
+			pair.YouDrove++
+		} else {
+			pair.TheyDrove++
+		}
+		if commit.Date.After(pair.LastCommit) {
+			pair.LastCommit = commit.Date
+		}
+	}
+
+	if coAuthored {
+		driver := commit.Identity
+		if driver == "" {
+			driver = commit.Author
+		}
+		credit(driver, false)
+		return
+	}
+	for _, coAuthor := range scan.CoAuthors(commit) {
+		if !matcher.MatchesIdentity(coAuthor) {
+			credit(coAuthor, true)
+		}
+	}
+}
+
+// formatFreezes describes the streak freezes used to keep a streak alive
+func formatFreezes(frozenDays []string) string {
+	if len(frozenDays) == 0 {
+		return ""
+	}
+	indicator := config.AppConfig.DisplayStats.ActivityIndicators.StreakFreeze
+	if len(frozenDays) == 1 {
+		return fmt.Sprintf(" (%s freeze used %s)", indicator, frozenDays[0])
+	}
+	return fmt.Sprintf(" (%s %d freezes used, last %s)", indicator, len(frozenDays), frozenDays[len(frozenDays)-1])
+}
+
 func displayAuthorStats(stats AuthorStats) {
 	// Get terminal width for table sizing
 	width := getTerminalWidth()
@@ -267,7 +371,7 @@ func displayAuthorStats(stats AuthorStats) {
 	} else if stats.CurrentStreak >= 7 {
 		streakEmoji = config.AppConfig.DisplayStats.ActivityIndicators.HighActivity
 	}
-	t.AppendRow(table.Row{streakEmoji, "Current Streak", fmt.Sprintf("%d days", stats.CurrentStreak)})
+	t.AppendRow(table.Row{streakEmoji, "Current Streak", fmt.Sprintf("%d days%s", stats.CurrentStreak, formatFreezes(stats.FrozenDays))})
 
 	// Format longest streak with trophy if it's the current record
 	streakSuffix := ""
@@ -285,7 +389,8 @@ func displayAuthorStats(stats AuthorStats) {
 	}
 	t.AppendRow(table.Row{activityEmoji, "Weekly Activity", fmt.Sprintf("%d commits", stats.WeeklyCommits)})
 	t.AppendRow(table.Row{"📅", "Monthly Activity", fmt.Sprintf("%d commits", stats.MonthlyCommits)})
-	t.AppendRow(table.Row{"⚡", "Code Changes", fmt.Sprintf("+%d/-%d lines", stats.TotalAdditions, stats.TotalDeletions)})
+	t.AppendRow(table.Row{"⚡", "Code Changes", formatLineChanges(stats.TotalAdditions, stats.TotalDeletions,
+		stats.RawAdditions, stats.RawDeletions, stats.Outliers)})
 	t.AppendRow(table.Row{"⏰", "Peak Coding Hour", fmt.Sprintf("%02d:00-%02d:00 (%d commits)",
 		stats.PeakHour, (stats.PeakHour+1)%24, stats.PeakCommits)})
 
@@ -327,11 +432,84 @@ func displayAuthorStats(stats AuthorStats) {
 		fmt.Println()
 	}
 
-	// Display language statistics
+	// Display who the author pairs with
+	if len(stats.Pairs) > 0 {
+		t = table.NewWriter()
+		t.SetStyle(getAuthorTableStyle())
+		t.SetAllowedRowLength(width - 4)
+
+		if config.AppConfig.DisplayStats.TableStyle.UseTableHeader {
+			t.AppendHeader(table.Row{"Partner", "Commits", "You Drove", "They Drove", "Last Paired"})
+		}
+		for i, pair := range stats.Pairs {
+			if i >= maxPairs {
+				break
+			}
+			t.AppendRow(table.Row{
+				pair.Partner,
+				fmt.Sprintf("%d", pair.Commits),
+				fmt.Sprintf("%d", pair.YouDrove),
+				fmt.Sprintf("%d", pair.TheyDrove),
+				formatAuthorLastActivity(pair.LastCommit),
+			})
+		}
+
+		tableStr = t.Render()
+		tableWidth = getTableWidth(tableStr)
+		fmt.Println(headerStyle.Render(centerText("👥 Pairing", tableWidth)))
+		fmt.Println(tableStr)
+		if stats.CoAuthored > 0 && !config.AppConfig.CoAuthorSettings.CountTowardStreaks {
+			fmt.Printf("%d co-authored commits are not counted toward streaks\n", stats.CoAuthored)
+		}
+		fmt.Println()
+	}
+
+	// Display the conventional commit breakdown
+	if hasConventionalCommits(stats.CommitTypes) {
+		t = table.NewWriter()
+		t.SetStyle(getAuthorTableStyle())
+		t.SetAllowedRowLength(width - 4)
+
+		if config.AppConfig.DisplayStats.TableStyle.UseTableHeader {
+			t.AppendHeader(table.Row{"Type", "Commits", "Share"})
+		}
+		for _, commitType := range scan.SortedTypes(stats.CommitTypes) {
+			name := commitType
+			if name == "" {
+				name = "other"
+			}
+			count := stats.CommitTypes[commitType]
+			t.AppendRow(table.Row{
+				name,
+				fmt.Sprintf("%d", count),
+				fmt.Sprintf("%.0f%%", float64(count)/float64(stats.TotalCommits)*100),
+			})
+		}
+		if stats.Breaking > 0 {
+			t.AppendFooter(table.Row{"breaking", fmt.Sprintf("%d", stats.Breaking), ""})
+		}
+
+		tableStr = t.Render()
+		tableWidth = getTableWidth(tableStr)
+		fmt.Println(headerStyle.Render(centerText("🏷️  Commit Types", tableWidth)))
+		fmt.Println(tableStr)
+		fmt.Printf("🐛 Fix:Feat Ratio: %s\n", formatFixFeatTrend(stats.TypeTrend))
+		fmt.Println()
+	}
+
+	// Display the languages written next to the repositories' composition
+	topCount := config.AppConfig.DisplayStats.InsightSettings.TopLanguagesCount
+	if len(stats.Written) > 0 {
+		langStr := formatLanguages(stats.Written, topCount)
+		langWidth := getTableWidth(langStr)
+		fmt.Println(headerStyle.Render(centerText("✍️  Languages Written", langWidth)))
+		fmt.Println(langStr)
+		fmt.Println()
+	}
 	if len(stats.Languages) > 0 {
-		langStr := formatLanguages(stats.Languages, config.AppConfig.DisplayStats.InsightSettings.TopLanguagesCount)
+		langStr := formatLanguages(stats.Languages, topCount)
 		langWidth := getTableWidth(langStr)
-		fmt.Println(headerStyle.Render(centerText("💻 Language Distribution", langWidth)))
+		fmt.Println(headerStyle.Render(centerText("💻 Repository Languages", langWidth)))
 		fmt.Println(langStr)
 	}
 }
@@ -43,12 +43,14 @@ This is useful when you want to refresh the data without waiting for the automat
 		}
 		
 		cacheFilePath := getCacheFilePath()
-		err := cache.RefreshCache(
+		err := cache.RefreshCacheWithProgress(
+			cmd.Context(),
 			config.AppConfig.ScanDirectories,
 			config.AppConfig.Author,
 			cacheFilePath,
 			config.AppConfig.ScanSettings.ExcludedPatterns,
 			config.AppConfig.ScanSettings.ExcludedPaths,
+			ScanProgressLine(),
 		)
 		if err != nil {
 			fmt.Printf("Error reloading cache: %v\n", err)
@@ -16,6 +16,8 @@ import (
 	"github.com/AccursedGalaxy/streakode/cmd/search"
 	"github.com/AccursedGalaxy/streakode/config"
 	"github.com/AccursedGalaxy/streakode/scan"
+	"github.com/AccursedGalaxy/streakode/scan/gitcmd"
+	"github.com/AccursedGalaxy/streakode/scan/gitobj"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"golang.org/x/term"
@@ -30,7 +32,9 @@ type HistoryOptions struct {
 	Preview     bool
 	Format      string
 	Branch      string
-	Query       string // Search query for filtering commits
+	Query       string   // Search query for filtering commits
+	Types       []string // Conventional commit types to keep, e.g. "feat", "fix"
+	Scope       string   // Conventional commit scope to keep
 }
 
 type CommitSummary struct {
@@ -56,7 +60,7 @@ type FileResult struct {
 }
 
 // DisplayHistory is the main entry point for the history command
-func DisplayHistory(opts HistoryOptions) {
+func DisplayHistory(ctx context.Context, opts HistoryOptions) {
 	// Always use interactive mode with preview by default
 	opts.Interactive = true
 	if !opts.Preview {
@@ -68,13 +72,13 @@ func DisplayHistory(opts HistoryOptions) {
 	doneChan := make(chan bool)
 
 	// Start loading commits in background
-	go loadCommitsProgressively(opts, commitChan, doneChan)
+	go loadCommitsProgressively(ctx, opts, commitChan, doneChan)
 
 	// Start interactive search immediately
 	displayInteractiveHistoryProgressive(commitChan, doneChan, opts)
 }
 
-func loadCommitsProgressively(opts HistoryOptions, commitChan chan<- CommitSummary, doneChan chan<- bool) {
+func loadCommitsProgressively(ctx context.Context, opts HistoryOptions, commitChan chan<- CommitSummary, doneChan chan<- bool) {
 	var wg sync.WaitGroup
 	since := time.Now().AddDate(0, 0, -opts.Days)
 
@@ -84,7 +88,7 @@ func loadCommitsProgressively(opts HistoryOptions, commitChan chan<- CommitSumma
 	// Skip cache for file searches
 	if opts.Format != "files" {
 		// Get commits from cache
-		cachedCommits := getCachedCommits(opts, since)
+		cachedCommits := filterCommitsByOptions(getCachedCommits(opts, since), opts)
 		for _, commit := range cachedCommits {
 			commitChan <- commit
 		}
@@ -104,7 +108,7 @@ func loadCommitsProgressively(opts HistoryOptions, commitChan chan<- CommitSumma
 			defer func() { <-sem }() // Release semaphore
 
 			repoName := extractRepoName(repoPath)
-			localCommits := getLocalCommitsOptimized(repoPath, opts, since)
+			localCommits := getLocalCommitsOptimized(ctx, repoPath, opts, since)
 
 			// Filter commits based on command context
 			filteredCommits := filterCommitsByOptions(localCommits, opts)
@@ -118,12 +122,12 @@ func loadCommitsProgressively(opts HistoryOptions, commitChan chan<- CommitSumma
 			}
 
 			// Only fetch remote data if needed and not too many local commits
-			if len(localCommits) < 100 && shouldFetchRemote(repoPath) {
+			if len(localCommits) < 100 && shouldFetchRemote(ctx, repoPath) {
 				wg.Add(1)
 				go func() {
 					defer wg.Done()
-					fetchRemoteData(repoPath)
-					remoteCommits := getRemoteCommitsOptimized(repoPath, opts, since)
+					fetchRemoteData(ctx, repoPath)
+					remoteCommits := getRemoteCommitsOptimized(ctx, repoPath, opts, since)
 
 					// Filter remote commits based on command context
 					filteredRemote := filterCommitsByOptions(remoteCommits, opts)
@@ -161,10 +165,15 @@ func filterCommitsByOptions(commits []CommitSummary, opts HistoryOptions) []Comm
 		return commits
 	}
 
+	var authors *scan.IdentityMatcher
+	if opts.Author != "" {
+		authors = authorMatcher(opts.Author)
+	}
+
 	filtered := make([]CommitSummary, 0, len(commits))
 	for _, commit := range commits {
 		// Apply author filter if specified
-		if opts.Author != "" && !strings.Contains(commit.Author, opts.Author) {
+		if authors != nil && !authors.MatchesIdentity(commit.Author) {
 			continue
 		}
 
@@ -173,6 +182,11 @@ func filterCommitsByOptions(commits []CommitSummary, opts HistoryOptions) []Comm
 			continue
 		}
 
+		// Apply conventional commit type and scope filters
+		if !matchesConventional(commit.Message, opts) {
+			continue
+		}
+
 		// Apply file pattern filter if specified (for files command)
 		if opts.Query != "" && opts.Format == "files" {
 			hasMatchingFile := false
@@ -207,6 +221,27 @@ func filterCommitsByOptions(commits []CommitSummary, opts HistoryOptions) []Comm
 	return filtered
 }
 
+// matchesConventional reports whether a commit subject has one of the
+// requested conventional types and the requested scope
+func matchesConventional(subject string, opts HistoryOptions) bool {
+	if len(opts.Types) == 0 && opts.Scope == "" {
+		return true
+	}
+	message := scan.ParseCommitMessage(subject)
+	if opts.Scope != "" && !strings.EqualFold(message.Scope, opts.Scope) {
+		return false
+	}
+	if len(opts.Types) == 0 {
+		return true
+	}
+	for _, commitType := range opts.Types {
+		if strings.EqualFold(message.Type, commitType) {
+			return true
+		}
+	}
+	return false
+}
+
 func getCachedCommits(opts HistoryOptions, since time.Time) []CommitSummary {
 	var commits []CommitSummary
 	cache.Cache.Range(func(path string, repo scan.RepoMetadata) bool {
@@ -225,6 +260,7 @@ func getCachedCommits(opts HistoryOptions, since time.Time) []CommitSummary {
 					Additions:  ch.Additions,
 					Deletions:  ch.Deletions,
 					Repository: repoName,
+					Author:     commitIdentity(ch),
 				})
 			}
 		}
@@ -233,7 +269,7 @@ func getCachedCommits(opts HistoryOptions, since time.Time) []CommitSummary {
 	return commits
 }
 
-func getLocalCommitsOptimized(repoPath string, opts HistoryOptions, since time.Time) []CommitSummary {
+func getLocalCommitsOptimized(ctx context.Context, repoPath string, opts HistoryOptions, since time.Time) []CommitSummary {
 	// For file searches, we want to show files and their contents
 	if opts.Format == "files" {
 		var commits []CommitSummary
@@ -241,15 +277,13 @@ func getLocalCommitsOptimized(repoPath string, opts HistoryOptions, since time.T
 
 		// Get all commits in time range
 		args := []string{
-			"-C", repoPath,
 			"log",
 			"--no-merges",
 			"--format=%H", // Just get commit hashes
 			"--after=" + since.Format("2006-01-02"),
 		}
 
-		cmd := exec.Command("git", args...)
-		output, err := cmd.Output()
+		output, err := gitcmd.Output(ctx, repoPath, args...)
 		if err != nil {
 			return nil
 		}
@@ -263,15 +297,13 @@ func getLocalCommitsOptimized(repoPath string, opts HistoryOptions, since time.T
 
 			// Get files changed in this commit
 			filesArgs := []string{
-				"-C", repoPath,
 				"diff-tree",
 				"--no-commit-id",
 				"--name-only",
 				"-r",
 				hash,
 			}
-			filesCmd := exec.Command("git", filesArgs...)
-			filesOutput, err := filesCmd.Output()
+			filesOutput, err := gitcmd.Output(ctx, repoPath, filesArgs...)
 			if err != nil {
 				continue
 			}
@@ -284,26 +316,22 @@ func getLocalCommitsOptimized(repoPath string, opts HistoryOptions, since time.T
 
 				// Get file content at this commit
 				contentArgs := []string{
-					"-C", repoPath,
 					"show",
 					hash + ":" + file,
 				}
-				contentCmd := exec.Command("git", contentArgs...)
-				content, err := contentCmd.Output()
+				content, err := gitcmd.Output(ctx, repoPath, contentArgs...)
 				if err != nil {
 					continue
 				}
 
 				// Get commit info
 				infoArgs := []string{
-					"-C", repoPath,
 					"show",
-					"--format=%H%n%aI%n%an%n%ae%n%s",
+					"--format=%H%n%aI%n%aN%n%aE%n%s",
 					"-s",
 					hash,
 				}
-				infoCmd := exec.Command("git", infoArgs...)
-				info, err := infoCmd.Output()
+				info, err := gitcmd.Output(ctx, repoPath, infoArgs...)
 				if err != nil {
 					continue
 				}
@@ -321,18 +349,15 @@ func getLocalCommitsOptimized(repoPath string, opts HistoryOptions, since time.T
 
 	// For other modes, use the existing commit history logic
 	args := []string{
-		"-C", repoPath,
 		"log",
 		"--no-merges",
 		"--name-only",
-		"--format=%H%n%aI%n%an%n%ae%n%s%n%x00",
+		"--format=%H%n%aI%n%aN%n%aE%n%s%n%x00",
 		"--after=" + since.Format("2006-01-02"),
 		"--max-count=1000",
 	}
 
-	if opts.Author != "" {
-		args = append(args, "--author="+opts.Author)
-	}
+	args = append(args, gitAuthorArgs(opts.Author)...)
 
 	if opts.Branch != "" {
 		args = append(args, opts.Branch)
@@ -340,8 +365,7 @@ func getLocalCommitsOptimized(repoPath string, opts HistoryOptions, since time.T
 		args = append(args, "--all")
 	}
 
-	cmd := exec.CommandContext(context.Background(), "git", args...)
-	output, err := cmd.Output()
+	output, err := gitcmd.Output(ctx, repoPath, args...)
 	if err != nil {
 		if config.AppConfig.Debug {
 			fmt.Printf("Error getting local commits from %s: %v\n", repoPath, err)
@@ -458,12 +482,11 @@ func parseGitLogWithPatch(output string, opts HistoryOptions) []CommitSummary {
 	return commits
 }
 
-func getRemoteCommitsOptimized(repoPath string, opts HistoryOptions, since time.Time) []CommitSummary {
+func getRemoteCommitsOptimized(ctx context.Context, repoPath string, opts HistoryOptions, since time.Time) []CommitSummary {
 	var commits []CommitSummary
 
 	// Get remote branches efficiently
-	cmd := exec.Command("git", "-C", repoPath, "for-each-ref", "--format=%(refname)", "refs/remotes/origin")
-	output, err := cmd.Output()
+	output, err := gitcmd.Output(ctx, repoPath, "for-each-ref", "--format=%(refname)", "refs/remotes/origin")
 	if err != nil {
 		return commits
 	}
@@ -486,24 +509,21 @@ func getRemoteCommitsOptimized(repoPath string, opts HistoryOptions, since time.
 			sem <- struct{}{}        // Acquire semaphore
 			defer func() { <-sem }() // Release semaphore
 
-			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 			defer cancel()
 
 			args := []string{
-				"-C", repoPath,
 				"log",
 				"--no-merges",
 				"--patch",                              // Show the actual changes
 				"--unified=3",                          // Show 3 lines of context
-				"--format=%H%n%aI%n%an%n%ae%n%s%n%x00", // Use newlines and null byte as separators
+				"--format=%H%n%aI%n%aN%n%aE%n%s%n%x00", // Use newlines and null byte as separators
 				branchName,
 				"--after=" + since.Format("2006-01-02"),
 				"--max-count=500", // Limit per branch
 			}
 
-			if opts.Author != "" {
-				args = append(args, "--author="+opts.Author)
-			}
+			args = append(args, gitAuthorArgs(opts.Author)...)
 
 			// Add file filter if in files mode
 			if opts.Format == "files" && opts.Query != "" {
@@ -514,8 +534,7 @@ func getRemoteCommitsOptimized(repoPath string, opts HistoryOptions, since time.
 				}
 			}
 
-			cmd := exec.CommandContext(ctx, "git", args...)
-			output, err := cmd.Output()
+			output, err := gitcmd.Output(ctx, repoPath, args...)
 			if err != nil {
 				return
 			}
@@ -609,16 +628,19 @@ func getDetailLevelForFormat(format string) int {
 	}
 }
 
-func shouldFetchRemote(repoPath string) bool {
+func shouldFetchRemote(ctx context.Context, repoPath string) bool {
 	// Check if repo has a remote
-	cmd := exec.Command("git", "-C", repoPath, "remote")
-	if output, err := cmd.Output(); err != nil || len(output) == 0 {
+	if output, err := gitcmd.Output(ctx, repoPath, "remote"); err != nil || len(output) == 0 {
 		return false
 	}
 
-	// Check last fetch time
-	lastFetchFile := filepath.Join(repoPath, ".git", "FETCH_HEAD")
-	info, err := os.Stat(lastFetchFile)
+	// Check last fetch time; worktrees and submodules keep their git
+	// directory elsewhere
+	gitDir, err := gitobj.FindGitDir(repoPath)
+	if err != nil {
+		return true
+	}
+	info, err := os.Stat(filepath.Join(gitDir, "FETCH_HEAD"))
 	if err != nil {
 		return true // No fetch record, should fetch
 	}
@@ -627,14 +649,18 @@ func shouldFetchRemote(repoPath string) bool {
 	return time.Since(info.ModTime()) > 15*time.Minute
 }
 
-func fetchRemoteData(repoPath string) {
+// remoteFetchTimeout bounds fetching remote data for the history view
+const remoteFetchTimeout = 30 * time.Second
+
+func fetchRemoteData(ctx context.Context, repoPath string) {
 	if config.AppConfig.Debug {
 		fmt.Printf("Fetching remote data for %s\n", repoPath)
 	}
 
-	// Fetch all branches and tags
-	cmd := exec.Command("git", "-C", repoPath, "fetch", "--all", "--tags", "--force", "--quiet")
-	cmd.Run() // Ignore errors, we'll work with what we have
+	// Fetch all branches and tags, giving up on unreachable remotes
+	ctx, cancel := context.WithTimeout(ctx, remoteFetchTimeout)
+	defer cancel()
+	gitcmd.Run(ctx, repoPath, "fetch", "--all", "--tags", "--force", "--quiet") // Ignore errors, we'll work with what we have
 }
 
 func extractRepoName(path string) string {
@@ -753,6 +779,23 @@ func matchesAuthor(author string) bool {
 	return config.AppConfig.Author == author
 }
 
+// gitAuthorArgs lets git pre-filter the log by author when a single pattern
+// is enough. Several identities are matched afterwards by filterCommitsByOptions.
+func gitAuthorArgs(author string) []string {
+	if author == "" || authorMatcher(author).HasIdentities() {
+		return nil
+	}
+	return []string{"--author=" + author}
+}
+
+// commitIdentity returns the canonical "Name <email>" of a cached commit
+func commitIdentity(commit scan.CommitHistory) string {
+	if commit.Identity != "" {
+		return commit.Identity
+	}
+	return commit.Author
+}
+
 func sortCommitsByDate(commits []CommitSummary) {
 	sort.Slice(commits, func(i, j int) bool {
 		return commits[i].Date.After(commits[j].Date)
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/AccursedGalaxy/streakode/config"
+	"github.com/AccursedGalaxy/streakode/scan"
+	"golang.org/x/term"
+)
+
+// progressInterval limits how often the progress line is redrawn
+const progressInterval = 50 * time.Millisecond
+
+// ScanProgressLine returns a progress callback that keeps a single status
+// line updated while repositories are scanned. It returns nil when output is
+// not a terminal or debug output would interleave with it.
+func ScanProgressLine() scan.ProgressFunc {
+	if config.AppConfig.Debug || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return nil
+	}
+
+	var lastDraw time.Time
+	return func(p scan.ScanProgress) {
+		if !p.Done && time.Since(lastDraw) < progressInterval {
+			return
+		}
+		lastDraw = time.Now()
+
+		// \033[K clears whatever remains of a longer previous line
+		fmt.Printf("\r\033[K🔍 Scanning repositories: %d found, %d scanned, %d skipped",
+			p.Found, p.Scanned, p.Skipped)
+		if p.Done {
+			fmt.Println()
+		}
+	}
+}
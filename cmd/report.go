@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AccursedGalaxy/streakode/cache"
+	"github.com/AccursedGalaxy/streakode/config"
+	"github.com/AccursedGalaxy/streakode/daemon"
+	"github.com/AccursedGalaxy/streakode/scan"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// statusLabels decorate report statuses for the table view
+var statusLabels = map[scan.RepoStatus]string{
+	scan.StatusScanned:  "✅ scanned",
+	scan.StatusSkipped:  "💤 skipped",
+	scan.StatusExcluded: "🚫 excluded",
+	scan.StatusFailed:   "⚠️  failed",
+}
+
+// DisplayScanReport shows the outcome of the last full scan, as a table or
+// as JSON for scripts. It returns how many repositories failed, or -1 when
+// no scan has been recorded.
+func DisplayScanReport(asJSON bool) int {
+	report := cache.Cache.GetScanReport()
+	if report == nil {
+		if asJSON {
+			fmt.Println("null")
+		} else {
+			fmt.Println("No scan recorded yet. Try running 'cache reload' first.")
+		}
+		return -1
+	}
+	failed := report.Count(scan.StatusFailed)
+
+	if asJSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Printf("Error encoding report: %v\n", err)
+			return failed
+		}
+		fmt.Println(string(data))
+		return failed
+	}
+
+	fmt.Printf("🔍 Last scan %s, took %s\n",
+		report.Started.Local().Format("2006-01-02 15:04"),
+		report.Duration.Round(time.Millisecond))
+	fmt.Printf("   %d discovered, %d scanned, %d skipped, %d excluded, %d failed\n",
+		len(report.Repos),
+		report.Count(scan.StatusScanned),
+		report.Count(scan.StatusSkipped),
+		report.Count(scan.StatusExcluded),
+		failed)
+	if len(report.Repos) == 0 {
+		return failed
+	}
+
+	t := table.NewWriter()
+	t.SetStyle(getAuthorTableStyle())
+	if config.AppConfig.DisplayStats.TableStyle.UseTableHeader {
+		t.AppendHeader(table.Row{"Repository", "Kind", "Status", "Time", "Reason"})
+	}
+	for _, repo := range report.Repos {
+		duration := ""
+		if repo.Duration > 0 {
+			duration = repo.Duration.Round(time.Millisecond).String()
+		}
+		reason := repo.Reason
+		if repo.MovedFrom != "" {
+			reason = strings.TrimPrefix(reason+"; moved from "+repo.MovedFrom, "; ")
+		}
+		t.AppendRow(table.Row{repo.Path, string(repo.Kind), statusLabels[repo.Status], duration, reason})
+	}
+	fmt.Println(t.Render())
+	return failed
+}
+
+// DisplayRefreshStatus shows when the cache was last refreshed, whether a
+// refresh is running and how the last background refresh went
+func DisplayRefreshStatus(cacheFilePath string) {
+	status, err := cache.GetBackgroundStatus(cacheFilePath)
+	if err != nil {
+		fmt.Printf("Error reading refresh status: %v\n", err)
+	}
+
+	if lastSync := cache.Cache.GetLastSync(); lastSync.IsZero() {
+		fmt.Println("📭 The cache has not been refreshed yet")
+	} else {
+		fmt.Printf("✅ Refreshed %s (%s)\n", formatAgo(lastSync), lastSync.Local().Format("2006-01-02 15:04"))
+	}
+
+	switch {
+	case status != nil && status.Running && status.Finished.IsZero():
+		fmt.Printf("🔄 Refresh running in the background (pid %d), started %s\n", status.PID, formatAgo(status.Started))
+	case cache.RefreshRunning(cacheFilePath):
+		fmt.Println("🔄 Refresh running in another streakode process")
+	case status != nil && status.Error != "":
+		fmt.Printf("⚠️  The background refresh started %s failed: %s\n", formatAgo(status.Started), status.Error)
+	}
+	if status != nil {
+		fmt.Printf("📄 Background refresh log: %s\n", cache.LogPath(cacheFilePath))
+	}
+	if daemonStatus, err := daemon.Query(cacheFilePath); err == nil {
+		fmt.Printf("👀 Kept fresh by the streakode daemon (pid %d)\n", daemonStatus.PID)
+	}
+}
+
+// DisplayDaemonStatus shows what the daemon keeping the cache fresh is
+// doing, reporting whether one is running
+func DisplayDaemonStatus(cacheFilePath string) bool {
+	status, err := daemon.Query(cacheFilePath)
+	if err != nil {
+		fmt.Println("💤 No daemon is running, start one with 'streakode daemon'")
+		return false
+	}
+
+	fmt.Printf("👀 Daemon running (pid %d) since %s, watching %d repositories\n",
+		status.PID, status.Started.Local().Format("2006-01-02 15:04"), status.Repos)
+	if !status.LastUpdate.IsZero() {
+		fmt.Printf("✅ Cache updated %s\n", formatAgo(status.LastUpdate))
+	}
+	if status.Pending > 0 {
+		fmt.Printf("🔄 %d changed repositories waiting to be rescanned\n", status.Pending)
+	}
+	if status.LastError != "" {
+		fmt.Printf("⚠️  Last update failed: %s\n", status.LastError)
+	}
+	return true
+}
+
+// BackgroundRefreshNote describes a refresh started in the background for a
+// command showing cached data in the meantime
+func BackgroundRefreshNote() string {
+	lastSync := cache.Cache.GetLastSync()
+	if lastSync.IsZero() {
+		return "🔄 Building the cache in the background, see 'streakode cache status'"
+	}
+	return fmt.Sprintf("🔄 Refreshing the cache in the background, showing data refreshed %s", formatAgo(lastSync))
+}
+
+// formatAgo formats the time since t, e.g. "3m ago"
+func formatAgo(t time.Time) string {
+	duration := time.Since(t)
+	switch {
+	case duration < time.Minute:
+		return "just now"
+	case duration < time.Hour:
+		return fmt.Sprintf("%dm ago", int(duration.Minutes()))
+	case duration < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(duration.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(duration.Hours()/24))
+	}
+}
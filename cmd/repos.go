@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/AccursedGalaxy/streakode/config"
+	"github.com/AccursedGalaxy/streakode/scan"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// configuredExclusions builds the exclusion rules from the scan settings
+func configuredExclusions() *scan.Exclusions {
+	return scan.NewExclusions(
+		config.AppConfig.ScanDirectories,
+		config.AppConfig.ScanSettings.ExcludedPatterns,
+		config.AppConfig.ScanSettings.ExcludedPaths,
+	)
+}
+
+// DisplayRepos lists the repositories found in the scan directories and
+// whether each one is tracked
+func DisplayRepos() {
+	opts := scan.DiscoveryOptions()
+	opts.Exclusions = configuredExclusions()
+
+	t := table.NewWriter()
+	t.SetStyle(getAuthorTableStyle())
+	if config.AppConfig.DisplayStats.TableStyle.UseTableHeader {
+		t.AppendHeader(table.Row{"Repository", "Kind", "Status"})
+	}
+
+	tracked := 0
+	scan.DiscoverRepositories(config.AppConfig.ScanDirectories, opts, func(repo scan.DiscoveredRepo) {
+		status := "✅ tracked"
+		switch {
+		case repo.ExcludedBy != nil:
+			status = fmt.Sprintf("🚫 excluded by %s", repo.ExcludedBy)
+		case repo.Duplicate != "":
+			status = fmt.Sprintf("🔁 shares objects with %s", repo.Duplicate)
+		default:
+			tracked++
+		}
+		t.AppendRow(table.Row{repo.Path, string(repo.Kind), status})
+	}, func(path string, err error) {
+		t.AppendRow(table.Row{path, "", fmt.Sprintf("⚠️  unreadable: %v", err)})
+	})
+
+	fmt.Println(t.Render())
+	fmt.Printf("%d of %d repositories tracked\n", tracked, t.Length())
+}
+
+// ExplainPath reports which exclusion rule, if any, decides whether path is
+// scanned
+func ExplainPath(path string) {
+	explanation := configuredExclusions().Explain(path)
+
+	fmt.Printf("📂 %s\n", explanation.Path)
+	if explanation.Root == "" {
+		fmt.Println("⚠️  Not inside any scan directory")
+	} else {
+		fmt.Printf("   Scan directory: %s\n", explanation.Root)
+	}
+
+	switch {
+	case explanation.Excluded && explanation.Via != "":
+		fmt.Printf("🚫 Excluded: parent directory %s matches %s\n", explanation.Via, explanation.Rule)
+	case explanation.Excluded:
+		fmt.Printf("🚫 Excluded by %s\n", explanation.Rule)
+	case explanation.Rule != nil:
+		fmt.Printf("✅ Included, re-included by %s\n", explanation.Rule)
+	default:
+		fmt.Println("✅ Included, no rule matches")
+	}
+}
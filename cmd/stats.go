@@ -134,10 +134,14 @@ func DisplayStats(targetRepo string) {
 		// Add rows
 		for _, rs := range repoStats {
 			activityText := formatActivityText(rs.LastCommitTime)
+			name := rs.Name
+			if rs.Clones > 1 {
+				name = fmt.Sprintf("%s (%d clones)", rs.Name, rs.Clones)
+			}
 			t.AppendRow(table.Row{
-				rs.Name,
+				name,
 				fmt.Sprintf("%d%s", rs.WeeklyCommits, formatActivityIndicator(rs.WeeklyCommits)),
-				formatStreakString(rs.CurrentStreak, rs.LongestStreak),
+				formatStreakString(rs.CurrentStreak, rs.LongestStreak, len(rs.FrozenDays)),
 				fmt.Sprintf("+%d/-%d", rs.Additions, rs.Deletions),
 				activityText,
 			})
@@ -198,12 +202,12 @@ func DisplayStats(targetRepo string) {
 		if displayStats.WeeklyDiff < 0 {
 			trend = "↘️"
 		}
-		weeklyText := fmt.Sprintf("📈 Weekly Summary: %d commits (%s %s), +%d/-%d lines",
+		weeklyText := fmt.Sprintf("📈 Weekly Summary: %d commits (%s %s), %s",
 			displayStats.WeeklyTotal,
 			trend,
 			formatDiff(displayStats.WeeklyDiff),
-			displayStats.TotalAdditions,
-			displayStats.TotalDeletions)
+			formatLineChanges(displayStats.TotalAdditions, displayStats.TotalDeletions,
+				displayStats.RawAdditions, displayStats.RawDeletions, displayStats.Outliers))
 		sections = append(sections, weeklyText)
 
 		// Daily average
@@ -215,6 +219,31 @@ func DisplayStats(targetRepo string) {
 			langText := "💻 Top Languages:  " + formatLanguageStats(displayStats.LanguageStats)
 			sections = append(sections, langText)
 		}
+		if displayStats.CodeLines > 0 {
+			sourceText := fmt.Sprintf("🧾 Source Lines:   %.1fK code, %.1fK comments, %.1fK blank",
+				float64(displayStats.CodeLines)/1000,
+				float64(displayStats.CommentLines)/1000,
+				float64(displayStats.BlankLines)/1000)
+			sections = append(sections, sourceText)
+		}
+		if len(displayStats.WrittenStats) > 0 {
+			writtenText := "✍️  You Wrote:      " + formatLanguageStats(displayStats.WrittenStats)
+			sections = append(sections, writtenText)
+		}
+
+		// Conventional commit breakdown
+		if hasConventionalCommits(displayStats.CommitTypes) {
+			typesText := "🏷️  Commit Types:   " + formatCommitTypes(displayStats.CommitTypes, 4)
+			sections = append(sections, typesText)
+			ratioText := "🐛 Fix:Feat Ratio: " + formatFixFeatTrend(displayStats.TypeTrend)
+			sections = append(sections, ratioText)
+		}
+
+		// Copies of the same change counted once
+		if displayStats.Collapsed > 0 {
+			collapsedText := fmt.Sprintf("🧬 Deduplicated:   %d rebased or cherry-picked commits", displayStats.Collapsed)
+			sections = append(sections, collapsedText)
+		}
 
 		// Peak coding hour
 		peakText := fmt.Sprintf("⏰ Peak Coding:    %02d:00-%02d:00 (%d commits)",
@@ -291,6 +320,47 @@ func formatLanguageStats(stats map[string]int) string {
 	return strings.Join(result, "  ")
 }
 
+// hasConventionalCommits reports whether any commit followed Conventional Commits
+func hasConventionalCommits(types map[string]int) bool {
+	for commitType, count := range types {
+		if commitType != "" && count > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// formatCommitTypes lists the most common commit types, e.g.
+// "feat 12 · fix 8 · other 3"
+func formatCommitTypes(types map[string]int, topCount int) string {
+	var result []string
+	for i, commitType := range scan.SortedTypes(types) {
+		if i >= topCount && commitType != "" {
+			continue
+		}
+		name := commitType
+		if name == "" {
+			name = "other"
+		}
+		result = append(result, fmt.Sprintf("%s %d", name, types[commitType]))
+	}
+	return strings.Join(result, " · ")
+}
+
+// formatFixFeatTrend shows the fix-to-feature ratio per week, oldest first,
+// with "–" for weeks without features
+func formatFixFeatTrend(periods []scan.TypePeriod) string {
+	ratios := make([]string, 0, len(periods))
+	for _, period := range periods {
+		if ratio, ok := period.FixFeatRatio(); ok {
+			ratios = append(ratios, fmt.Sprintf("%.1f", ratio))
+		} else {
+			ratios = append(ratios, "–")
+		}
+	}
+	return fmt.Sprintf("%s (weekly, last %d weeks)", strings.Join(ratios, " → "), len(periods))
+}
+
 func getLanguageIcon(lang string) string {
 	icons := map[string]string{
 		"Go":         "🔵",
@@ -427,8 +497,9 @@ func formatActivityIndicator(weeklyCommits int) string {
 	return indicators.NormalActivity
 }
 
-// formatStreakString formats the streak display with appropriate indicators
-func formatStreakString(currentStreak, longestStreak int) string {
+// formatStreakString formats the streak display with appropriate indicators,
+// marking streaks that were kept alive by freezes
+func formatStreakString(currentStreak, longestStreak, freezesUsed int) string {
 	indicators := config.AppConfig.DisplayStats.ActivityIndicators
 	streakStr := fmt.Sprintf("%dd", currentStreak)
 
@@ -437,6 +508,9 @@ func formatStreakString(currentStreak, longestStreak int) string {
 	} else if currentStreak > 0 {
 		streakStr += indicators.ActiveStreak
 	}
+	if freezesUsed > 0 {
+		streakStr += indicators.StreakFreeze
+	}
 
 	return streakStr
 }
@@ -448,8 +522,9 @@ func calculateWeeklyChanges(commitHistory []scan.CommitHistory) (int, int) {
 
 	for _, commit := range commitHistory {
 		if commit.Date.After(weekStart) {
-			weeklyAdditions += commit.Additions
-			weeklyDeletions += commit.Deletions
+			additions, deletions := commit.CountedChanges()
+			weeklyAdditions += additions
+			weeklyDeletions += deletions
 		}
 	}
 
@@ -504,7 +579,7 @@ func buildProjectsSection(targetRepo string) string {
 		meta := repo.metadata
 
 		activity := formatActivityIndicator(meta.WeeklyCommits)
-		streakStr := formatStreakString(meta.CurrentStreak, meta.LongestStreak)
+		streakStr := formatStreakString(meta.CurrentStreak, meta.LongestStreak, len(meta.FrozenDays))
 		weeklyAdd, weeklyDel := calculateWeeklyChanges(meta.CommitHistory)
 		activityStr := formatLastActivity(repo.lastCommit)
 		changesStr := fmt.Sprintf("+%d/-%d", weeklyAdd, weeklyDel)
@@ -537,23 +612,24 @@ func buildProjectsSection(targetRepo string) string {
 }
 
 func formatLanguages(stats map[string]int, topCount int) string {
-	// Language icons mapping with more descriptive emojis
+	// Language icons mapping with more descriptive emojis, keyed by the
+	// lower-case canonical language name
 	languageIcons := map[string]string{
-		"go":      config.AppConfig.LanguageSettings.LanguageDisplay.GoDisplay,
-		"py":      config.AppConfig.LanguageSettings.LanguageDisplay.PythonDisplay,
-		"lua":     config.AppConfig.LanguageSettings.LanguageDisplay.LuaDisplay,
-		"js":      config.AppConfig.LanguageSettings.LanguageDisplay.JavaDisplay,
-		"ts":      config.AppConfig.LanguageSettings.LanguageDisplay.TypeScriptDisplay,
-		"rust":    config.AppConfig.LanguageSettings.LanguageDisplay.RustDisplay,
-		"cpp":     config.AppConfig.LanguageSettings.LanguageDisplay.CppDisplay,
-		"c":       config.AppConfig.LanguageSettings.LanguageDisplay.CDisplay,
-		"java":    config.AppConfig.LanguageSettings.LanguageDisplay.JavaDisplay,
-		"ruby":    config.AppConfig.LanguageSettings.LanguageDisplay.RubyDisplay,
-		"php":     config.AppConfig.LanguageSettings.LanguageDisplay.PHPDisplay,
-		"html":    config.AppConfig.LanguageSettings.LanguageDisplay.HTMLDisplay,
-		"css":     config.AppConfig.LanguageSettings.LanguageDisplay.CSSDisplay,
-		"shell":   config.AppConfig.LanguageSettings.LanguageDisplay.ShellDisplay,
-		"default": config.AppConfig.LanguageSettings.LanguageDisplay.DefaultDisplay,
+		"go":         config.AppConfig.LanguageSettings.LanguageDisplay.GoDisplay,
+		"python":     config.AppConfig.LanguageSettings.LanguageDisplay.PythonDisplay,
+		"lua":        config.AppConfig.LanguageSettings.LanguageDisplay.LuaDisplay,
+		"javascript": config.AppConfig.LanguageSettings.LanguageDisplay.JavaScriptDisplay,
+		"typescript": config.AppConfig.LanguageSettings.LanguageDisplay.TypeScriptDisplay,
+		"rust":       config.AppConfig.LanguageSettings.LanguageDisplay.RustDisplay,
+		"c++":        config.AppConfig.LanguageSettings.LanguageDisplay.CppDisplay,
+		"c":          config.AppConfig.LanguageSettings.LanguageDisplay.CDisplay,
+		"java":       config.AppConfig.LanguageSettings.LanguageDisplay.JavaDisplay,
+		"ruby":       config.AppConfig.LanguageSettings.LanguageDisplay.RubyDisplay,
+		"php":        config.AppConfig.LanguageSettings.LanguageDisplay.PHPDisplay,
+		"html":       config.AppConfig.LanguageSettings.LanguageDisplay.HTMLDisplay,
+		"css":        config.AppConfig.LanguageSettings.LanguageDisplay.CSSDisplay,
+		"shell":      config.AppConfig.LanguageSettings.LanguageDisplay.ShellDisplay,
+		"default":    config.AppConfig.LanguageSettings.LanguageDisplay.DefaultDisplay,
 	}
 
 	// Convert map to slice for sorting
@@ -564,8 +640,7 @@ func formatLanguages(stats map[string]int, topCount int) string {
 
 	langs := make([]langStat, 0, len(stats))
 	for lang, lines := range stats {
-		cleanLang := strings.ToLower(strings.TrimPrefix(lang, "."))
-		langs = append(langs, langStat{cleanLang, lines})
+		langs = append(langs, langStat{lang, lines})
 	}
 
 	// Sort by line count descending
@@ -586,9 +661,13 @@ func formatLanguages(stats map[string]int, topCount int) string {
 	for i := 0; i < min(len(langs), topCount); i++ {
 		if langs[i].lines > 0 {
 			// Retrieve icon or default if not found
-			icon := languageIcons[langs[i].lang]
+			icon := languageIcons[strings.ToLower(langs[i].lang)]
 			if icon == "" {
-				icon = languageIcons["default"]
+				// Keep the default icon but name the language
+				icon = langs[i].lang
+				if fields := strings.Fields(languageIcons["default"]); len(fields) > 1 {
+					icon = fields[0] + " " + langs[i].lang
+				}
 			}
 
 			// Format lines of code with appropriate unit
@@ -658,9 +737,10 @@ func calculateGlobalStats(repos map[string]scan.RepoMetadata) (int, int, int, in
 
 		for _, commit := range repo.CommitHistory {
 			if commit.Date.After(weekStart) {
-				totalAdditions += commit.Additions
-				totalDeletions += commit.Deletions
-				hourStats[commit.Date.Hour()]++
+				additions, deletions := commit.CountedChanges()
+				totalAdditions += additions
+				totalDeletions += deletions
+				hourStats[scan.LocalTime(commit.Date).Hour()]++
 			}
 		}
 	}
@@ -683,6 +763,25 @@ func findPeakCodingHour(hourStats map[int]int) (int, int) {
 	return peakHour, peakCommits
 }
 
+// formatLineChanges formats counted line changes, followed by the raw
+// numbers when change rules or outliers left lines out
+func formatLineChanges(additions, deletions, rawAdditions, rawDeletions, outliers int) string {
+	text := fmt.Sprintf("+%d/-%d lines", additions, deletions)
+	var notes []string
+	if rawAdditions != additions || rawDeletions != deletions {
+		notes = append(notes, fmt.Sprintf("raw +%d/-%d", rawAdditions, rawDeletions))
+	}
+	if outliers == 1 {
+		notes = append(notes, "1 outlier commit")
+	} else if outliers > 1 {
+		notes = append(notes, fmt.Sprintf("%d outlier commits", outliers))
+	}
+	if len(notes) > 0 {
+		text += " (" + strings.Join(notes, ", ") + ")"
+	}
+	return text
+}
+
 // formatWeeklySummary creates a formatted weekly summary string
 func formatWeeklySummary(totalWeeklyCommits int, commitTrend CommitTrend, totalAdditions, totalDeletions int) string {
 	return fmt.Sprintf("%d commits (%s %s), +%d/-%d lines",
@@ -701,6 +800,9 @@ type insightStats struct {
 	peakCommits   int
 	commitTrend   CommitTrend
 	languageStats map[string]int
+	writtenStats  map[string]int // changed lines per language in your commits
+	commitTypes   map[string]int // commits per conventional type
+	typeTrend     []scan.TypePeriod
 }
 
 // appendInsightRows adds insight rows to the table based on configuration
@@ -728,6 +830,16 @@ func appendInsightRows(t table.Writer, insights struct {
 		t.AppendRow(table.Row{"💻", "Top Languages:", langs})
 	}
 
+	if insights.ShowTopLanguages && len(stats.writtenStats) > 0 {
+		langs := formatLanguages(stats.writtenStats, insights.TopLanguagesCount)
+		t.AppendRow(table.Row{"✍️", "You Wrote:", langs})
+	}
+
+	if hasConventionalCommits(stats.commitTypes) {
+		t.AppendRow(table.Row{"🏷️", "Commit Types:", formatCommitTypes(stats.commitTypes, 4)})
+		t.AppendRow(table.Row{"🐛", "Fix:Feat Ratio:", formatFixFeatTrend(stats.typeTrend)})
+	}
+
 	if insights.ShowPeakCoding {
 		t.AppendRow(table.Row{"⏰", "Peak Coding:",
 			fmt.Sprintf("%02d:00-%02d:00 (%d commits)",
@@ -805,8 +917,13 @@ func buildInsightsSection(targetRepo string) string {
 		deletions := 0
 		hourStats := make(map[int]int)
 		languageStats := make(map[string]int)
+		writtenStats := make(map[string]int)
+		commitTypes := make(map[string]int)
+		var history []scan.CommitHistory
 
-		for _, repo := range repoCache {
+		// Clones of one project are merged so shared commits count once
+		for _, project := range scan.GroupProjects(repoCache) {
+			repo := project.Meta
 			weeklyCommits += repo.WeeklyCommits
 			lastWeeksCommits += repo.LastWeeksCommits
 
@@ -834,11 +951,19 @@ func buildInsightsSection(targetRepo string) string {
 
 			// Use pre-calculated commit stats
 			for _, commit := range repo.CommitHistory {
-				additions += commit.Additions
-				deletions += commit.Deletions
-				hour := commit.Date.Hour()
+				commitAdditions, commitDeletions := commit.CountedChanges()
+				additions += commitAdditions
+				deletions += commitDeletions
+				hour := scan.LocalTime(commit.Date).Hour()
 				hourStats[hour]++
 			}
+			for lang, lines := range scan.LanguagesWritten(repo.CommitHistory, time.Time{}) {
+				writtenStats[lang] += lines
+			}
+			for commitType, count := range scan.CommitTypes(repo.CommitHistory, time.Time{}) {
+				commitTypes[commitType] += count
+			}
+			history = append(history, repo.CommitHistory...)
 		}
 
 		peakHour, peakCommits := findPeakCodingHour(hourStats)
@@ -853,6 +978,9 @@ func buildInsightsSection(targetRepo string) string {
 			peakCommits:   peakCommits,
 			commitTrend:   commitTrend,
 			languageStats: languageStats,
+			writtenStats:  writtenStats,
+			commitTypes:   commitTypes,
+			typeTrend:     scan.TypeTrend(history, scan.TypeTrendWeeks),
 		})
 
 		return t.Render()
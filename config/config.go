@@ -6,21 +6,34 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
 	Author           string   `mapstructure:"author"`
+	Identities       []string `mapstructure:"identities"` // Other names, emails or /regexes/ you commit as
 	DormantThreshold int      `mapstructure:"dormant_threshold"`
+	Timezone         string   `mapstructure:"timezone"`          // IANA zone used to bucket commits into days, defaults to local time
+	DayRolloverHour  int      `mapstructure:"day_rollover_hour"` // Commits before this hour count toward the previous day
 	ScanDirectories  []string `mapstructure:"scan_directories"`
 	ScanSettings     struct {
 		ExcludedPatterns []string `mapstructure:"excluded_patterns"` // e.g., ["node_modules", "dist", ".git"]
 		ExcludedPaths    []string `mapstructure:"excluded_paths"`    // Full paths to exclude
+		Concurrency      int      `mapstructure:"concurrency"`       // Repositories scanned in parallel, defaults to CPU count
+		MaxDepth         int      `mapstructure:"max_depth"`         // Directory levels searched below each scan directory, 0 for no limit
+		Submodules       bool     `mapstructure:"submodules"`        // Search inside repositories for submodules and nested clones
+		DedupPatchIDs    bool     `mapstructure:"dedup_patch_ids"`   // Count rebased and cherry-picked copies of a commit once, by patch-id
+		RepoTimeout      int      `mapstructure:"repo_timeout"`      // Seconds allowed for reading one repository, 0 for no limit
 	} `mapstructure:"scan_settings"`
 	RefreshInterval int `mapstructure:"refresh_interval"`
-	DisplayStats    struct {
+	CacheSettings   struct {
+		Backend string `mapstructure:"backend"` // "gob" for one cache file, "bolt" for an embedded database with records per repository
+	} `mapstructure:"cache_settings"`
+	DisplayStats struct {
 		ShowWelcomeMessage bool `mapstructure:"show_welcome_message"`
 		ShowActiveProjects bool `mapstructure:"show_active_projects"`
 		ShowInsights       bool `mapstructure:"show_insights"`
@@ -41,6 +54,7 @@ type Config struct {
 			NoActivity     string `mapstructure:"no_activity"`
 			StreakRecord   string `mapstructure:"streak_record"`
 			ActiveStreak   string `mapstructure:"active_streak"`
+			StreakFreeze   string `mapstructure:"streak_freeze"`
 		} `mapstructure:"activity_indicators"`
 		Thresholds struct {
 			HighActivity int `mapstructure:"high_activity"`
@@ -58,10 +72,35 @@ type Config struct {
 	GoalSettings struct {
 		WeeklyCommitGoal int `mapstructure:"weekly_commit_goal"`
 	} `mapstructure:"goal_settings"`
+	StreakSettings struct {
+		WeekendsOff bool     `mapstructure:"weekends_off"` // Saturdays and Sundays neither extend nor break a streak
+		RestDays    []string `mapstructure:"rest_days"`    // Holidays and vacations, "2006-01-02" or "2006-01-02..2006-01-09"
+		FreezeEvery int      `mapstructure:"freeze_every"` // Earn a streak freeze every N active days, 0 disables freezes
+		MaxFreezes  int      `mapstructure:"max_freezes"`  // Freezes that can be saved up at once
+		MinCommits  int      `mapstructure:"min_commits"`  // Commits needed for a day to count
+		MinLines    int      `mapstructure:"min_lines"`    // Changed lines needed for a day to count, 0 disables
+	} `mapstructure:"streak_settings"`
+	ProjectSettings struct {
+		CanonicalPaths []string `mapstructure:"canonical_paths"` // Preferred locations when clones of one project are grouped, first match wins
+	} `mapstructure:"project_settings"`
+	ChangeSettings struct {
+		ExcludedFiles []string `mapstructure:"excluded_files"` // Globs of generated or vendored files whose lines are not counted, e.g. ["go.sum", "vendor/"]
+		WeightedFiles []struct {
+			Pattern string  `mapstructure:"pattern"`
+			Weight  float64 `mapstructure:"weight"` // Share of the file's changed lines counted, between 0 and 1
+		} `mapstructure:"weighted_files"`
+		OutlierFactor   float64 `mapstructure:"outlier_factor"`    // Commits changing this many times the median commit are outliers
+		OutlierMinLines int     `mapstructure:"outlier_min_lines"` // Commits changing fewer lines are never outliers
+		ExcludeOutliers bool    `mapstructure:"exclude_outliers"`  // Leave outlier commits out of line totals
+	} `mapstructure:"change_settings"`
+	CoAuthorSettings struct {
+		CountTowardStreaks bool `mapstructure:"count_toward_streaks"` // Commits crediting you in a Co-authored-by trailer count toward streaks and goals
+	} `mapstructure:"co_author_settings"`
 	Colors struct {
 		HeaderColor string `mapstructure:"header_color"`
 	}
 	DetailedStats    bool `mapstructure:"detailed_stats"`
+	HistoryDays      int  `mapstructure:"history_days"` // Days of per-commit history kept for detailed stats, -1 keeps all history
 	Debug            bool `mapstructure:"debug"`
 	LanguageSettings struct {
 		ExcludedExtensions []string `mapstructure:"excluded_extensions"` // e.g., [".yaml", ".txt", ".md"]
@@ -115,9 +154,51 @@ func (c *Config) ValidateConfig() error {
 	if len(c.ScanDirectories) == 0 {
 		return fmt.Errorf("at least one scan directory must be specified")
 	}
+	if c.Timezone != "" && c.Timezone != "Local" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone %q: %v", c.Timezone, err)
+		}
+	}
+	if c.DayRolloverHour < 0 || c.DayRolloverHour > 23 {
+		return fmt.Errorf("day_rollover_hour must be between 0 and 23")
+	}
+	if c.ScanSettings.MaxDepth < 0 {
+		return fmt.Errorf("scan_settings.max_depth cannot be negative")
+	}
+	if c.ScanSettings.RepoTimeout < 0 {
+		return fmt.Errorf("scan_settings.repo_timeout cannot be negative")
+	}
+	for _, weighted := range c.ChangeSettings.WeightedFiles {
+		if strings.TrimSpace(weighted.Pattern) == "" {
+			return fmt.Errorf("change_settings.weighted_files entries need a pattern")
+		}
+		if weighted.Weight < 0 || weighted.Weight > 1 {
+			return fmt.Errorf("change_settings.weighted_files weight for %q must be between 0 and 1", weighted.Pattern)
+		}
+	}
+	if c.ChangeSettings.OutlierFactor < 0 || c.ChangeSettings.OutlierMinLines < 0 {
+		return fmt.Errorf("change_settings outlier values cannot be negative")
+	}
+	if c.HistoryDays < -1 {
+		return fmt.Errorf("history_days must be -1 (all history) or a number of days")
+	}
+	if c.StreakSettings.FreezeEvery < 0 || c.StreakSettings.MaxFreezes < 0 ||
+		c.StreakSettings.MinCommits < 0 || c.StreakSettings.MinLines < 0 {
+		return fmt.Errorf("streak_settings values cannot be negative")
+	}
+	for _, day := range c.StreakSettings.RestDays {
+		if _, _, err := ParseDayRange(day); err != nil {
+			return fmt.Errorf("invalid streak_settings.rest_days entry %q: %v", day, err)
+		}
+	}
 	if c.RefreshInterval <= 0 {
 		return fmt.Errorf("refresh_interval must be greater than 0")
 	}
+	switch c.CacheSettings.Backend {
+	case "", "gob", "bolt":
+	default:
+		return fmt.Errorf("cache_settings.backend must be \"gob\" or \"bolt\"")
+	}
 	if c.DisplayStats.MaxProjects <= 0 {
 		return fmt.Errorf("display_stats.max_projects must be greater than 0")
 	}
@@ -138,6 +219,14 @@ func (c *Config) ValidateConfig() error {
 		c.Colors.HeaderColor = "#FF69B4"
 	}
 
+	// Flag commits changing twenty times the median, and at least 5000 lines
+	if c.ChangeSettings.OutlierFactor == 0 {
+		c.ChangeSettings.OutlierFactor = 20
+	}
+	if c.ChangeSettings.OutlierMinLines == 0 {
+		c.ChangeSettings.OutlierMinLines = 5000
+	}
+
 	// Set default activity indicators if not specified
 	if c.DisplayStats.ActivityIndicators.HighActivity == "" {
 		c.DisplayStats.ActivityIndicators.HighActivity = "🔥"
@@ -154,6 +243,9 @@ func (c *Config) ValidateConfig() error {
 	if c.DisplayStats.ActivityIndicators.ActiveStreak == "" {
 		c.DisplayStats.ActivityIndicators.ActiveStreak = "🔥"
 	}
+	if c.DisplayStats.ActivityIndicators.StreakFreeze == "" {
+		c.DisplayStats.ActivityIndicators.StreakFreeze = "🧊"
+	}
 
 	// Validate thresholds
 	if c.DisplayStats.Thresholds.HighActivity <= 0 {
@@ -327,6 +419,21 @@ func setDefaults() {
 		AppConfig.RefreshInterval = 60 // 60 minutes default
 	}
 
+	// Keep the cache in one file unless configured otherwise
+	if AppConfig.CacheSettings.Backend == "" {
+		AppConfig.CacheSettings.Backend = "gob"
+	}
+
+	// Keep 30 days of detailed history unless configured otherwise
+	if AppConfig.HistoryDays == 0 {
+		AppConfig.HistoryDays = 30
+	}
+
+	// Scan one repository per CPU unless configured otherwise
+	if AppConfig.ScanSettings.Concurrency <= 0 {
+		AppConfig.ScanSettings.Concurrency = runtime.NumCPU()
+	}
+
 	// Set default activity indicators if not specified
 	if AppConfig.DisplayStats.ActivityIndicators.HighActivity == "" {
 		AppConfig.DisplayStats.ActivityIndicators.HighActivity = "🔥"
@@ -343,6 +450,17 @@ func setDefaults() {
 	if AppConfig.DisplayStats.ActivityIndicators.ActiveStreak == "" {
 		AppConfig.DisplayStats.ActivityIndicators.ActiveStreak = "🔥"
 	}
+	if AppConfig.DisplayStats.ActivityIndicators.StreakFreeze == "" {
+		AppConfig.DisplayStats.ActivityIndicators.StreakFreeze = "🧊"
+	}
+
+	// A day counts toward a streak with a single commit by default
+	if AppConfig.StreakSettings.MinCommits <= 0 {
+		AppConfig.StreakSettings.MinCommits = 1
+	}
+	if AppConfig.StreakSettings.FreezeEvery > 0 && AppConfig.StreakSettings.MaxFreezes <= 0 {
+		AppConfig.StreakSettings.MaxFreezes = 2
+	}
 
 	// Set default thresholds
 	if AppConfig.DisplayStats.Thresholds.HighActivity <= 0 {
@@ -369,3 +487,24 @@ func setDefaults() {
 		AppConfig.DisplayStats.MaxProjects = 10
 	}
 }
+
+// ParseDayRange parses a "2006-01-02" day or an inclusive
+// "2006-01-02..2006-01-09" range
+func ParseDayRange(value string) (time.Time, time.Time, error) {
+	startText, endText, isRange := strings.Cut(strings.TrimSpace(value), "..")
+	start, err := time.Parse("2006-01-02", strings.TrimSpace(startText))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if !isRange {
+		return start, start, nil
+	}
+	end, err := time.Parse("2006-01-02", strings.TrimSpace(endText))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("range ends before it starts")
+	}
+	return start, end, nil
+}
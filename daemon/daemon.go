@@ -0,0 +1,490 @@
+// Package daemon keeps the cache fresh from a long running process. It
+// watches the refs of every tracked repository and rescans only those that
+// change, picks up repositories cloned into the scan directories, and
+// reports its status over a unix socket next to the cache.
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AccursedGalaxy/streakode/cache"
+	"github.com/AccursedGalaxy/streakode/config"
+	"github.com/AccursedGalaxy/streakode/scan"
+	"github.com/AccursedGalaxy/streakode/scan/gitobj"
+	"github.com/fsnotify/fsnotify"
+)
+
+// settleDelay is how long changes must stop before repositories are
+// rescanned, so a rebase or a fetch of many branches is one rescan
+const settleDelay = 2 * time.Second
+
+// Daemon watches repositories and updates the cache when they change
+type Daemon struct {
+	cachePath    string
+	dirs         []string
+	exclusions   *scan.Exclusions
+	settle       time.Duration
+	fullInterval time.Duration // between full scans catching what watching missed, 0 for none
+
+	// Cache operations, replaced in tests
+	rescan   func(ctx context.Context, repos []string) error // incremental refresh of repositories
+	discover func(ctx context.Context) error                 // full scan of the scan directories
+	tracked  func() []string                                 // repositories in the cache
+
+	watcher  *fsnotify.Watcher
+	repos    map[string]bool     // watched repositories, true once the cache tracks them
+	gitDirs  map[string][]string // git directory -> repositories whose HEAD or packed-refs it holds
+	refsDirs map[string][]string // directory below refs -> repositories whose refs it holds
+	areas    map[string]bool     // directories new repositories may be cloned into
+
+	changed  map[string]bool // tracked repositories waiting to be rescanned
+	newRepos bool            // a repository appeared or changed before being tracked
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New returns a daemon for the cache at cachePath, watching the
+// repositories and scan directories of the loaded configuration
+func New(cachePath string) *Daemon {
+	dirs := make([]string, len(config.AppConfig.ScanDirectories))
+	for i, dir := range config.AppConfig.ScanDirectories {
+		dirs[i] = filepath.Clean(dir)
+	}
+	excludedPatterns := config.AppConfig.ScanSettings.ExcludedPatterns
+	excludedPaths := config.AppConfig.ScanSettings.ExcludedPaths
+
+	return &Daemon{
+		cachePath:    cachePath,
+		dirs:         dirs,
+		exclusions:   scan.NewExclusions(dirs, excludedPatterns, excludedPaths),
+		settle:       settleDelay,
+		fullInterval: time.Duration(config.AppConfig.RefreshInterval) * time.Minute,
+		rescan:       cache.RefreshRepos,
+		discover: func(ctx context.Context) error {
+			// Start from what other processes saved since the last scan
+			if err := cache.LoadCache(cachePath); err != nil {
+				return err
+			}
+			return cache.RefreshCache(ctx, dirs, config.AppConfig.Author, cachePath, excludedPatterns, excludedPaths)
+		},
+		tracked: trackedRepos,
+	}
+}
+
+// trackedRepos lists the repositories in the cache
+func trackedRepos() []string {
+	var repos []string
+	cache.Cache.Range(func(repoPath string, _ scan.RepoMetadata) bool {
+		repos = append(repos, repoPath)
+		return true
+	})
+	return repos
+}
+
+// Run scans the scan directories, then watches for changes until ctx is
+// cancelled. Only one daemon runs per cache.
+func (d *Daemon) Run(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start watching repositories: %v", err)
+	}
+	defer watcher.Close()
+	d.watcher = watcher
+	d.repos = make(map[string]bool)
+	d.gitDirs = make(map[string][]string)
+	d.refsDirs = make(map[string][]string)
+	d.areas = make(map[string]bool)
+	d.changed = make(map[string]bool)
+
+	listener, err := listen(d.cachePath)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	d.updateStatus(func(s *Status) {
+		s.PID = os.Getpid()
+		s.Started = time.Now()
+	})
+	go d.serve(listener)
+	d.logf("👀 Daemon started (pid %d), status on %s", os.Getpid(), SocketPath(d.cachePath))
+
+	// Catch up on what changed while no daemon was running
+	d.newRepos = true
+	d.update(ctx)
+
+	var full <-chan time.Time
+	if d.fullInterval > 0 {
+		ticker := time.NewTicker(d.fullInterval)
+		defer ticker.Stop()
+		full = ticker.C
+	}
+	var settled <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			d.logf("👋 Daemon stopped")
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if d.handle(event) {
+				settled = time.After(d.settle)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			d.logf("⚠️  Watching failed: %v", err)
+			d.updateStatus(func(s *Status) { s.LastError = err.Error() })
+			// Events were lost, so only a full scan is sure to catch up
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				d.newRepos = true
+				settled = time.After(d.settle)
+			}
+		case <-settled:
+			settled = nil
+			d.update(ctx)
+		case <-full:
+			d.newRepos = true
+			d.update(ctx)
+		}
+	}
+}
+
+// handle records what an event changed and reports whether an update is due
+func (d *Daemon) handle(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	dir, name := filepath.Dir(event.Name), filepath.Base(event.Name)
+	// Git writes refs to a lock file, then renames it into place
+	if strings.HasSuffix(name, ".lock") {
+		return false
+	}
+
+	due := false
+	if repos, ok := d.refsDirs[dir]; ok {
+		// New namespaces of branches, e.g. refs/heads/feature/
+		if event.Has(fsnotify.Create) && isDir(event.Name) {
+			d.watchRefs(event.Name, repos)
+		}
+		due = d.repoChanged(repos)
+	}
+	if repos, ok := d.gitDirs[dir]; ok && (name == "HEAD" || name == "packed-refs") {
+		due = d.repoChanged(repos) || due
+	}
+	if d.areas[dir] && event.Has(fsnotify.Create) {
+		due = d.created(event.Name) || due
+	}
+	return due
+}
+
+// repoChanged queues watched repositories for an update
+func (d *Daemon) repoChanged(repos []string) bool {
+	for _, repo := range repos {
+		if d.repos[repo] {
+			d.changed[repo] = true
+		} else {
+			d.newRepos = true
+		}
+	}
+	d.updateStatus(func(s *Status) { s.Pending = len(d.changed) })
+	return true
+}
+
+// created handles an entry created in a directory new repositories may be
+// cloned into, reporting whether it is a new repository
+func (d *Daemon) created(path string) bool {
+	if filepath.Base(path) == gitDirName {
+		repo := filepath.Dir(path)
+		d.removeArea(repo)
+		return d.watchNew(repo)
+	}
+	if !isDir(path) || d.exclusions.Explain(path).Excluded {
+		return false
+	}
+	if isRepo(path) {
+		return d.watchNew(path)
+	}
+
+	// Clones and directories holding them appear empty first
+	d.addArea(path)
+	// The clone may have created its .git before the watch was added
+	if isRepo(path) {
+		d.removeArea(path)
+		return d.watchNew(path)
+	}
+	return false
+}
+
+// watchNew watches a repository the cache does not track yet, so the full
+// scan it triggers is repeated when the clone finishes
+func (d *Daemon) watchNew(repo string) bool {
+	if _, ok := d.repos[repo]; ok {
+		return false
+	}
+	if err := d.watchRepo(repo); err != nil {
+		d.debugf("Watching new repository %s failed: %v", repo, err)
+	}
+	d.logf("📦 New repository %s", repo)
+	d.newRepos = true
+	return true
+}
+
+// update runs the scans changes are waiting for: a full scan when
+// repositories appeared, otherwise a rescan of the repositories that changed
+func (d *Daemon) update(ctx context.Context) {
+	start := time.Now()
+	var err error
+	switch {
+	case d.newRepos:
+		d.newRepos = false
+		d.changed = make(map[string]bool)
+		d.updateStatus(func(s *Status) { s.Pending = 0 })
+		if err = d.discover(ctx); err == nil {
+			d.logf("🔍 Scanned all repositories in %s", time.Since(start).Round(time.Millisecond))
+		}
+	case len(d.changed) > 0:
+		repos := make([]string, 0, len(d.changed))
+		for repo := range d.changed {
+			repos = append(repos, repo)
+		}
+		sort.Strings(repos)
+		d.changed = make(map[string]bool)
+		d.updateStatus(func(s *Status) { s.Pending = 0 })
+		if err = d.rescan(ctx, repos); err == nil {
+			d.logRescans()
+		}
+	default:
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	if err != nil {
+		d.logf("⚠️  Updating the cache failed: %v", err)
+	}
+	d.updateStatus(func(s *Status) {
+		if err != nil {
+			s.LastError = err.Error()
+			return
+		}
+		s.LastUpdate = time.Now()
+		s.LastError = ""
+	})
+	d.syncWatches()
+}
+
+// logRescans logs the repositories the last rescan updated
+func (d *Daemon) logRescans() {
+	updates := cache.Notifications()
+	for {
+		select {
+		case update := <-updates:
+			if update.Type == cache.UpdateRescan {
+				d.logf("🔄 %s: %d new commits", filepath.Base(update.RepoID), update.Changes)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// syncWatches watches the repositories the cache tracks and the
+// directories around them, and stops watching those it dropped or never
+// picked up. Repositories without branches yet, like clones still
+// fetching, stay watched until they have some.
+func (d *Daemon) syncWatches() {
+	tracked := make(map[string]bool)
+	for _, repo := range d.tracked() {
+		tracked[repo] = true
+	}
+	for repo := range d.repos {
+		if !tracked[repo] && !noBranches(repo) {
+			d.unwatchRepo(repo)
+		}
+	}
+
+	for repo := range tracked {
+		if _, ok := d.repos[repo]; !ok {
+			if err := d.watchRepo(repo); err != nil {
+				d.debugf("Watching %s failed: %v", repo, err)
+				continue
+			}
+		}
+		d.repos[repo] = true
+		d.addParentAreas(repo)
+	}
+	for _, dir := range d.dirs {
+		d.addArea(dir)
+	}
+
+	d.updateStatus(func(s *Status) { s.Repos = len(d.repos) })
+}
+
+// watchRepo watches HEAD, packed-refs and the refs of a repository
+func (d *Daemon) watchRepo(repo string) error {
+	gitDir, err := gitobj.FindGitDir(repo)
+	if err != nil {
+		return err
+	}
+	d.repos[repo] = false
+
+	// Linked worktrees keep their HEAD apart from the shared refs
+	if err := d.watch(d.gitDirs, gitDir, []string{repo}); err != nil {
+		return err
+	}
+	common := gitobj.CommonDir(gitDir)
+	if common != gitDir {
+		if err := d.watch(d.gitDirs, common, []string{repo}); err != nil {
+			return err
+		}
+	}
+	d.watchRefs(filepath.Join(common, "refs"), []string{repo})
+	return nil
+}
+
+// watchRefs watches root and every directory below it
+func (d *Daemon) watchRefs(root string, repos []string) {
+	filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		if err := d.watch(d.refsDirs, path, repos); err != nil {
+			d.debugf("Watching %s failed: %v", path, err)
+		}
+		return nil
+	})
+}
+
+// watch adds dir to the watcher and records the repositories it belongs to
+func (d *Daemon) watch(table map[string][]string, dir string, repos []string) error {
+	if _, ok := table[dir]; !ok {
+		if err := d.watcher.Add(dir); err != nil {
+			return err
+		}
+	}
+	for _, repo := range repos {
+		if !slices.Contains(table[dir], repo) {
+			table[dir] = append(table[dir], repo)
+		}
+	}
+	return nil
+}
+
+// unwatchRepo stops watching the directories of a repository no other
+// watched repository shares
+func (d *Daemon) unwatchRepo(repo string) {
+	delete(d.repos, repo)
+	delete(d.changed, repo)
+	for _, table := range []map[string][]string{d.gitDirs, d.refsDirs} {
+		for dir, repos := range table {
+			repos = slices.DeleteFunc(repos, func(r string) bool { return r == repo })
+			if len(repos) > 0 {
+				table[dir] = repos
+				continue
+			}
+			delete(table, dir)
+			d.watcher.Remove(dir)
+		}
+	}
+}
+
+// addParentAreas watches the directories between a repository and the
+// scan directory holding it, where its siblings are cloned
+func (d *Daemon) addParentAreas(repo string) {
+	for dir := filepath.Dir(repo); d.inScanDirs(dir); dir = filepath.Dir(dir) {
+		d.addArea(dir)
+		if slices.Contains(d.dirs, dir) || dir == filepath.Dir(dir) {
+			return
+		}
+	}
+}
+
+// addArea watches a directory for new repositories, unless it is one
+func (d *Daemon) addArea(dir string) {
+	if d.areas[dir] || isRepo(dir) {
+		return
+	}
+	if err := d.watcher.Add(dir); err != nil {
+		d.debugf("Watching %s failed: %v", dir, err)
+		return
+	}
+	d.areas[dir] = true
+}
+
+// removeArea stops watching a directory that turned out to be a repository
+func (d *Daemon) removeArea(dir string) {
+	if d.areas[dir] && !slices.Contains(d.dirs, dir) {
+		delete(d.areas, dir)
+		d.watcher.Remove(dir)
+	}
+}
+
+// inScanDirs reports whether path is a scan directory or lies below one
+func (d *Daemon) inScanDirs(path string) bool {
+	for _, dir := range d.dirs {
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// gitDirName is the entry marking a working tree, a directory or a gitdir
+// file for worktrees and submodules
+const gitDirName = ".git"
+
+func isRepo(dir string) bool {
+	_, err := os.Lstat(filepath.Join(dir, gitDirName))
+	return err == nil
+}
+
+// noBranches reports whether a repository has no branches yet
+func noBranches(repo string) bool {
+	gitDir, err := gitobj.FindGitDir(repo)
+	if err != nil {
+		return false
+	}
+	common := gitobj.CommonDir(gitDir)
+	if _, err := os.Stat(filepath.Join(common, "packed-refs")); err == nil {
+		return false
+	}
+	entries, err := os.ReadDir(filepath.Join(common, "refs", "heads"))
+	return err == nil && len(entries) == 0
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func (d *Daemon) updateStatus(update func(*Status)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	update(&d.status)
+}
+
+func (d *Daemon) logf(format string, args ...interface{}) {
+	fmt.Printf("%s %s\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, args...))
+}
+
+func (d *Daemon) debugf(format string, args ...interface{}) {
+	if config.AppConfig.Debug {
+		fmt.Printf("Debug: "+format+"\n", args...)
+	}
+}
@@ -0,0 +1,144 @@
+package daemon
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/AccursedGalaxy/streakode/scan"
+)
+
+// gitRun runs a git command inside dir
+func gitRun(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Test User",
+		"GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test User",
+		"GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, output)
+	}
+}
+
+func TestDaemonWatchesRepositories(t *testing.T) {
+	root := t.TempDir()
+	scanDir := filepath.Join(root, "src")
+	tracked := filepath.Join(scanDir, "work", "tool")
+	idle := filepath.Join(scanDir, "work", "idle")
+	for _, repo := range []string{tracked, idle} {
+		if err := os.MkdirAll(repo, 0755); err != nil {
+			t.Fatal(err)
+		}
+		gitRun(t, repo, "init", "-q", "-b", "main")
+		gitRun(t, repo, "commit", "-q", "--allow-empty", "-m", "initial")
+	}
+
+	cachePath := filepath.Join(root, "cache")
+	rescans := make(chan []string, 10)
+	discoveries := make(chan bool, 10)
+	d := &Daemon{
+		cachePath:  cachePath,
+		dirs:       []string{scanDir},
+		exclusions: scan.NewExclusions([]string{scanDir}, nil, nil),
+		settle:     50 * time.Millisecond,
+		rescan: func(ctx context.Context, repos []string) error {
+			rescans <- repos
+			return nil
+		},
+		discover: func(ctx context.Context) error {
+			discoveries <- true
+			return nil
+		},
+		tracked: func() []string { return []string{tracked, idle} },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- d.Run(ctx) }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run() = %v", err)
+		}
+	}()
+
+	select {
+	case <-discoveries:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no scan at startup")
+	}
+
+	// The status is served once the startup scan has set up the watches
+	var status *Status
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if status, _ = Query(cachePath); status != nil && status.Repos == 2 {
+			break
+		}
+	}
+	if status == nil || status.PID != os.Getpid() || status.Repos != 2 {
+		t.Fatalf("Query() = %+v, want pid %d watching 2 repositories", status, os.Getpid())
+	}
+	if _, err := listen(cachePath); err == nil {
+		t.Error("listen() succeeded while a daemon is running")
+	}
+
+	// A commit rescans only its repository
+	gitRun(t, tracked, "commit", "-q", "--allow-empty", "-m", "second")
+	select {
+	case repos := <-rescans:
+		if !reflect.DeepEqual(repos, []string{tracked}) {
+			t.Errorf("rescanned %v, want %v", repos, []string{tracked})
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("commit did not trigger a rescan")
+	}
+
+	// So does a new branch in a namespace of its own
+	gitRun(t, idle, "branch", "feature/watch")
+	select {
+	case repos := <-rescans:
+		if !reflect.DeepEqual(repos, []string{idle}) {
+			t.Errorf("rescanned %v, want %v", repos, []string{idle})
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("new branch did not trigger a rescan")
+	}
+
+	// A clone next to a tracked repository triggers a full scan
+	gitRun(t, scanDir, "clone", "-q", tracked, filepath.Join(scanDir, "work", "clone"))
+	select {
+	case <-discoveries:
+	case <-time.After(5 * time.Second):
+		t.Fatal("new clone did not trigger a scan")
+	}
+	select {
+	case repos := <-rescans:
+		t.Errorf("rescanned %v, want only a full scan", repos)
+	default:
+	}
+}
+
+func TestQueryWithoutDaemon(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "cache")
+	if status, err := Query(cachePath); err == nil {
+		t.Errorf("Query() = %+v without a daemon", status)
+	}
+
+	// A socket left by a daemon that is gone is taken over
+	if err := os.WriteFile(SocketPath(cachePath), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := listen(cachePath)
+	if err != nil {
+		t.Fatalf("listen() over a stale socket: %v", err)
+	}
+	listener.Close()
+}
@@ -0,0 +1,88 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// socketSuffix is appended to the cache path to name the daemon's socket
+const socketSuffix = ".sock"
+
+// queryTimeout bounds how long a command waits for the daemon to answer
+const queryTimeout = 500 * time.Millisecond
+
+// Status describes a running daemon, as served on its socket
+type Status struct {
+	PID        int       `json:"pid"`
+	Started    time.Time `json:"started"`
+	Repos      int       `json:"repos"`       // repositories watched
+	Pending    int       `json:"pending"`     // changed repositories waiting to be rescanned
+	LastUpdate time.Time `json:"last_update"` // when the daemon last updated the cache
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// SocketPath returns the socket of the daemon keeping the cache at
+// cachePath
+func SocketPath(cachePath string) string {
+	return cachePath + socketSuffix
+}
+
+// Query asks the daemon keeping the cache at cachePath for its status. It
+// fails when no daemon is running.
+func Query(cachePath string) (*Status, error) {
+	conn, err := net.DialTimeout("unix", SocketPath(cachePath), queryTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("daemon is not running: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(queryTimeout))
+
+	var status Status
+	if err := json.NewDecoder(conn).Decode(&status); err != nil {
+		return nil, fmt.Errorf("failed to read daemon status: %v", err)
+	}
+	return &status, nil
+}
+
+// listen opens the socket, taking over one left behind by a daemon that
+// is gone
+func listen(cachePath string) (net.Listener, error) {
+	if status, err := Query(cachePath); err == nil {
+		return nil, fmt.Errorf("a daemon is already running (pid %d)", status.PID)
+	}
+	path := SocketPath(cachePath)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale daemon socket: %v", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open daemon socket: %v", err)
+	}
+	return listener, nil
+}
+
+// serve answers every connection with the current status until the
+// listener is closed
+func (d *Daemon) serve(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			conn.SetWriteDeadline(time.Now().Add(queryTimeout))
+
+			d.mu.Lock()
+			status := d.status
+			d.mu.Unlock()
+			if err := json.NewEncoder(conn).Encode(status); err != nil {
+				d.debugf("Answering status query failed: %v", err)
+			}
+		}()
+	}
+}
@@ -1,14 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 
 	"github.com/AccursedGalaxy/streakode/cache"
 	"github.com/AccursedGalaxy/streakode/cmd"
 	"github.com/AccursedGalaxy/streakode/config"
+	"github.com/AccursedGalaxy/streakode/daemon"
+	"github.com/AccursedGalaxy/streakode/scan"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -35,7 +41,19 @@ func getCacheFilePath(profile string) string {
 	return filepath.Join(home, fmt.Sprintf(".streakode_%s.cache", profile))
 }
 
-func ensureCacheRefresh() error {
+// withInterrupt returns a context cancelled by the first Ctrl-C, so that a
+// scan stops cleanly and saves what it completed. A second Ctrl-C exits
+// right away.
+func withInterrupt(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+func ensureCacheRefresh(ctx context.Context) error {
 	// Skip if no refresh interval is configured
 	if config.AppConfig.RefreshInterval <= 0 {
 		return nil
@@ -47,9 +65,20 @@ func ensureCacheRefresh() error {
 	if cache.QuickNeedsRefresh(interval) {
 		cacheFilePath := getCacheFilePath(config.AppState.ActiveProfile)
 
+		// A running daemon keeps the cache fresh on its own
+		if status, err := daemon.Query(cacheFilePath); err == nil {
+			if config.AppConfig.Debug {
+				fmt.Printf("Debug: Daemon (pid %d) keeps the cache fresh, skipping refresh\n", status.PID)
+			}
+			return nil
+		}
+
 		// For commands that need fresh data, use sync refresh
 		if requiresFreshData() {
+			ctx, stop := withInterrupt(ctx)
+			defer stop()
 			return cache.RefreshCache(
+				ctx,
 				config.AppConfig.ScanDirectories,
 				config.AppConfig.Author,
 				cacheFilePath,
@@ -58,18 +87,28 @@ func ensureCacheRefresh() error {
 			)
 		}
 
-		// For other commands, use async refresh
-		cache.AsyncRefreshCache(
-			config.AppConfig.ScanDirectories,
-			config.AppConfig.Author,
-			cacheFilePath,
-			config.AppConfig.ScanSettings.ExcludedPatterns,
-			config.AppConfig.ScanSettings.ExcludedPaths,
-		)
+		// For other commands, refresh in a process of its own that outlives
+		// this one. The note goes to stderr to keep --json output clean.
+		if err := cache.SpawnRefresh(cacheFilePath); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, cmd.BackgroundRefreshNote())
 	}
 	return nil
 }
 
+// managesCache reports whether c refreshes or removes the cache itself, or
+// reports on refreshes: the daemon and the cache subcommands but report
+func managesCache(c *cobra.Command) bool {
+	if c.Name() == "daemon" || c.HasParent() && c.Parent().Name() == "daemon" {
+		return true
+	}
+	if c.Parent() == nil || c.Parent().Name() != "cache" {
+		return false
+	}
+	return c.Name() != "report"
+}
+
 func requiresFreshData() bool {
 	// Get the command being executed
 	cmd := os.Args[1]
@@ -83,6 +122,13 @@ func requiresFreshData() bool {
 	return freshDataCommands[cmd]
 }
 
+// applyCommitFilters copies the conventional commit filters of the history
+// commands into opts
+func applyCommitFilters(cobraCmd *cobra.Command, opts *cmd.HistoryOptions) {
+	opts.Types, _ = cobraCmd.Flags().GetStringSlice("type")
+	opts.Scope, _ = cobraCmd.Flags().GetString("scope")
+}
+
 func main() {
 	var (
 		profile string
@@ -111,9 +157,16 @@ func main() {
 			cache.InitCache()
 			if err := cache.LoadCache(cacheFilePath); err != nil {
 				fmt.Printf("Error loading cache: %v\n", err)
+				if errors.Is(err, cache.ErrNewerCache) {
+					// Scanning would be wasted: the result can't be saved
+					return
+				}
 			}
 
-			if err := ensureCacheRefresh(); err != nil {
+			if managesCache(cmd) {
+				return
+			}
+			if err := ensureCacheRefresh(cmd.Context()); err != nil {
 				fmt.Printf("Error refreshing cache: %v\n", err)
 			}
 		},
@@ -158,15 +211,24 @@ Example:
 				fmt.Println("Debug: Starting cache reload...")
 			}
 			cacheFilePath := getCacheFilePath(profile)
-			err := cache.RefreshCache(
+			ctx, stop := withInterrupt(cobraCmd.Context())
+			defer stop()
+			err := cache.RefreshCacheWithProgress(
+				ctx,
 				config.AppConfig.ScanDirectories,
 				config.AppConfig.Author,
 				cacheFilePath,
 				config.AppConfig.ScanSettings.ExcludedPatterns,
 				config.AppConfig.ScanSettings.ExcludedPaths,
+				cmd.ScanProgressLine(),
 			)
 			if err == nil {
 				fmt.Println("✨ Cache reloaded successfully!")
+				if report := cache.Cache.GetScanReport(); report != nil && report.Count(scan.StatusFailed) > 0 {
+					fmt.Printf("⚠️  %d repositories could not be read, see 'streakode cache report'\n", report.Count(scan.StatusFailed))
+				}
+			} else if ctx.Err() != nil {
+				fmt.Println("⏹️  Reload interrupted, repositories scanned so far were saved")
 			} else {
 				fmt.Printf("Error reloading cache: %v\n", err)
 			}
@@ -189,9 +251,109 @@ Example:
 		},
 	}
 
+	reportCmd := &cobra.Command{
+		Use:   "report",
+		Short: "Show the outcome of the last scan",
+		Long: `Show every repository the last 'cache reload' discovered and whether it was
+scanned, skipped, excluded or failed, with the reason and time taken.
+
+Exits with status 1 when any repository failed, so scripts can check for
+broken repositories; --json prints the report in machine readable form.`,
+		Example: `  streakode cache report          # Table of the last scan
+  streakode cache report --json   # Same, as JSON`,
+		Args: cobra.NoArgs,
+		Run: func(cobraCmd *cobra.Command, args []string) {
+			asJSON, _ := cobraCmd.Flags().GetBool("json")
+			if failed := cmd.DisplayScanReport(asJSON); failed != 0 {
+				os.Exit(1)
+			}
+		},
+	}
+	reportCmd.Flags().Bool("json", false, "Print the report as JSON")
+
+	refreshCmd := &cobra.Command{
+		Use:   "refresh",
+		Short: "Refresh the streakode cache in the background",
+		Long: `Start refreshing the cache in a background process and return right away.
+Its output goes to a log next to the cache; 'streakode cache status' shows
+whether it is still running.
+
+Commands other than 'stats' do this on their own once the cache is older than
+refresh_interval, and show the cached data in the meantime.`,
+		Args: cobra.NoArgs,
+		Run: func(cobraCmd *cobra.Command, args []string) {
+			cacheFilePath := getCacheFilePath(config.AppState.ActiveProfile)
+			if background, _ := cobraCmd.Flags().GetBool("background"); background {
+				ctx, stop := withInterrupt(cobraCmd.Context())
+				defer stop()
+				if err := cache.RunBackgroundRefresh(ctx, cacheFilePath); err != nil {
+					os.Exit(1)
+				}
+				return
+			}
+			if err := cache.SpawnRefresh(cacheFilePath); err != nil {
+				fmt.Printf("Error starting refresh: %v\n", err)
+				return
+			}
+			fmt.Println("🔄 Refreshing the cache in the background, see 'streakode cache status'")
+		},
+	}
+	refreshCmd.Flags().Bool("background", false, "Refresh in this process, as the background process started by streakode")
+	refreshCmd.Flags().MarkHidden("background")
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show when the cache was refreshed and whether a refresh is running",
+		Args:  cobra.NoArgs,
+		Run: func(cobraCmd *cobra.Command, args []string) {
+			cmd.DisplayRefreshStatus(getCacheFilePath(config.AppState.ActiveProfile))
+		},
+	}
+
 	// Add subcommands to cache command
 	cacheCmd.AddCommand(reloadCmd)
 	cacheCmd.AddCommand(cleanCmd)
+	cacheCmd.AddCommand(reportCmd)
+	cacheCmd.AddCommand(refreshCmd)
+	cacheCmd.AddCommand(statusCmd)
+
+	daemonCmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Keep the cache fresh by watching repositories for changes",
+		Long: `Run in the foreground, watching HEAD, the refs and packed-refs of every
+tracked repository and rescanning only the repositories that change. New
+clones in the scan directories are picked up as they appear, and a full scan
+every refresh_interval minutes catches anything watching missed.
+
+While the daemon runs, other commands use the cache as it is instead of
+refreshing it. Its status is served on a unix socket next to the cache.`,
+		Example: `  streakode daemon          # Watch until interrupted
+  streakode daemon status   # Show what a running daemon is doing`,
+		Args: cobra.NoArgs,
+		Run: func(cobraCmd *cobra.Command, args []string) {
+			ctx, stop := withInterrupt(cobraCmd.Context())
+			defer stop()
+			if err := daemon.New(getCacheFilePath(config.AppState.ActiveProfile)).Run(ctx); err != nil {
+				fmt.Printf("Error running daemon: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	daemonStatusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show whether the daemon is running and what it is watching",
+		Long: `Show whether a daemon keeps the cache fresh, how many repositories it
+watches and when it last updated the cache. Exits with status 1 when no
+daemon is running.`,
+		Args: cobra.NoArgs,
+		Run: func(cobraCmd *cobra.Command, args []string) {
+			if !cmd.DisplayDaemonStatus(getCacheFilePath(config.AppState.ActiveProfile)) {
+				os.Exit(1)
+			}
+		},
+	}
+	daemonCmd.AddCommand(daemonStatusCmd)
 
 	profileCmd := &cobra.Command{
 		Use:   "profile [name]",
@@ -258,7 +420,10 @@ Example:
 			cacheFilePath := getCacheFilePath(newProfile)
 			cache.InitCache()
 			cache.LoadCache(cacheFilePath)
+			ctx, stop := withInterrupt(cmd.Context())
+			defer stop()
 			cache.RefreshCache(
+				ctx,
 				config.AppConfig.ScanDirectories,
 				config.AppConfig.Author,
 				cacheFilePath,
@@ -292,7 +457,7 @@ Example:
 			if len(args) > 0 {
 				targetAuthor = args[0]
 			}
-			cmd.DisplayAuthorInfo(targetAuthor)
+			cmd.DisplayAuthorInfo(cobraCmd.Context(), targetAuthor)
 		},
 	}
 
@@ -307,7 +472,9 @@ Press '?' while searching to see keyboard shortcuts.`,
 		Example: `  sk history                  # Show commits from last 7 days
   sk history --days 30        # Show last 30 days
   sk history author robin     # Show commits by author
-  sk history repo myproject   # Show commits in repository`,
+  sk history repo myproject   # Show commits in repository
+  sk history --type fix       # Show fix commits
+  sk history -t feat -s api   # Show features scoped to api`,
 		Run:     func(cobraCmd *cobra.Command, args []string) {
 			var opts cmd.HistoryOptions
 			days, _ := cobraCmd.Flags().GetInt("days")
@@ -317,13 +484,16 @@ Press '?' while searching to see keyboard shortcuts.`,
 			if days == 0 {
 				opts.Days = 7
 			}
-			cmd.DisplayHistory(opts)
+			applyCommitFilters(cobraCmd, &opts)
+			cmd.DisplayHistory(cobraCmd.Context(), opts)
 		},
 	}
 
 	// Add persistent flags that will be inherited by all subcommands
 	historyCmd.PersistentFlags().IntP("days", "n", 7, "Number of days to show history for")
 	historyCmd.PersistentFlags().StringP("format", "f", "default", "Output format (default, detailed, compact)")
+	historyCmd.PersistentFlags().StringSliceP("type", "t", nil, "Only show conventional commits of these types (e.g. feat,fix)")
+	historyCmd.PersistentFlags().StringP("scope", "s", "", "Only show conventional commits with this scope")
 
 	// Add subcommands with cleaner help text
 	historyAuthorCmd := &cobra.Command{
@@ -342,7 +512,8 @@ Press '?' while searching to see keyboard shortcuts.`,
 			if days == 0 {
 				opts.Days = 14
 			}
-			cmd.DisplayHistory(opts)
+			applyCommitFilters(cobraCmd, &opts)
+			cmd.DisplayHistory(cobraCmd.Context(), opts)
 		},
 	}
 
@@ -362,7 +533,8 @@ Press '?' while searching to see keyboard shortcuts.`,
 			if days == 0 {
 				opts.Days = 14
 			}
-			cmd.DisplayHistory(opts)
+			applyCommitFilters(cobraCmd, &opts)
+			cmd.DisplayHistory(cobraCmd.Context(), opts)
 		},
 	}
 
@@ -373,7 +545,8 @@ Press '?' while searching to see keyboard shortcuts.`,
 			var opts cmd.HistoryOptions
 			opts.Days = 1
 			opts.Format = "detailed"
-			cmd.DisplayHistory(opts)
+			applyCommitFilters(cobraCmd, &opts)
+			cmd.DisplayHistory(cobraCmd.Context(), opts)
 		},
 	}
 
@@ -393,7 +566,8 @@ Press '?' while searching to see keyboard shortcuts.`,
 			if days == 0 {
 				opts.Days = 7
 			}
-			cmd.DisplayHistory(opts)
+			applyCommitFilters(cobraCmd, &opts)
+			cmd.DisplayHistory(cobraCmd.Context(), opts)
 		},
 	}
 
@@ -408,9 +582,31 @@ Press '?' while searching to see keyboard shortcuts.`,
 			if days == 0 {
 				opts.Days = 30
 			}
-			cmd.DisplayHistory(opts)
+			applyCommitFilters(cobraCmd, &opts)
+			cmd.DisplayHistory(cobraCmd.Context(), opts)
+		},
+	}
+
+	reposCmd := &cobra.Command{
+		Use:   "repos",
+		Short: "List discovered repositories and why any are excluded",
+		Long: `List the repositories found in the scan directories.
+
+Exclusions follow .gitignore rules: excluded_patterns and excluded_paths from
+the config, plus .streakodeignore files anywhere under a scan directory.`,
+		Example: `  streakode repos                          # List repositories and their status
+  streakode repos --explain ~/code/build   # Show the rule deciding a path`,
+		Args: cobra.NoArgs,
+		Run: func(cobraCmd *cobra.Command, args []string) {
+			explain, _ := cobraCmd.Flags().GetString("explain")
+			if explain != "" {
+				cmd.ExplainPath(explain)
+				return
+			}
+			cmd.DisplayRepos()
 		},
 	}
+	reposCmd.Flags().String("explain", "", "Report which rule includes or excludes a path")
 
 	// Add subcommands to history command
 	historyCmd.AddCommand(historyAuthorCmd)
@@ -422,9 +618,11 @@ Press '?' while searching to see keyboard shortcuts.`,
 	// Add all commands to root
 	rootCmd.AddCommand(statsCmd)
 	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(daemonCmd)
 	rootCmd.AddCommand(profileCmd)
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(authorCmd)
 	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(reposCmd)
 	rootCmd.Execute()
 }
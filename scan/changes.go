@@ -0,0 +1,164 @@
+package scan
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/AccursedGalaxy/streakode/config"
+)
+
+// changeRule scales the changed lines of the files it matches
+type changeRule struct {
+	rule   IgnoreRule
+	weight float64
+}
+
+// changeFilter decides how much each file's changed lines count toward line
+// totals. Rules follow .gitignore semantics relative to the repository root
+// and the last matching rule wins.
+type changeFilter struct {
+	rules []changeRule
+}
+
+// configuredChangeFilter builds the filter from change_settings, nil when no
+// rules are configured
+func configuredChangeFilter() *changeFilter {
+	settings := config.AppConfig.ChangeSettings
+	filter := &changeFilter{}
+	for _, pattern := range settings.ExcludedFiles {
+		filter.add(pattern, 0)
+	}
+	for _, weighted := range settings.WeightedFiles {
+		filter.add(weighted.Pattern, weighted.Weight)
+	}
+	if len(filter.rules) == 0 {
+		return nil
+	}
+	return filter
+}
+
+func (f *changeFilter) add(pattern string, weight float64) {
+	rule, ok := parseIgnoreRule(pattern)
+	if !ok {
+		return
+	}
+	rule.Source = "change_settings"
+	f.rules = append(f.rules, changeRule{rule: rule, weight: weight})
+}
+
+// weight returns the share of a file's changed lines that counts. A rule
+// matching one of the file's directories applies to the file too.
+func (f *changeFilter) weight(file string) float64 {
+	weight := 1.0
+	if f == nil {
+		return weight
+	}
+	for _, r := range f.rules {
+		if !r.matchesFile(file) {
+			continue
+		}
+		if r.rule.negate {
+			weight = 1
+		} else {
+			weight = r.weight
+		}
+	}
+	return weight
+}
+
+func (r changeRule) matchesFile(file string) bool {
+	if r.rule.matches(file, false) {
+		return true
+	}
+	for dir := path.Dir(file); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if r.rule.matches(dir, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// key identifies the rules, so scans made under different rules are told
+// apart
+func (f *changeFilter) key() string {
+	if f == nil {
+		return ""
+	}
+	parts := make([]string, len(f.rules))
+	for i, r := range f.rules {
+		parts[i] = fmt.Sprintf("%s=%g", r.rule.Pattern, r.weight)
+	}
+	return strings.Join(parts, ",")
+}
+
+// filterChanges applies the filter to the commit's per-file changes. The
+// unfiltered totals are kept as RawAdditions and RawDeletions.
+func (e *logEntry) filterChanges(f *changeFilter) {
+	e.RawAdditions, e.RawDeletions = e.Additions, e.Deletions
+	if f == nil {
+		return
+	}
+	for i := range e.files {
+		file := &e.files[i]
+		weight := f.weight(file.Path)
+		if weight == 1 {
+			continue
+		}
+		additions := int(math.Round(float64(file.Additions) * weight))
+		deletions := int(math.Round(float64(file.Deletions) * weight))
+		e.Additions -= file.Additions - additions
+		e.Deletions -= file.Deletions - deletions
+		file.Additions, file.Deletions = additions, deletions
+	}
+}
+
+// markOutliers flags commits changing far more lines than is usual for the
+// history: at least outlier_min_lines, and more than outlier_factor times the
+// median of the commits that changed any lines
+func markOutliers(history []CommitHistory) {
+	settings := config.AppConfig.ChangeSettings
+	var sizes []int
+	for i := range history {
+		history[i].Outlier = false
+		if lines := history[i].Additions + history[i].Deletions; lines > 0 {
+			sizes = append(sizes, lines)
+		}
+	}
+	if len(sizes) == 0 || settings.OutlierFactor <= 0 {
+		return
+	}
+
+	sort.Ints(sizes)
+	median := float64(sizes[len(sizes)/2])
+	if len(sizes)%2 == 0 {
+		median = float64(sizes[len(sizes)/2-1]+sizes[len(sizes)/2]) / 2
+	}
+	for i := range history {
+		lines := history[i].Additions + history[i].Deletions
+		if lines >= settings.OutlierMinLines && float64(lines) > settings.OutlierFactor*median {
+			history[i].Outlier = true
+		}
+	}
+}
+
+// CountedChanges returns the additions and deletions the commit contributes
+// to line totals: after change rules, and nothing for outliers when they are
+// excluded
+func (c CommitHistory) CountedChanges() (int, int) {
+	if c.Outlier && config.AppConfig.ChangeSettings.ExcludeOutliers {
+		return 0, 0
+	}
+	return c.Additions, c.Deletions
+}
+
+// RawChanges returns the commit's additions and deletions before change
+// rules. Histories scanned before rules existed only know the filtered ones.
+func (c CommitHistory) RawChanges() (int, int) {
+	if c.RawAdditions == 0 && c.RawDeletions == 0 {
+		return c.Additions, c.Deletions
+	}
+	return c.RawAdditions, c.RawDeletions
+}
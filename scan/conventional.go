@@ -0,0 +1,164 @@
+package scan
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// conventionalSubject matches "type(scope)!: description"
+var conventionalSubject = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9_-]*)(?:\(([^()]*)\))?(!)?: +\S`)
+
+// trailerLine matches "Key: value" lines of a trailer block
+var trailerLine = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9-]*|BREAKING CHANGE): *(.*)$`)
+
+// CommitMessage is a commit message split along the Conventional Commits
+// specification
+type CommitMessage struct {
+	Type     string              // lowercased, "" when the subject is not conventional
+	Scope    string              // optional scope in parentheses
+	Breaking bool                // "!" after the type or a BREAKING CHANGE trailer
+	Trailers map[string][]string // trailer values by canonical key, e.g. "Co-authored-by"
+}
+
+// ParseCommitMessage parses the full message of a commit. Trailers are read
+// from the last paragraph when every line in it is a trailer or a
+// continuation of one.
+func ParseCommitMessage(message string) CommitMessage {
+	var parsed CommitMessage
+	message = strings.TrimLeft(strings.ReplaceAll(message, "\r\n", "\n"), "\n")
+	subject, body, _ := strings.Cut(message, "\n")
+
+	if m := conventionalSubject.FindStringSubmatch(strings.TrimSpace(subject)); m != nil {
+		parsed.Type = strings.ToLower(m[1])
+		parsed.Scope = strings.TrimSpace(m[2])
+		parsed.Breaking = m[3] == "!"
+	}
+
+	parsed.Trailers = parseTrailers(body)
+	if len(parsed.Trailers["BREAKING CHANGE"]) > 0 {
+		parsed.Breaking = true
+	}
+	return parsed
+}
+
+// parseTrailers reads the trailer block at the end of a message body
+func parseTrailers(body string) map[string][]string {
+	body = strings.TrimLeft(strings.TrimRight(body, "\n "), "\n")
+	if body == "" {
+		return nil
+	}
+	block := body
+	if i := strings.LastIndex(body, "\n\n"); i >= 0 {
+		block = body[i+2:]
+	}
+
+	trailers := make(map[string][]string)
+	lastKey := ""
+	for _, line := range strings.Split(block, "\n") {
+		if lastKey != "" && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			values := trailers[lastKey]
+			values[len(values)-1] += " " + strings.TrimSpace(line)
+			continue
+		}
+		m := trailerLine.FindStringSubmatch(line)
+		if m == nil {
+			return nil
+		}
+		lastKey = canonicalTrailerKey(m[1])
+		trailers[lastKey] = append(trailers[lastKey], strings.TrimSpace(m[2]))
+	}
+	return trailers
+}
+
+// canonicalTrailerKey capitalizes only the first letter, as git writes
+// "Signed-off-by" and "Co-authored-by". BREAKING-CHANGE is a synonym of
+// BREAKING CHANGE.
+func canonicalTrailerKey(key string) string {
+	if strings.EqualFold(key, "BREAKING CHANGE") || strings.EqualFold(key, "BREAKING-CHANGE") {
+		return "BREAKING CHANGE"
+	}
+	key = strings.ToLower(key)
+	return strings.ToUpper(key[:1]) + key[1:]
+}
+
+// CommitTypes counts commits made after since by conventional type. Commits
+// that do not follow the convention are counted under "".
+func CommitTypes(history []CommitHistory, since time.Time) map[string]int {
+	types := make(map[string]int)
+	for _, commit := range history {
+		if commit.Date.After(since) {
+			types[commit.Type]++
+		}
+	}
+	return types
+}
+
+// TypePeriod counts commits by type over one period
+type TypePeriod struct {
+	Start  time.Time // first day of the period, as a UTC date
+	Counts map[string]int
+}
+
+// FixFeatRatio returns fixes per feature in the period, and false when the
+// period has no features
+func (p TypePeriod) FixFeatRatio() (float64, bool) {
+	if p.Counts["feat"] == 0 {
+		return 0, false
+	}
+	return float64(p.Counts["fix"]) / float64(p.Counts["feat"]), true
+}
+
+// TypeTrendWeeks is how many weeks of commit types trends cover
+const TypeTrendWeeks = 4
+
+// TypeTrend counts commit types per week for the last weeks weeks, oldest
+// first. Weeks end today, so the last period covers the past seven days.
+func TypeTrend(history []CommitHistory, weeks int) []TypePeriod {
+	if weeks <= 0 {
+		return nil
+	}
+	today, _ := parseDayKey(Today())
+	periods := make([]TypePeriod, weeks)
+	for i := range periods {
+		periods[i] = TypePeriod{
+			Start:  today.AddDate(0, 0, -7*(weeks-i)+1),
+			Counts: make(map[string]int),
+		}
+	}
+
+	for _, commit := range history {
+		day, _ := parseDayKey(DayKey(commit.Date))
+		if day.Before(periods[0].Start) || day.After(today) {
+			continue
+		}
+		index := int(day.Sub(periods[0].Start).Hours()/24) / 7
+		if index >= weeks {
+			index = weeks - 1
+		}
+		periods[index].Counts[commit.Type]++
+	}
+	return periods
+}
+
+// SortedTypes returns the types in counts by descending count, with the
+// non-conventional "" entry last
+func SortedTypes(counts map[string]int) []string {
+	types := make([]string, 0, len(counts))
+	for t := range counts {
+		if t != "" {
+			types = append(types, t)
+		}
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if counts[types[i]] != counts[types[j]] {
+			return counts[types[i]] > counts[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	if counts[""] > 0 {
+		types = append(types, "")
+	}
+	return types
+}
@@ -0,0 +1,106 @@
+package scan
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AccursedGalaxy/streakode/config"
+)
+
+// dayKeyLayout is the format of day keys used across the cache
+const dayKeyLayout = "2006-01-02"
+
+var (
+	locationMu   sync.Mutex
+	locationName string
+	location     *time.Location
+)
+
+// Location returns the configured time zone used to decide which day a
+// commit belongs to. It defaults to the system's local zone.
+func Location() *time.Location {
+	name := config.AppConfig.Timezone
+
+	locationMu.Lock()
+	defer locationMu.Unlock()
+	if location != nil && name == locationName {
+		return location
+	}
+
+	loc := time.Local
+	if name != "" && name != "Local" {
+		if loaded, err := time.LoadLocation(name); err == nil {
+			loc = loaded
+		} else if config.AppConfig.Debug {
+			fmt.Printf("Debug: Unknown timezone %q, using local time: %v\n", name, err)
+		}
+	}
+	locationName, location = name, loc
+	return loc
+}
+
+// LocalTime converts t to the configured time zone
+func LocalTime(t time.Time) time.Time {
+	return t.In(Location())
+}
+
+// DayOf returns midnight of the day t counts toward, in the configured zone.
+// Commits made before the configured rollover hour count toward the
+// previous day, so a 1am commit can extend the evening's streak.
+func DayOf(t time.Time) time.Time {
+	local := LocalTime(t)
+	day := local.Day()
+	// Compare wall clock hours so DST changes do not move the boundary
+	if local.Hour() < config.AppConfig.DayRolloverHour {
+		day--
+	}
+	return time.Date(local.Year(), local.Month(), day, 0, 0, 0, 0, local.Location())
+}
+
+// DayKey returns the "2006-01-02" key of the day t counts toward
+func DayKey(t time.Time) string {
+	return DayOf(t).Format(dayKeyLayout)
+}
+
+// Today returns the key of the current day
+func Today() string {
+	return DayKey(time.Now())
+}
+
+// parseDayKey turns a day key back into a date. Dates are returned in UTC so
+// day arithmetic is not affected by DST transitions.
+func parseDayKey(key string) (time.Time, error) {
+	return time.Parse(dayKeyLayout, key)
+}
+
+// activeDays returns the sorted distinct day keys of dates
+func activeDays(dates []time.Time) []string {
+	seen := make(map[string]bool, len(dates))
+	var days []string
+	for _, date := range dates {
+		key := DayKey(date)
+		if !seen[key] {
+			seen[key] = true
+			days = append(days, key)
+		}
+	}
+	sort.Strings(days)
+	return days
+}
+
+// buildDailyStats aggregates commit history per day
+func buildDailyStats(history []CommitHistory) map[string]DailyStats {
+	stats := make(map[string]DailyStats)
+	for _, commit := range history {
+		key := DayKey(commit.Date)
+		day := stats[key]
+		day.Date = DayOf(commit.Date)
+		day.Commits++
+		day.Lines += commit.Additions + commit.Deletions
+		day.Files += commit.FileCount
+		stats[key] = day
+	}
+	return stats
+}
@@ -0,0 +1,180 @@
+package scan
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/AccursedGalaxy/streakode/config"
+	"github.com/AccursedGalaxy/streakode/scan/gitobj"
+)
+
+// RepoKind describes how a repository is laid out on disk
+type RepoKind string
+
+const (
+	RepoStandard  RepoKind = "repository" // working tree with a .git directory
+	RepoWorktree  RepoKind = "worktree"   // linked worktree, .git is a file
+	RepoSubmodule RepoKind = "submodule"  // submodule checkout, .git is a file
+	RepoBare      RepoKind = "bare"       // git directory without a working tree
+)
+
+// DiscoveredRepo is a repository found while walking the scan directories
+type DiscoveredRepo struct {
+	Path       string // working tree, or the git directory of a bare repository
+	GitDir     string
+	CommonDir  string // object store; worktrees of one repository share it
+	Kind       RepoKind
+	Depth      int         // directories below the scan directory
+	Duplicate  string      // repository found earlier with the same object store
+	ExcludedBy *IgnoreRule // exclusion rule matching the repository, if any
+}
+
+// DiscoverOptions controls how far discovery searches
+type DiscoverOptions struct {
+	MaxDepth   int         // directories below each scan directory to search, 0 for no limit
+	Submodules bool        // keep searching inside repositories for submodules and nested clones
+	Exclusions *Exclusions // directories to skip, nil to walk everything
+}
+
+// DiscoveryOptions returns the discovery options from the configuration
+func DiscoveryOptions() DiscoverOptions {
+	return DiscoverOptions{
+		MaxDepth:   config.AppConfig.ScanSettings.MaxDepth,
+		Submodules: config.AppConfig.ScanSettings.Submodules,
+	}
+}
+
+// discoverer walks directory trees looking for repositories
+type discoverer struct {
+	opts    DiscoverOptions
+	visited map[string]bool   // resolved paths of walked directories
+	stores  map[string]string // object store -> first repository using it
+	found   func(DiscoveredRepo)
+	skipped func(path string, err error)
+}
+
+// DiscoverRepositories walks dirs and calls found for every repository, in a
+// stable order. Worktrees sharing an object store with a repository found
+// earlier have Duplicate set. Excluded directories are not searched, and an
+// excluded repository is reported with ExcludedBy set. .streakodeignore files
+// are read as directories are entered. Unreadable directories are reported to
+// skipped.
+// Symbolic links to directories are followed; a directory reached a second
+// time, as through a symlink cycle, is not walked again.
+func DiscoverRepositories(dirs []string, opts DiscoverOptions, found func(DiscoveredRepo), skipped func(path string, err error)) {
+	d := &discoverer{
+		opts:    opts,
+		visited: make(map[string]bool),
+		stores:  make(map[string]string),
+		found:   found,
+		skipped: skipped,
+	}
+	for _, dir := range dirs {
+		d.walk(dir, 0)
+	}
+}
+
+func (d *discoverer) walk(dir string, depth int) {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		d.skipped(dir, err)
+		return
+	}
+	if d.visited[real] {
+		if config.AppConfig.Debug {
+			fmt.Printf("Debug: Already visited %s, skipping %s\n", real, dir)
+		}
+		return
+	}
+	d.visited[real] = true
+
+	excludedBy := d.opts.Exclusions.excludedBy(dir)
+	if repo, ok := detectRepo(dir); ok {
+		repo.Depth = depth
+		repo.ExcludedBy = excludedBy
+		if first, ok := d.stores[repo.CommonDir]; ok {
+			repo.Duplicate = first
+		} else if excludedBy == nil {
+			d.stores[repo.CommonDir] = repo.Path
+		}
+		d.found(repo)
+		// Bare repositories have no working tree to search
+		if !d.opts.Submodules || repo.Kind == RepoBare {
+			return
+		}
+	}
+	if excludedBy != nil {
+		if config.AppConfig.Debug {
+			fmt.Printf("Debug: Excluding %s (%s)\n", dir, excludedBy)
+		}
+		return
+	}
+
+	if d.opts.MaxDepth > 0 && depth >= d.opts.MaxDepth {
+		return
+	}
+
+	if err := d.opts.Exclusions.LoadIgnoreFile(dir); err != nil && config.AppConfig.Debug {
+		fmt.Printf("Debug: %v\n", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		d.skipped(dir, err)
+		return
+	}
+	for _, entry := range entries {
+		if entry.Name() == ".git" {
+			continue
+		}
+		child := filepath.Join(dir, entry.Name())
+		isDir := entry.IsDir()
+		if entry.Type()&fs.ModeSymlink != 0 {
+			info, err := os.Stat(child)
+			isDir = err == nil && info.IsDir()
+		}
+		if isDir {
+			d.walk(child, depth+1)
+		}
+	}
+}
+
+// detectRepo reports whether dir is a working tree, linked worktree,
+// submodule checkout or bare repository
+func detectRepo(dir string) (DiscoveredRepo, bool) {
+	repo := DiscoveredRepo{Path: dir, Kind: RepoStandard}
+
+	info, err := os.Stat(filepath.Join(dir, ".git"))
+	switch {
+	case err == nil && info.IsDir():
+		repo.GitDir = filepath.Join(dir, ".git")
+	case err == nil:
+		gitDir, err := gitobj.FindGitDir(dir)
+		if err != nil {
+			return repo, false
+		}
+		repo.GitDir = gitDir
+		if _, err := os.Stat(filepath.Join(gitDir, "commondir")); err == nil {
+			repo.Kind = RepoWorktree
+		} else if strings.Contains(filepath.ToSlash(gitDir), "/modules/") {
+			repo.Kind = RepoSubmodule
+		}
+	case filepath.Base(dir) != ".git" && gitobj.IsGitDir(dir):
+		repo.GitDir = dir
+		repo.Kind = RepoBare
+	default:
+		return repo, false
+	}
+
+	if !gitobj.IsGitDir(repo.GitDir) {
+		return repo, false
+	}
+	repo.CommonDir = gitobj.CommonDir(repo.GitDir)
+	if real, err := filepath.EvalSymlinks(repo.CommonDir); err == nil {
+		repo.CommonDir = real
+	}
+	return repo, true
+}
@@ -0,0 +1,138 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// attributeRule is a single line of a .gitattributes file
+type attributeRule struct {
+	dir     string // directory of the .gitattributes file, "" for the root
+	pattern string
+	attrs   map[string]string // "true", "false" or a value; "" unsets
+}
+
+// gitAttributes holds the rules of every .gitattributes file in a repository,
+// ordered from the root down so that later rules take precedence
+type gitAttributes struct {
+	rules []attributeRule
+}
+
+// parseGitAttributes parses the contents of the .gitattributes file in dir
+func parseGitAttributes(dir string, data []byte) []attributeRule {
+	var rules []attributeRule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		rule := attributeRule{dir: dir, pattern: fields[0], attrs: make(map[string]string)}
+		for _, attr := range fields[1:] {
+			switch {
+			case strings.HasPrefix(attr, "-"):
+				rule.attrs[attr[1:]] = "false"
+			case strings.HasPrefix(attr, "!"):
+				rule.attrs[attr[1:]] = ""
+			default:
+				name, value, hasValue := strings.Cut(attr, "=")
+				if !hasValue {
+					value = "true"
+				}
+				rule.attrs[name] = value
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// loadGitAttributes reads the .gitattributes files among a repository's
+// tracked files from the working tree
+func loadGitAttributes(repoPath string, files []string) *gitAttributes {
+	return readGitAttributes(files, func(file string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(repoPath, filepath.FromSlash(file)))
+	})
+}
+
+// readGitAttributes parses the .gitattributes files among files, reading
+// their content through read
+func readGitAttributes(files []string, read func(file string) ([]byte, error)) *gitAttributes {
+	var attrFiles []string
+	for _, file := range files {
+		if path.Base(file) == ".gitattributes" {
+			attrFiles = append(attrFiles, file)
+		}
+	}
+	// Shallower files first so deeper ones override them
+	sort.SliceStable(attrFiles, func(i, j int) bool {
+		return strings.Count(attrFiles[i], "/") < strings.Count(attrFiles[j], "/")
+	})
+
+	attrs := &gitAttributes{}
+	for _, file := range attrFiles {
+		data, err := read(file)
+		if err != nil {
+			continue
+		}
+		dir := path.Dir(file)
+		if dir == "." {
+			dir = ""
+		}
+		attrs.rules = append(attrs.rules, parseGitAttributes(dir, data)...)
+	}
+	return attrs
+}
+
+// lookup returns the attributes set for a file
+func (a *gitAttributes) lookup(file string) map[string]string {
+	result := make(map[string]string)
+	if a == nil {
+		return result
+	}
+	for _, rule := range a.rules {
+		if !rule.matches(file) {
+			continue
+		}
+		for name, value := range rule.attrs {
+			if value == "" {
+				delete(result, name)
+			} else {
+				result[name] = value
+			}
+		}
+	}
+	return result
+}
+
+// matches reports whether the rule applies to file. Patterns without a slash
+// match the file name at any depth, others are relative to the rule's
+// directory.
+func (r attributeRule) matches(file string) bool {
+	rel := file
+	if r.dir != "" {
+		if !strings.HasPrefix(file, r.dir+"/") {
+			return false
+		}
+		rel = file[len(r.dir)+1:]
+	}
+	pattern := r.pattern
+	if !strings.Contains(pattern, "/") {
+		return matchSegment(pattern, path.Base(rel))
+	}
+	return matchGlob(strings.TrimPrefix(pattern, "/"), rel)
+}
+
+// attrBool reads a boolean attribute, reporting whether it was set at all
+func attrBool(attrs map[string]string, name string) (value, ok bool) {
+	v, ok := attrs[name]
+	if !ok {
+		return false, false
+	}
+	return v != "false" && v != "0", true
+}
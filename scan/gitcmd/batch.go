@@ -0,0 +1,88 @@
+package gitcmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Batch reads objects through one long running `git cat-file --batch`
+// process instead of starting git once per object
+type Batch struct {
+	ctx    context.Context
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// NewBatch starts reading objects of the repository at repoPath. The process
+// is killed once ctx is done; Close ends it.
+func NewBatch(ctx context.Context, repoPath string) (*Batch, error) {
+	cmd := Command(ctx, repoPath, "cat-file", "--batch")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error piping to git cat-file: %v", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error piping from git cat-file: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, describe(ctx, []string{"cat-file"}, err, "")
+	}
+	return &Batch{ctx: ctx, cmd: cmd, stdin: stdin, stdout: bufio.NewReaderSize(stdout, 64*1024)}, nil
+}
+
+// Read returns the type and content of an object named by hash or any
+// expression git understands. A missing object is an error that leaves the
+// batch usable.
+func (b *Batch) Read(object string) (string, []byte, error) {
+	if strings.Contains(object, "\n") {
+		return "", nil, fmt.Errorf("invalid object name %q", object)
+	}
+	if _, err := io.WriteString(b.stdin, object+"\n"); err != nil {
+		return "", nil, b.failed(err)
+	}
+
+	// "<oid> <type> <size>" or "<object> missing"
+	header, err := b.stdout.ReadString('\n')
+	if err != nil {
+		return "", nil, b.failed(err)
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return "", nil, fmt.Errorf("object %s not found: %s", object, strings.TrimSpace(header))
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return "", nil, fmt.Errorf("malformed git cat-file header %q", strings.TrimSpace(header))
+	}
+
+	// The content is followed by a newline
+	data := make([]byte, size+1)
+	if _, err := io.ReadFull(b.stdout, data); err != nil {
+		return "", nil, b.failed(err)
+	}
+	return fields[1], data[:size], nil
+}
+
+// Close ends the process
+func (b *Batch) Close() error {
+	b.stdin.Close()
+	if err := b.cmd.Wait(); err != nil && b.ctx.Err() == nil {
+		return fmt.Errorf("git cat-file failed: %v", err)
+	}
+	return nil
+}
+
+// failed explains a broken pipe to the process
+func (b *Batch) failed(err error) error {
+	if ctxErr := b.ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("git cat-file stopped: %v", ctxErr)
+	}
+	return fmt.Errorf("reading from git cat-file failed: %v", err)
+}
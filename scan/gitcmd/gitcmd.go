@@ -0,0 +1,70 @@
+// Package gitcmd runs the git binary under a context.
+//
+// Every invocation is bound to a context.Context: cancelling it, or letting
+// its deadline pass, kills the git process, so a repository with a stuck
+// lock or a huge pack cannot hang a scan.
+package gitcmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// waitDelay is how long a killed git process gets to release its pipes
+const waitDelay = 2 * time.Second
+
+// Command returns a git command run in repoPath, or the current directory
+// when repoPath is empty, that is killed once ctx is done
+func Command(ctx context.Context, repoPath string, args ...string) *exec.Cmd {
+	if repoPath != "" {
+		args = append([]string{"-C", repoPath}, args...)
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.WaitDelay = waitDelay
+	return cmd
+}
+
+// Output runs git and returns its standard output. Errors carry git's
+// standard error, or the context error when git was stopped by ctx.
+func Output(ctx context.Context, repoPath string, args ...string) ([]byte, error) {
+	cmd := Command(ctx, repoPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return output, describe(ctx, args, err, stderr.String())
+	}
+	return output, nil
+}
+
+// Run runs git, discarding its output. Errors are as for Output; a non-zero
+// exit status is returned as an *exec.ExitError.
+func Run(ctx context.Context, repoPath string, args ...string) error {
+	cmd := Command(ctx, repoPath, args...)
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok && ctx.Err() == nil {
+			return err
+		}
+		return describe(ctx, args, err, "")
+	}
+	return nil
+}
+
+// describe explains why a git invocation failed
+func describe(ctx context.Context, args []string, err error, stderr string) error {
+	name := "git"
+	if len(args) > 0 {
+		name = "git " + args[0]
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("%s stopped: %v", name, ctxErr)
+	}
+	if stderr = strings.TrimSpace(stderr); stderr != "" {
+		return fmt.Errorf("%s failed: %v: %s", name, err, stderr)
+	}
+	return fmt.Errorf("%s failed: %v", name, err)
+}
@@ -0,0 +1,89 @@
+package gitcmd
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestOutput(t *testing.T) {
+	dir := t.TempDir()
+	if err := Run(context.Background(), dir, "init", "-q"); err != nil {
+		t.Fatalf("git init failed: %v", err)
+	}
+
+	output, err := Output(context.Background(), dir, "rev-parse", "--is-inside-work-tree")
+	if err != nil || strings.TrimSpace(string(output)) != "true" {
+		t.Errorf("Expected git to run in %s, got %q, %v", dir, output, err)
+	}
+
+	// Failures carry git's own explanation
+	_, err = Output(context.Background(), dir, "rev-parse", "--verify", "no-such-ref")
+	if err == nil || !strings.Contains(err.Error(), "git rev-parse failed") {
+		t.Errorf("Expected a descriptive error, got %v", err)
+	}
+
+	// A non-zero exit status stays inspectable
+	err = Run(context.Background(), dir, "diff", "--quiet", "--no-index", "/dev/null", "/dev/null")
+	if err != nil {
+		t.Errorf("Expected identical files to compare equal, got %v", err)
+	}
+	err = Run(context.Background(), dir, "rev-parse", "--verify", "-q", "no-such-ref")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+		t.Errorf("Expected exit status 1, got %v", err)
+	}
+}
+
+func TestCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Output(ctx, t.TempDir(), "version")
+	if err == nil || !strings.Contains(err.Error(), "git version stopped") {
+		t.Errorf("Expected a cancelled command to report it was stopped, got %v", err)
+	}
+	if err := Run(ctx, "", "version"); err == nil {
+		t.Error("Expected a cancelled command to fail")
+	}
+}
+
+func TestBatch(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	if err := Run(ctx, dir, "init", "-q"); err != nil {
+		t.Fatalf("git init failed: %v", err)
+	}
+	hashes := make(map[string]string)
+	for _, content := range []string{"first\n", "", "no newline"} {
+		cmd := Command(ctx, dir, "hash-object", "-w", "--stdin")
+		cmd.Stdin = strings.NewReader(content)
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("git hash-object failed: %v", err)
+		}
+		hashes[content] = strings.TrimSpace(string(output))
+	}
+
+	batch, err := NewBatch(ctx, dir)
+	if err != nil {
+		t.Fatalf("Starting the batch failed: %v", err)
+	}
+	for content, hash := range hashes {
+		kind, data, err := batch.Read(hash)
+		if err != nil || kind != "blob" || string(data) != content {
+			t.Errorf("Read(%s) = %q, %q, %v; want blob %q", hash, kind, data, err, content)
+		}
+	}
+
+	// A missing object does not end the batch
+	if _, _, err := batch.Read(strings.Repeat("0", 40)); err == nil {
+		t.Error("Expected reading a missing object to fail")
+	}
+	if _, data, err := batch.Read(hashes["first\n"]); err != nil || string(data) != "first\n" {
+		t.Errorf("Expected the batch to stay usable after a missing object, got %q, %v", data, err)
+	}
+	if err := batch.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
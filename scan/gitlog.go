@@ -0,0 +1,490 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AccursedGalaxy/streakode/config"
+	"github.com/AccursedGalaxy/streakode/scan/gitcmd"
+	"github.com/AccursedGalaxy/streakode/scan/gitobj"
+)
+
+// logOptions controls which commits readCommits returns
+type logOptions struct {
+	Authors *IdentityMatcher // only commits by these identities; everyone when nil
+	Since   time.Time        // only commits committed after this time, like git --after
+	Until   time.Time        // only commits committed at or before this time, like git --before
+	NumStat bool             // collect per-commit line and file counts
+	Tips    []string         // commits to start from; every ref when empty
+	Exclude []string         // commits whose history is skipped, like git --not
+
+	// Also return commits whose Co-authored-by trailers name one of Authors
+	CoAuthors bool
+	// Compute the patch-id of each commit
+	PatchIDs bool
+}
+
+// logEntry is a single commit read from a repository
+type logEntry struct {
+	Hash        string
+	Date        time.Time // author date
+	AuthorName  string    // as recorded in the commit
+	AuthorEmail string
+	MappedName  string // after applying .mailmap
+	MappedEmail string
+	Subject     string
+	Message     string // full message, subject included
+	FileCount   int
+	Additions   int
+	Deletions   int
+	Languages   map[string]int // changed lines per language
+	CoAuthored  bool           // matched through a Co-authored-by trailer only
+	PatchID     string         // stable patch-id, when requested and the commit has changes
+
+	// Changed lines before change rules; Additions and Deletions are after
+	RawAdditions int
+	RawDeletions int
+
+	files []gitobj.FileStat // per-file changes, only while reading
+}
+
+// toHistory converts a log entry into the cached commit representation
+func (e logEntry) toHistory() CommitHistory {
+	message := ParseCommitMessage(e.Message)
+	return CommitHistory{
+		Date:         e.Date.UTC(),
+		Hash:         e.Hash,
+		MessageHead:  e.Subject,
+		Author:       e.MappedName,
+		Identity:     FormatIdentity(e.MappedName, e.MappedEmail),
+		FileCount:    e.FileCount,
+		Additions:    e.Additions,
+		Deletions:    e.Deletions,
+		RawAdditions: e.RawAdditions,
+		RawDeletions: e.RawDeletions,
+		Languages:    e.Languages,
+		Type:         message.Type,
+		Scope:        message.Scope,
+		Breaking:     message.Breaking,
+		Trailers:     message.Trailers,
+		CoAuthored:   e.CoAuthored,
+		PatchID:      e.PatchID,
+	}
+}
+
+// readCommits lists commits reachable from any ref, newest first. It reads
+// the object store directly and only falls back to the git binary when the
+// repository cannot be read natively.
+func readCommits(ctx context.Context, repoPath string, opts logOptions) ([]logEntry, error) {
+	entries, err := readCommitsNative(ctx, repoPath, opts)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if config.AppConfig.Debug {
+			fmt.Printf("Debug: Native reader failed for %s, falling back to git: %v\n", repoPath, err)
+		}
+		if entries, err = readCommitsGit(ctx, repoPath, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.NumStat {
+		attributes := loadGitAttributes(repoPath, []string{".gitattributes"})
+		filter := configuredChangeFilter()
+		for i := range entries {
+			entries[i].filterChanges(filter)
+			entries[i].attributeLanguages(attributes)
+		}
+	}
+	if opts.PatchIDs {
+		if err := setPatchIDs(ctx, repoPath, entries); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// attributeLanguages credits the commit's changed lines to the languages of
+// the files it touched. Files that do not count toward language statistics
+// are left out.
+func (e *logEntry) attributeLanguages(attributes *gitAttributes) {
+	for _, file := range e.files {
+		if file.Binary || file.Additions+file.Deletions == 0 {
+			continue
+		}
+		language := pathLanguage(file.Path, attributes.lookup(file.Path))
+		if language == "" {
+			continue
+		}
+		if e.Languages == nil {
+			e.Languages = make(map[string]int)
+		}
+		e.Languages[language] += file.Additions + file.Deletions
+	}
+	e.files = nil
+}
+
+func readCommitsNative(ctx context.Context, repoPath string, opts logOptions) ([]logEntry, error) {
+	repo, err := gitobj.Open(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.Close()
+
+	var tips []gitobj.Hash
+	if len(opts.Tips) == 0 {
+		if tips, err = repo.Tips(); err != nil {
+			return nil, err
+		}
+	} else if tips, err = parseHashes(opts.Tips); err != nil {
+		return nil, err
+	}
+	exclude, err := parseHashes(opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	mailmap := readMailmap(repoPath)
+	var entries []logEntry
+	err = repo.Walk(tips, exclude, func(c *gitobj.Commit) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !opts.Since.IsZero() && !c.Committer.When.After(opts.Since) {
+			return nil
+		}
+		if !opts.Until.IsZero() && c.Committer.When.After(opts.Until) {
+			return nil
+		}
+		entry := logEntry{
+			Hash:        c.Hash.String(),
+			Date:        c.Author.When,
+			AuthorName:  c.Author.Name,
+			AuthorEmail: c.Author.Email,
+			Subject:     c.Subject(),
+			Message:     c.Message,
+		}
+		entry.MappedName, entry.MappedEmail = mailmap.Resolve(c.Author.Name, c.Author.Email)
+		if !entry.selected(opts) {
+			return nil
+		}
+		if opts.NumStat {
+			stats, err := repo.DiffStat(c)
+			if err != nil {
+				return err
+			}
+			for _, s := range stats {
+				entry.FileCount++
+				entry.Additions += s.Additions
+				entry.Deletions += s.Deletions
+			}
+			entry.files = stats
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// matches reports whether either the recorded or the mailmapped identity
+// belongs to authors
+func (e logEntry) matches(authors *IdentityMatcher) bool {
+	return authors.Matches(e.AuthorName, e.AuthorEmail) || authors.Matches(e.MappedName, e.MappedEmail)
+}
+
+// selected reports whether the commit belongs in the log, marking commits
+// that only a co-author trailer ties to the authors
+func (e *logEntry) selected(opts logOptions) bool {
+	if e.matches(opts.Authors) {
+		return true
+	}
+	if !opts.CoAuthors || !strings.Contains(strings.ToLower(e.Message), "co-authored-by") {
+		return false
+	}
+	trailers := ParseCommitMessage(e.Message).Trailers
+	e.CoAuthored = opts.Authors.MatchesCoAuthor(trailers[coAuthorTrailer])
+	return e.CoAuthored
+}
+
+func parseHashes(hashes []string) ([]gitobj.Hash, error) {
+	parsed := make([]gitobj.Hash, 0, len(hashes))
+	for _, s := range hashes {
+		h, err := gitobj.ParseHash(s)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, h)
+	}
+	return parsed, nil
+}
+
+// Field and record separators keep subjects containing "|" intact; the body
+// separator ends the multi-line message ahead of the --numstat lines
+const (
+	logFieldSep  = "\x1f"
+	logRecordSep = "\x1e"
+	logBodySep   = "\x1d"
+)
+
+func readCommitsGit(ctx context.Context, repoPath string, opts logOptions) ([]logEntry, error) {
+	args := []string{"log",
+		"--pretty=format:" + logRecordSep + "%H" + logFieldSep + "%aI" + logFieldSep + "%an" + logFieldSep + "%ae" +
+			logFieldSep + "%aN" + logFieldSep + "%aE" + logFieldSep + "%s" + logFieldSep + "%B" + logBodySep}
+	// Let git narrow the log when the plain author pattern is all there is;
+	// identities are matched below in either case
+	if opts.Authors != nil && opts.Authors.authorText != "" && !opts.Authors.HasIdentities() && !opts.CoAuthors {
+		args = append(args, "--author="+opts.Authors.authorText)
+	}
+	if !opts.Since.IsZero() {
+		args = append(args, "--after="+opts.Since.Format(time.RFC3339))
+	}
+	if !opts.Until.IsZero() {
+		args = append(args, "--before="+opts.Until.Format(time.RFC3339))
+	}
+	if opts.NumStat {
+		args = append(args, "--numstat")
+	}
+	if len(opts.Tips) == 0 {
+		args = append(args, "--all")
+	} else {
+		args = append(args, opts.Tips...)
+	}
+	if len(opts.Exclude) > 0 {
+		args = append(args, "--not")
+		args = append(args, opts.Exclude...)
+	}
+
+	if config.AppConfig.Debug {
+		fmt.Printf("Debug: Running git command in %s: git %v\n", repoPath, strings.Join(args, " "))
+	}
+	output, err := gitcmd.Output(ctx, repoPath, args...)
+	if err != nil {
+		return nil, err
+	}
+	var entries []logEntry
+	for _, entry := range parseGitLog(string(output)) {
+		if entry.selected(opts) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// parseGitLog parses output produced with the separators used by readCommitsGit
+func parseGitLog(output string) []logEntry {
+	var entries []logEntry
+	for _, record := range strings.Split(output, logRecordSep) {
+		if strings.TrimSpace(record) == "" {
+			continue
+		}
+		header, stats, _ := strings.Cut(record, logBodySep)
+		fields := strings.Split(header, logFieldSep)
+		if len(fields) != 8 {
+			continue
+		}
+		date, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil {
+			if config.AppConfig.Debug {
+				fmt.Printf("Debug: Failed to parse date %s: %v\n", fields[1], err)
+			}
+			continue
+		}
+
+		entry := logEntry{
+			Hash:        fields[0],
+			Date:        date,
+			AuthorName:  fields[2],
+			AuthorEmail: fields[3],
+			MappedName:  fields[4],
+			MappedEmail: fields[5],
+			Subject:     fields[6],
+			Message:     fields[7],
+		}
+		for _, line := range strings.Split(stats, "\n") {
+			parts := strings.Fields(line)
+			if len(parts) < 3 {
+				continue
+			}
+			entry.FileCount++
+			// Binary files report "-" for both counts
+			if parts[0] == "-" || parts[1] == "-" {
+				continue
+			}
+			additions, _ := strconv.Atoi(parts[0])
+			deletions, _ := strconv.Atoi(parts[1])
+			entry.Additions += additions
+			entry.Deletions += deletions
+			if columns := strings.SplitN(line, "\t", 3); len(columns) == 3 {
+				entry.files = append(entry.files, gitobj.FileStat{
+					Path: numstatPath(columns[2]), Additions: additions, Deletions: deletions,
+				})
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// numstatPath returns the new path of a --numstat entry, resolving renames
+// written as "old => new" or "dir/{old => new}/file"
+func numstatPath(path string) string {
+	if open := strings.IndexByte(path, '{'); open >= 0 {
+		if end := strings.IndexByte(path[open:], '}'); end >= 0 {
+			inner := path[open+1 : open+end]
+			if _, newPart, ok := strings.Cut(inner, " => "); ok {
+				joined := path[:open] + newPart + path[open+end+1:]
+				return strings.ReplaceAll(joined, "//", "/")
+			}
+		}
+	}
+	if _, newPath, ok := strings.Cut(path, " => "); ok {
+		return newPath
+	}
+	return path
+}
+
+// readRefTips maps every ref (and HEAD) to the commit it points at
+func readRefTips(ctx context.Context, repoPath string) (map[string]string, error) {
+	if tips, err := readRefTipsNative(repoPath); err == nil {
+		return tips, nil
+	} else if config.AppConfig.Debug {
+		fmt.Printf("Debug: Native ref listing failed for %s, falling back to git: %v\n", repoPath, err)
+	}
+	return readRefTipsGit(ctx, repoPath)
+}
+
+// readRefTipsGit lists the ref tips with the git CLI, peeling annotated tags
+func readRefTipsGit(ctx context.Context, repoPath string) (map[string]string, error) {
+	output, err := gitcmd.Output(ctx, repoPath, "for-each-ref",
+		"--format=%(refname)%09%(objectname)%09%(objecttype)%09%(*objectname)%09%(*objecttype)")
+	if err != nil {
+		return nil, err
+	}
+
+	tips := make(map[string]string)
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 {
+			continue
+		}
+		switch {
+		case fields[4] == "commit":
+			tips[fields[0]] = fields[3]
+		case fields[2] == "commit":
+			tips[fields[0]] = fields[1]
+		}
+	}
+
+	// An unborn HEAD simply has no tip
+	if head, err := gitcmd.Output(ctx, repoPath, "rev-parse", "--verify", "-q", "HEAD^{commit}"); err == nil {
+		tips["HEAD"] = strings.TrimSpace(string(head))
+	}
+	return tips, nil
+}
+
+func readRefTipsNative(repoPath string) (map[string]string, error) {
+	repo, err := gitobj.Open(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.Close()
+
+	refs, err := repo.RefCommits()
+	if err != nil {
+		return nil, err
+	}
+	tips := make(map[string]string, len(refs))
+	for name, h := range refs {
+		tips[name] = h.String()
+	}
+	return tips, nil
+}
+
+// readRootCommit returns the first-parent root of HEAD, which identifies the
+// project across clones and forks
+func readRootCommit(ctx context.Context, repoPath string) (string, error) {
+	if root, err := readRootCommitNative(repoPath); err == nil {
+		return root, nil
+	} else if config.AppConfig.Debug {
+		fmt.Printf("Debug: Native root lookup failed for %s, falling back to git: %v\n", repoPath, err)
+	}
+
+	output, err := gitcmd.Output(ctx, repoPath, "rev-list", "--first-parent", "--max-parents=0", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	root := strings.TrimSpace(string(output))
+	if root == "" {
+		return "", errors.New("no root commit")
+	}
+	return root, nil
+}
+
+func readRootCommitNative(repoPath string) (string, error) {
+	repo, err := gitobj.Open(repoPath)
+	if err != nil {
+		return "", err
+	}
+	defer repo.Close()
+
+	head, err := repo.Head()
+	if err != nil {
+		return "", err
+	}
+	root, err := repo.RootCommit(head)
+	if err != nil {
+		return "", err
+	}
+	return root.String(), nil
+}
+
+// isAncestor reports whether ancestor is reachable from descendant. An
+// error means one of the commits could not be read.
+func isAncestor(ctx context.Context, repoPath, ancestor, descendant string) (bool, error) {
+	if ok, err := isAncestorNative(repoPath, ancestor, descendant); err == nil {
+		return ok, nil
+	} else if errors.Is(err, gitobj.ErrNotFound) {
+		return false, err
+	} else if config.AppConfig.Debug {
+		fmt.Printf("Debug: Native ancestry check failed for %s, falling back to git: %v\n", repoPath, err)
+	}
+
+	err := gitcmd.Run(ctx, repoPath, "merge-base", "--is-ancestor", ancestor, descendant)
+	if err == nil {
+		return true, nil
+	}
+	// Exit status 1 means "not an ancestor"; anything else is a failure
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	if _, ok := err.(*exec.ExitError); ok {
+		return false, fmt.Errorf("git merge-base failed: %v", err)
+	}
+	return false, err
+}
+
+func isAncestorNative(repoPath, ancestor, descendant string) (bool, error) {
+	repo, err := gitobj.Open(repoPath)
+	if err != nil {
+		return false, err
+	}
+	defer repo.Close()
+
+	hashes, err := parseHashes([]string{ancestor, descendant})
+	if err != nil {
+		return false, err
+	}
+	if _, err := repo.Commit(hashes[1]); err != nil {
+		return false, err
+	}
+	return repo.IsAncestor(hashes[0], hashes[1])
+}
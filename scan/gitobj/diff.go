@@ -0,0 +1,257 @@
+package gitobj
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// FileStat is the numstat entry for a single changed path
+type FileStat struct {
+	Path      string
+	OldPath   string // previous path for renames, empty otherwise
+	Additions int
+	Deletions int
+	Binary    bool
+}
+
+// File is a blob reachable from a tree
+type File struct {
+	Path string
+	Mode uint32
+	Hash Hash
+}
+
+// change is a single path that differs between two trees
+type change struct {
+	path     string
+	from, to File // zero Hash when the side does not exist
+}
+
+// binaryProbe matches git's heuristic of looking for NUL in the first 8000 bytes
+const binaryProbe = 8000
+
+// IsBinary reports whether data looks like binary content
+func IsBinary(data []byte) bool {
+	if len(data) > binaryProbe {
+		data = data[:binaryProbe]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// DiffStat returns the per-file line changes a commit introduced relative to
+// its first parent, equivalent to `git log --numstat`. Merge commits report
+// no changes, matching git's default output.
+func (r *Repository) DiffStat(c *Commit) ([]FileStat, error) {
+	if len(c.Parents) > 1 {
+		return nil, nil
+	}
+	var parentTree Hash
+	if len(c.Parents) == 1 && !r.shallow[c.Hash] {
+		parent, err := r.Commit(c.Parents[0])
+		if err != nil {
+			return nil, err
+		}
+		parentTree = parent.Tree
+	}
+	return r.DiffTreeStat(parentTree, c.Tree)
+}
+
+// DiffTreeStat computes numstat entries between two trees. A zero hash
+// stands for the empty tree.
+func (r *Repository) DiffTreeStat(from, to Hash) ([]FileStat, error) {
+	var changes []change
+	if err := r.diffTrees("", from, to, &changes); err != nil {
+		return nil, err
+	}
+	changes = pairRenames(changes)
+
+	stats := make([]FileStat, 0, len(changes))
+	for _, ch := range changes {
+		stat, err := r.fileStat(ch)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
+
+func (r *Repository) fileStat(ch change) (FileStat, error) {
+	stat := FileStat{Path: ch.path}
+	if !ch.from.Hash.IsZero() && !ch.to.Hash.IsZero() && ch.from.Path != ch.to.Path {
+		stat.OldPath = ch.from.Path
+	}
+	if ch.from.Hash == ch.to.Hash {
+		return stat, nil
+	}
+
+	oldData, err := r.fileContent(ch.from)
+	if err != nil {
+		return stat, err
+	}
+	newData, err := r.fileContent(ch.to)
+	if err != nil {
+		return stat, err
+	}
+	if IsBinary(oldData) || IsBinary(newData) {
+		stat.Binary = true
+		return stat, nil
+	}
+	stat.Additions, stat.Deletions = CountLineChanges(oldData, newData)
+	return stat, nil
+}
+
+// fileContent returns the diffable content of a tree entry
+func (r *Repository) fileContent(f File) ([]byte, error) {
+	if f.Hash.IsZero() {
+		return nil, nil
+	}
+	if f.Mode&0o170000 == ModeGitlink {
+		// git diffs submodules as a one line "Subproject commit" file
+		return []byte(fmt.Sprintf("Subproject commit %s\n", f.Hash)), nil
+	}
+	return r.Blob(f.Hash)
+}
+
+func (r *Repository) treeMap(h Hash) (map[string]TreeEntry, error) {
+	entries := make(map[string]TreeEntry)
+	if h.IsZero() {
+		return entries, nil
+	}
+	list, err := r.Tree(h)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range list {
+		entries[e.Name] = e
+	}
+	return entries, nil
+}
+
+func (r *Repository) diffTrees(prefix string, from, to Hash, out *[]change) error {
+	if from == to {
+		return nil
+	}
+	oldEntries, err := r.treeMap(from)
+	if err != nil {
+		return err
+	}
+	newEntries, err := r.treeMap(to)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(oldEntries)+len(newEntries))
+	for name := range oldEntries {
+		names = append(names, name)
+	}
+	for name := range newEntries {
+		if _, ok := oldEntries[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := prefix + name
+		oldEntry, inOld := oldEntries[name]
+		newEntry, inNew := newEntries[name]
+		if inOld && inNew && oldEntry.Hash == newEntry.Hash && oldEntry.Mode == newEntry.Mode {
+			continue
+		}
+
+		var oldTree, newTree Hash
+		var oldFile, newFile File
+		if inOld {
+			if oldEntry.IsTree() {
+				oldTree = oldEntry.Hash
+			} else {
+				oldFile = File{Path: path, Mode: oldEntry.Mode, Hash: oldEntry.Hash}
+			}
+		}
+		if inNew {
+			if newEntry.IsTree() {
+				newTree = newEntry.Hash
+			} else {
+				newFile = File{Path: path, Mode: newEntry.Mode, Hash: newEntry.Hash}
+			}
+		}
+
+		if !oldTree.IsZero() || !newTree.IsZero() {
+			if err := r.diffTrees(path+"/", oldTree, newTree, out); err != nil {
+				return err
+			}
+		}
+		if !oldFile.Hash.IsZero() || !newFile.Hash.IsZero() {
+			*out = append(*out, change{path: path, from: oldFile, to: newFile})
+		}
+	}
+	return nil
+}
+
+// pairRenames folds a deletion and an addition of identical content into a
+// single rename, as git's default rename detection does for exact matches
+func pairRenames(changes []change) []change {
+	deleted := make(map[Hash][]int)
+	for i, ch := range changes {
+		if ch.to.Hash.IsZero() {
+			deleted[ch.from.Hash] = append(deleted[ch.from.Hash], i)
+		}
+	}
+	if len(deleted) == 0 {
+		return changes
+	}
+
+	consumed := make(map[int]bool)
+	for i, ch := range changes {
+		if !ch.from.Hash.IsZero() {
+			continue
+		}
+		candidates := deleted[ch.to.Hash]
+		if len(candidates) == 0 {
+			continue
+		}
+		j := candidates[0]
+		deleted[ch.to.Hash] = candidates[1:]
+		changes[i].from = changes[j].from
+		consumed[j] = true
+	}
+
+	result := changes[:0]
+	for i, ch := range changes {
+		if !consumed[i] {
+			result = append(result, ch)
+		}
+	}
+	return result
+}
+
+// ListFiles returns every blob reachable from a tree with its full path.
+// Submodule entries are skipped.
+func (r *Repository) ListFiles(tree Hash) ([]File, error) {
+	var files []File
+	err := r.listFiles("", tree, &files)
+	return files, err
+}
+
+func (r *Repository) listFiles(prefix string, tree Hash, out *[]File) error {
+	entries, err := r.Tree(tree)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		path := prefix + e.Name
+		switch {
+		case e.IsTree():
+			if err := r.listFiles(path+"/", e.Hash, out); err != nil {
+				return err
+			}
+		case e.IsGitlink():
+			continue
+		default:
+			*out = append(*out, File{Path: path, Mode: e.Mode, Hash: e.Hash})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package gitobj
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// gitRun runs a git command inside dir and returns its output
+func gitRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Test User",
+		"GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test User",
+		"GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, output)
+	}
+	return string(output)
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// buildTestRepo creates a repository exercising packs, loose objects,
+// renames, binary files, merges and annotated tags
+func buildTestRepo(t *testing.T) string {
+	dir := t.TempDir()
+	gitRun(t, dir, "init", "-q", "-b", "main")
+
+	writeFile(t, dir, "main.go", "package main\n\nfunc main() {}\n")
+	writeFile(t, dir, "docs/readme.md", "# Title\n")
+	gitRun(t, dir, "add", ".")
+	gitRun(t, dir, "commit", "-q", "-m", "initial | with a pipe")
+
+	for i := 0; i < 20; i++ {
+		var body strings.Builder
+		body.WriteString("package main\n\n")
+		for j := 0; j <= i; j++ {
+			fmt.Fprintf(&body, "func f%d() int { return %d }\n", j, j*i)
+		}
+		writeFile(t, dir, "main.go", body.String())
+		gitRun(t, dir, "commit", "-q", "-am", fmt.Sprintf("edit %d", i))
+	}
+
+	writeFile(t, dir, "image.bin", "\x00\x01\x02binary")
+	gitRun(t, dir, "add", ".")
+	gitRun(t, dir, "commit", "-q", "-m", "add binary")
+	gitRun(t, dir, "tag", "-a", "v1", "-m", "release")
+
+	// Pack what we have so far; later objects stay loose
+	gitRun(t, dir, "gc", "-q", "--aggressive")
+
+	gitRun(t, dir, "checkout", "-q", "-b", "feature")
+	gitRun(t, dir, "mv", "docs/readme.md", "README.md")
+	gitRun(t, dir, "commit", "-q", "-m", "move readme")
+	writeFile(t, dir, "README.md", "# Title\n\nMore text\nno newline")
+	gitRun(t, dir, "commit", "-q", "-am", "extend readme")
+
+	gitRun(t, dir, "checkout", "-q", "main")
+	writeFile(t, dir, "other.txt", "a\nb\nc\n")
+	gitRun(t, dir, "add", ".")
+	gitRun(t, dir, "commit", "-q", "-m", "other file")
+	gitRun(t, dir, "merge", "-q", "--no-ff", "-m", "merge feature", "feature")
+	return dir
+}
+
+func TestLogMatchesGit(t *testing.T) {
+	dir := buildTestRepo(t)
+
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer repo.Close()
+
+	commits, err := repo.Log()
+	if err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+
+	expected := strings.Fields(gitRun(t, dir, "log", "--all", "--format=%H"))
+	if len(commits) != len(expected) {
+		t.Fatalf("Expected %d commits, got %d", len(expected), len(commits))
+	}
+	seen := make(map[string]bool)
+	for _, c := range commits {
+		seen[c.Hash.String()] = true
+	}
+	for _, h := range expected {
+		if !seen[h] {
+			t.Errorf("Commit %s missing from native log", h)
+		}
+	}
+
+	for _, c := range commits {
+		subject := strings.TrimSpace(gitRun(t, dir, "log", "-1", "--format=%s", c.Hash.String()))
+		if c.Subject() != subject {
+			t.Errorf("Subject mismatch for %s: got %q, want %q", c.Hash, c.Subject(), subject)
+		}
+	}
+}
+
+func TestDiffStatMatchesNumstat(t *testing.T) {
+	dir := buildTestRepo(t)
+
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer repo.Close()
+
+	commits, err := repo.Log()
+	if err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+
+	for _, c := range commits {
+		stats, err := repo.DiffStat(c)
+		if err != nil {
+			t.Fatalf("DiffStat(%s) failed: %v", c.Hash, err)
+		}
+
+		var wantFiles, wantAdd, wantDel int
+		output := gitRun(t, dir, "log", "-1", "--numstat", "--format=", c.Hash.String())
+		for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+			parts := strings.Fields(line)
+			if len(parts) < 3 {
+				continue
+			}
+			wantFiles++
+			add, _ := strconv.Atoi(parts[0])
+			del, _ := strconv.Atoi(parts[1])
+			wantAdd += add
+			wantDel += del
+		}
+
+		var gotAdd, gotDel int
+		for _, s := range stats {
+			gotAdd += s.Additions
+			gotDel += s.Deletions
+		}
+		if len(stats) != wantFiles || gotAdd != wantAdd || gotDel != wantDel {
+			t.Errorf("%s (%s): got %d files +%d/-%d, want %d files +%d/-%d",
+				c.Hash, c.Subject(), len(stats), gotAdd, gotDel, wantFiles, wantAdd, wantDel)
+		}
+	}
+}
+
+func TestRefsAndHead(t *testing.T) {
+	dir := buildTestRepo(t)
+	gitRun(t, dir, "pack-refs", "--all")
+
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer repo.Close()
+
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatalf("Head failed: %v", err)
+	}
+	want := strings.TrimSpace(gitRun(t, dir, "rev-parse", "HEAD"))
+	if head.String() != want {
+		t.Errorf("HEAD mismatch: got %s, want %s", head, want)
+	}
+
+	refs, err := repo.Refs()
+	if err != nil {
+		t.Fatalf("Refs failed: %v", err)
+	}
+	for _, name := range []string{"refs/heads/main", "refs/heads/feature", "refs/tags/v1", "HEAD"} {
+		if _, ok := refs[name]; !ok {
+			t.Errorf("Expected ref %s", name)
+		}
+	}
+}
+
+func TestCorruptObjectSizes(t *testing.T) {
+	// A pack entry claiming far more data than its zlib stream holds fails
+	// without allocating the claimed size
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte("short object"))
+	zw.Close()
+
+	path := filepath.Join(t.TempDir(), "test.pack")
+	if err := os.WriteFile(path, compressed.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &packfile{path: path, file: file}
+	defer p.close()
+
+	if data, err := p.inflate(0, 12); err != nil || string(data) != "short object" {
+		t.Errorf("inflate with the right size = %q, %v", data, err)
+	}
+	for _, size := range []int64{1 << 40, -1} {
+		if _, err := p.inflate(0, size); err == nil {
+			t.Errorf("inflate with size %d succeeded", size)
+		}
+	}
+
+	// So does a delta claiming a huge result: base size 4, result size 2^40,
+	// then an insert of 2 bytes
+	delta := []byte{4, 0x80, 0x80, 0x80, 0x80, 0x80, 0x20, 2, 'h', 'i'}
+	if _, err := applyDelta([]byte("base"), delta); err == nil {
+		t.Error("applyDelta with a wrong result size succeeded")
+	}
+}
+
+func TestCountLineChanges(t *testing.T) {
+	testCases := []struct {
+		old, new string
+		add, del int
+	}{
+		{"", "a\nb\n", 2, 0},
+		{"a\nb\n", "", 0, 2},
+		{"a\nb\nc\n", "a\nx\nc\n", 1, 1},
+		{"a\nb", "a\nb\n", 1, 1},
+		{"a\nb\nc\nd\n", "b\nc\nd\ne\n", 1, 1},
+	}
+	for _, tc := range testCases {
+		add, del := CountLineChanges([]byte(tc.old), []byte(tc.new))
+		if add != tc.add || del != tc.del {
+			t.Errorf("CountLineChanges(%q, %q) = +%d/-%d, want +%d/-%d",
+				tc.old, tc.new, add, del, tc.add, tc.del)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package gitobj
+
+import "bytes"
+
+// maxDiffWork bounds the Myers search before falling back to an estimate
+const maxDiffWork = 40_000_000
+
+// CountLineChanges returns the number of added and deleted lines between two
+// versions of a file. It computes a minimal line diff (Myers) so the result
+// matches `git diff --numstat`; pathological inputs fall back to a
+// multiset estimate to keep scanning fast.
+func CountLineChanges(oldData, newData []byte) (additions, deletions int) {
+	a := splitLines(oldData)
+	b := splitLines(newData)
+
+	// Common prefix and suffix never contribute to the edit script
+	for len(a) > 0 && len(b) > 0 && bytes.Equal(a[0], b[0]) {
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && bytes.Equal(a[len(a)-1], b[len(b)-1]) {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+	if len(a) == 0 || len(b) == 0 {
+		return len(b), len(a)
+	}
+
+	x, y := internLines(a, b)
+	n, m := len(x), len(y)
+
+	limit := n + m
+	if work := n + m; work*limit > maxDiffWork {
+		limit = max(1000, maxDiffWork/work)
+	}
+	if d, ok := editDistance(x, y, limit); ok {
+		return (d + m - n) / 2, (d - m + n) / 2
+	}
+
+	common := multisetOverlap(x, y)
+	return m - common, n - common
+}
+
+// splitLines splits data into lines, keeping each line terminator so that a
+// missing newline at end of file counts as a change, as in git
+func splitLines(data []byte) [][]byte {
+	var lines [][]byte
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			lines = append(lines, data)
+			break
+		}
+		lines = append(lines, data[:i+1])
+		data = data[i+1:]
+	}
+	return lines
+}
+
+func internLines(a, b [][]byte) ([]int, []int) {
+	ids := make(map[string]int, len(a)+len(b))
+	intern := func(lines [][]byte) []int {
+		out := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[string(line)]
+			if !ok {
+				id = len(ids)
+				ids[string(line)] = id
+			}
+			out[i] = id
+		}
+		return out
+	}
+	return intern(a), intern(b)
+}
+
+// editDistance runs the greedy Myers algorithm and returns the length of the
+// shortest edit script, or false if it exceeds limit
+func editDistance(a, b []int, limit int) (int, bool) {
+	n, m := len(a), len(b)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return d, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// multisetOverlap counts lines present in both inputs, an upper bound on the
+// longest common subsequence
+func multisetOverlap(a, b []int) int {
+	counts := make(map[int]int, len(a))
+	for _, id := range a {
+		counts[id]++
+	}
+	common := 0
+	for _, id := range b {
+		if counts[id] > 0 {
+			counts[id]--
+			common++
+		}
+	}
+	return common
+}
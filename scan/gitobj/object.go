@@ -0,0 +1,280 @@
+// Package gitobj reads git repositories directly from disk.
+//
+// It understands loose objects, packfiles (including deltified objects),
+// loose and packed refs, and is enough to walk history and compute
+// numstat-style line counts without running the git binary.
+package gitobj
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hash is a SHA-1 object id
+type Hash [20]byte
+
+// ZeroHash is the all-zero object id
+var ZeroHash Hash
+
+// ErrNotFound is returned when an object is not present in the repository
+var ErrNotFound = errors.New("object not found")
+
+// errNotCommit is returned when a commit was expected but another object found
+var errNotCommit = errors.New("not a commit")
+
+// ErrUnsupported is returned for repository layouts this package cannot read
+var ErrUnsupported = errors.New("unsupported repository format")
+
+// ParseHash parses a 40 character hex object id
+func ParseHash(s string) (Hash, error) {
+	var h Hash
+	if len(s) != 40 {
+		return h, fmt.Errorf("invalid object id %q", s)
+	}
+	if _, err := hex.Decode(h[:], []byte(s)); err != nil {
+		return h, fmt.Errorf("invalid object id %q: %v", s, err)
+	}
+	return h, nil
+}
+
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// IsZero reports whether h is the zero hash
+func (h Hash) IsZero() bool {
+	return h == ZeroHash
+}
+
+// ObjectType identifies the kind of a git object
+type ObjectType int
+
+const (
+	TypeInvalid ObjectType = 0
+	TypeCommit  ObjectType = 1
+	TypeTree    ObjectType = 2
+	TypeBlob    ObjectType = 3
+	TypeTag     ObjectType = 4
+	// pack-only delta encodings
+	typeOfsDelta ObjectType = 6
+	typeRefDelta ObjectType = 7
+)
+
+func (t ObjectType) String() string {
+	switch t {
+	case TypeCommit:
+		return "commit"
+	case TypeTree:
+		return "tree"
+	case TypeBlob:
+		return "blob"
+	case TypeTag:
+		return "tag"
+	default:
+		return "invalid"
+	}
+}
+
+func parseObjectType(s string) ObjectType {
+	switch s {
+	case "commit":
+		return TypeCommit
+	case "tree":
+		return TypeTree
+	case "blob":
+		return TypeBlob
+	case "tag":
+		return TypeTag
+	default:
+		return TypeInvalid
+	}
+}
+
+// Signature is an author or committer line
+type Signature struct {
+	Name  string
+	Email string
+	When  time.Time
+}
+
+// Commit is a parsed commit object
+type Commit struct {
+	Hash      Hash
+	Tree      Hash
+	Parents   []Hash
+	Author    Signature
+	Committer Signature
+	Message   string
+}
+
+// Subject returns the first line of the commit message
+func (c *Commit) Subject() string {
+	msg := strings.TrimLeft(c.Message, "\n")
+	if i := strings.IndexByte(msg, '\n'); i >= 0 {
+		msg = msg[:i]
+	}
+	return strings.TrimRight(msg, "\r")
+}
+
+func parseCommit(h Hash, data []byte) (*Commit, error) {
+	c := &Commit{Hash: h}
+	for len(data) > 0 {
+		nl := bytes.IndexByte(data, '\n')
+		if nl < 0 {
+			nl = len(data)
+		}
+		line := data[:nl]
+		if nl < len(data) {
+			data = data[nl+1:]
+		} else {
+			data = nil
+		}
+
+		// A blank line separates headers from the message
+		if len(line) == 0 {
+			c.Message = string(data)
+			break
+		}
+		// Continuation lines belong to multi-line headers such as gpgsig
+		if line[0] == ' ' {
+			continue
+		}
+
+		key, value, _ := strings.Cut(string(line), " ")
+		var err error
+		switch key {
+		case "tree":
+			c.Tree, err = ParseHash(value)
+		case "parent":
+			var p Hash
+			p, err = ParseHash(value)
+			c.Parents = append(c.Parents, p)
+		case "author":
+			c.Author, err = parseSignature(value)
+		case "committer":
+			c.Committer, err = parseSignature(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed commit %s: %v", h, err)
+		}
+	}
+	return c, nil
+}
+
+// parseSignature parses "Name <email> 1700000000 +0200"
+func parseSignature(s string) (Signature, error) {
+	var sig Signature
+	lt := strings.IndexByte(s, '<')
+	gt := strings.LastIndexByte(s, '>')
+	if lt < 0 || gt < lt {
+		return sig, fmt.Errorf("invalid signature %q", s)
+	}
+	sig.Name = strings.TrimSpace(s[:lt])
+	sig.Email = s[lt+1 : gt]
+
+	fields := strings.Fields(s[gt+1:])
+	if len(fields) == 0 {
+		return sig, nil
+	}
+	secs, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return sig, fmt.Errorf("invalid timestamp in %q", s)
+	}
+	loc := time.UTC
+	if len(fields) > 1 && len(fields[1]) == 5 {
+		tz := fields[1]
+		hours, herr := strconv.Atoi(tz[1:3])
+		mins, merr := strconv.Atoi(tz[3:5])
+		if herr == nil && merr == nil {
+			offset := hours*3600 + mins*60
+			if tz[0] == '-' {
+				offset = -offset
+			}
+			loc = time.FixedZone(tz, offset)
+		}
+	}
+	sig.When = time.Unix(secs, 0).In(loc)
+	return sig, nil
+}
+
+// TreeEntry is a single entry of a tree object
+type TreeEntry struct {
+	Name string
+	Mode uint32
+	Hash Hash
+}
+
+// File modes as stored in tree objects
+const (
+	ModeTree    = 0o040000
+	ModeBlob    = 0o100644
+	ModeExec    = 0o100755
+	ModeSymlink = 0o120000
+	ModeGitlink = 0o160000
+)
+
+// IsTree reports whether the entry is a subdirectory
+func (e TreeEntry) IsTree() bool {
+	return e.Mode&0o170000 == ModeTree
+}
+
+// IsGitlink reports whether the entry is a submodule commit
+func (e TreeEntry) IsGitlink() bool {
+	return e.Mode&0o170000 == ModeGitlink
+}
+
+func parseTree(data []byte) ([]TreeEntry, error) {
+	var entries []TreeEntry
+	for len(data) > 0 {
+		sp := bytes.IndexByte(data, ' ')
+		if sp < 0 {
+			return nil, fmt.Errorf("malformed tree entry")
+		}
+		mode, err := strconv.ParseUint(string(data[:sp]), 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("malformed tree mode: %v", err)
+		}
+		data = data[sp+1:]
+
+		nul := bytes.IndexByte(data, 0)
+		if nul < 0 || len(data) < nul+21 {
+			return nil, fmt.Errorf("malformed tree entry")
+		}
+		entry := TreeEntry{Name: string(data[:nul]), Mode: uint32(mode)}
+		copy(entry.Hash[:], data[nul+1:nul+21])
+		entries = append(entries, entry)
+		data = data[nul+21:]
+	}
+	return entries, nil
+}
+
+// parseTagTarget returns the object a tag points at
+func parseTagTarget(data []byte) (Hash, ObjectType, error) {
+	var target Hash
+	targetType := TypeInvalid
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			break
+		}
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "object":
+			h, err := ParseHash(value)
+			if err != nil {
+				return target, TypeInvalid, err
+			}
+			target = h
+		case "type":
+			targetType = parseObjectType(value)
+		}
+	}
+	if target.IsZero() {
+		return target, TypeInvalid, fmt.Errorf("malformed tag object")
+	}
+	return target, targetType, nil
+}
@@ -0,0 +1,298 @@
+package gitobj
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// packfile is a single .pack file together with its .idx
+type packfile struct {
+	path    string
+	file    *os.File
+	version uint32
+	fanout  [256]uint32
+	hashes  []byte // sorted object ids, 20 bytes each
+	offsets []byte // 4 byte offsets (v2) or 24 byte entries (v1)
+	large   []byte // 8 byte offsets for packs over 2GB (v2 only)
+}
+
+var idxMagic = []byte{0xff, 't', 'O', 'c'}
+
+// openPack loads the index for a .pack file
+func openPack(packPath string) (*packfile, error) {
+	idxPath := strings.TrimSuffix(packPath, ".pack") + ".idx"
+	idx, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pack index: %v", err)
+	}
+
+	p := &packfile{path: packPath}
+	if err := p.parseIndex(idx); err != nil {
+		return nil, fmt.Errorf("invalid pack index %s: %v", idxPath, err)
+	}
+
+	file, err := os.Open(packPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pack: %v", err)
+	}
+	p.file = file
+	return p, nil
+}
+
+func (p *packfile) parseIndex(idx []byte) error {
+	header := 0
+	p.version = 1
+	if bytes.HasPrefix(idx, idxMagic) {
+		if len(idx) < 8 {
+			return fmt.Errorf("truncated header")
+		}
+		p.version = binary.BigEndian.Uint32(idx[4:8])
+		if p.version != 2 {
+			return fmt.Errorf("unsupported index version %d", p.version)
+		}
+		header = 8
+	}
+
+	if len(idx) < header+256*4 {
+		return fmt.Errorf("truncated fanout table")
+	}
+	for i := 0; i < 256; i++ {
+		p.fanout[i] = binary.BigEndian.Uint32(idx[header+i*4:])
+	}
+	count := int(p.fanout[255])
+	rest := idx[header+256*4:]
+
+	if p.version == 1 {
+		// v1 entries are a 4 byte offset followed by the object id
+		if len(rest) < count*24 {
+			return fmt.Errorf("truncated object table")
+		}
+		p.offsets = rest[:count*24]
+		return nil
+	}
+
+	need := count*20 + count*4 + count*4
+	if len(rest) < need {
+		return fmt.Errorf("truncated object table")
+	}
+	p.hashes = rest[:count*20]
+	rest = rest[count*20:]
+	rest = rest[count*4:] // skip CRC32 table
+	p.offsets = rest[:count*4]
+	rest = rest[count*4:]
+	// Remaining bytes are large offsets followed by two trailing checksums
+	if len(rest) >= 40 {
+		p.large = rest[:len(rest)-40]
+	}
+	return nil
+}
+
+func (p *packfile) hashAt(i int) []byte {
+	if p.version == 1 {
+		return p.offsets[i*24+4 : i*24+24]
+	}
+	return p.hashes[i*20 : i*20+20]
+}
+
+// find returns the pack offset of h, or false when the pack does not hold it
+func (p *packfile) find(h Hash) (int64, bool) {
+	lo := 0
+	if h[0] > 0 {
+		lo = int(p.fanout[h[0]-1])
+	}
+	hi := int(p.fanout[h[0]])
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.hashAt(lo+i), h[:]) >= 0
+	})
+	if i >= hi || !bytes.Equal(p.hashAt(i), h[:]) {
+		return 0, false
+	}
+
+	if p.version == 1 {
+		return int64(binary.BigEndian.Uint32(p.offsets[i*24:])), true
+	}
+	off := binary.BigEndian.Uint32(p.offsets[i*4:])
+	if off&0x80000000 == 0 {
+		return int64(off), true
+	}
+	li := int(off & 0x7fffffff)
+	if len(p.large) < li*8+8 {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint64(p.large[li*8:])), true
+}
+
+func (p *packfile) close() error {
+	if p.file == nil {
+		return nil
+	}
+	return p.file.Close()
+}
+
+// packEntry is the raw header of an object inside a pack
+type packEntry struct {
+	typ      ObjectType
+	size     int64
+	dataOff  int64 // offset of the zlib stream
+	baseOff  int64 // for ofs-delta objects
+	baseHash Hash  // for ref-delta objects
+}
+
+func (p *packfile) readEntry(offset int64) (packEntry, error) {
+	var e packEntry
+	r := bufio.NewReaderSize(io.NewSectionReader(p.file, offset, 64), 64)
+
+	b, err := r.ReadByte()
+	if err != nil {
+		return e, fmt.Errorf("failed to read pack entry: %v", err)
+	}
+	n := int64(1)
+	e.typ = ObjectType((b >> 4) & 7)
+	e.size = int64(b & 0x0f)
+	shift := uint(4)
+	for b&0x80 != 0 {
+		if b, err = r.ReadByte(); err != nil {
+			return e, fmt.Errorf("failed to read pack entry: %v", err)
+		}
+		n++
+		e.size |= int64(b&0x7f) << shift
+		shift += 7
+	}
+
+	switch e.typ {
+	case typeOfsDelta:
+		if b, err = r.ReadByte(); err != nil {
+			return e, fmt.Errorf("failed to read delta offset: %v", err)
+		}
+		n++
+		rel := int64(b & 0x7f)
+		for b&0x80 != 0 {
+			if b, err = r.ReadByte(); err != nil {
+				return e, fmt.Errorf("failed to read delta offset: %v", err)
+			}
+			n++
+			rel = ((rel + 1) << 7) | int64(b&0x7f)
+		}
+		e.baseOff = offset - rel
+	case typeRefDelta:
+		if _, err := io.ReadFull(r, e.baseHash[:]); err != nil {
+			return e, fmt.Errorf("failed to read delta base: %v", err)
+		}
+		n += 20
+	}
+
+	e.dataOff = offset + n
+	return e, nil
+}
+
+// maxPrealloc caps buffers sized from pack and delta headers, which a
+// corrupt pack can set to anything. Larger objects grow their buffer as data
+// actually arrives.
+const maxPrealloc = 1 << 20
+
+// inflate decompresses size bytes of object data starting at offset
+func (p *packfile) inflate(offset, size int64) ([]byte, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("failed to inflate object: invalid size %d", size)
+	}
+	zr, err := zlib.NewReader(bufio.NewReader(io.NewSectionReader(p.file, offset, 1<<62)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to inflate object: %v", err)
+	}
+	defer zr.Close()
+
+	buf := bytes.NewBuffer(make([]byte, 0, min(size, maxPrealloc)))
+	if _, err := io.Copy(buf, io.LimitReader(zr, size)); err != nil {
+		return nil, fmt.Errorf("failed to inflate object: %v", err)
+	}
+	if int64(buf.Len()) != size {
+		return nil, fmt.Errorf("failed to inflate object: got %d bytes, header says %d", buf.Len(), size)
+	}
+	return buf.Bytes(), nil
+}
+
+// deltaHeaderSize reads one of the two size varints at the start of a delta
+func deltaHeaderSize(delta []byte) (int, []byte) {
+	size, shift := 0, uint(0)
+	for i, b := range delta {
+		size |= int(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			return size, delta[i+1:]
+		}
+	}
+	return size, nil
+}
+
+// applyDelta reconstructs an object from its base and a delta
+func applyDelta(base, delta []byte) ([]byte, error) {
+	srcSize, delta := deltaHeaderSize(delta)
+	if srcSize != len(base) {
+		return nil, fmt.Errorf("delta base size mismatch")
+	}
+	dstSize, delta := deltaHeaderSize(delta)
+	if dstSize < 0 {
+		return nil, fmt.Errorf("invalid delta result size")
+	}
+	out := make([]byte, 0, min(dstSize, maxPrealloc))
+
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		if op&0x80 != 0 {
+			// Copy a range out of the base object
+			var off, n int
+			for i := uint(0); i < 4; i++ {
+				if op&(1<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, fmt.Errorf("truncated delta")
+					}
+					off |= int(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			for i := uint(0); i < 3; i++ {
+				if op&(0x10<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, fmt.Errorf("truncated delta")
+					}
+					n |= int(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			if n == 0 {
+				n = 0x10000
+			}
+			if off+n > len(base) {
+				return nil, fmt.Errorf("delta copy out of range")
+			}
+			out = append(out, base[off:off+n]...)
+		} else if op != 0 {
+			// Insert literal bytes from the delta itself
+			n := int(op)
+			if n > len(delta) {
+				return nil, fmt.Errorf("truncated delta")
+			}
+			out = append(out, delta[:n]...)
+			delta = delta[n:]
+		} else {
+			return nil, fmt.Errorf("invalid delta opcode")
+		}
+		if len(out) > dstSize {
+			return nil, fmt.Errorf("delta result size mismatch")
+		}
+	}
+
+	if len(out) != dstSize {
+		return nil, fmt.Errorf("delta result size mismatch")
+	}
+	return out, nil
+}
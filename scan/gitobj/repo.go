@@ -0,0 +1,496 @@
+package gitobj
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxCacheBytes bounds the memory held by the delta base cache
+const maxCacheBytes = 32 << 20
+
+// Repository is an open git repository
+type Repository struct {
+	gitDir    string // per-worktree git dir, where HEAD lives
+	commonDir string // shared git dir holding objects and refs
+
+	objectDirs []string
+	packs      []*packfile
+	shallow    map[Hash]bool
+
+	mu         sync.Mutex
+	cache      map[cacheKey]cachedObject
+	cacheBytes int
+}
+
+type cacheKey struct {
+	pack   *packfile
+	offset int64
+}
+
+type cachedObject struct {
+	typ  ObjectType
+	data []byte
+}
+
+// Open opens the repository rooted at path. path may be a working tree
+// (with a .git directory or a .git file pointing elsewhere) or a bare
+// repository.
+func Open(path string) (*Repository, error) {
+	gitDir, err := FindGitDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Repository{
+		gitDir:    gitDir,
+		commonDir: gitDir,
+		cache:     make(map[cacheKey]cachedObject),
+	}
+
+	// Linked worktrees share objects and refs through commondir
+	r.commonDir = CommonDir(gitDir)
+
+	if err := r.checkFormat(); err != nil {
+		return nil, err
+	}
+
+	r.objectDirs = objectDirs(filepath.Join(r.commonDir, "objects"), 0)
+	for _, dir := range r.objectDirs {
+		matches, _ := filepath.Glob(filepath.Join(dir, "pack", "*.pack"))
+		for _, packPath := range matches {
+			p, err := openPack(packPath)
+			if err != nil {
+				r.Close()
+				return nil, err
+			}
+			r.packs = append(r.packs, p)
+		}
+	}
+
+	r.shallow = make(map[Hash]bool)
+	if data, err := os.ReadFile(filepath.Join(r.commonDir, "shallow")); err == nil {
+		for _, line := range strings.Fields(string(data)) {
+			if h, err := ParseHash(line); err == nil {
+				r.shallow[h] = true
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// FindGitDir resolves the git directory for a working tree or bare repository
+func FindGitDir(path string) (string, error) {
+	dotGit := filepath.Join(path, ".git")
+	info, err := os.Stat(dotGit)
+	switch {
+	case err == nil && info.IsDir():
+		return dotGit, nil
+	case err == nil:
+		// Worktrees and submodules use a file containing "gitdir: <path>"
+		data, err := os.ReadFile(dotGit)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %v", dotGit, err)
+		}
+		line := strings.TrimSpace(string(data))
+		if !strings.HasPrefix(line, "gitdir:") {
+			return "", fmt.Errorf("invalid gitdir file %s", dotGit)
+		}
+		target := strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(path, target)
+		}
+		return filepath.Clean(target), nil
+	}
+
+	if IsGitDir(path) {
+		return path, nil
+	}
+	return "", fmt.Errorf("not a git repository: %s", path)
+}
+
+// IsGitDir reports whether dir looks like a git directory (HEAD, objects and refs)
+func IsGitDir(dir string) bool {
+	if info, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil || info.IsDir() {
+		return false
+	}
+	common := CommonDir(dir)
+	for _, sub := range []string{"objects", "refs"} {
+		if info, err := os.Stat(filepath.Join(common, sub)); err != nil || !info.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+// CommonDir returns the directory holding the objects and refs of gitDir.
+// Linked worktrees point to their main repository's git directory through a
+// commondir file; every other git directory is its own common directory.
+func CommonDir(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	common := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(gitDir, common)
+	}
+	return filepath.Clean(common)
+}
+
+// objectDirs returns dir plus any alternates it references
+func objectDirs(dir string, depth int) []string {
+	dirs := []string{dir}
+	if depth > 5 {
+		return dirs
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "info", "alternates"))
+	if err != nil {
+		return dirs
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(dir, line)
+		}
+		dirs = append(dirs, objectDirs(filepath.Clean(line), depth+1)...)
+	}
+	return dirs
+}
+
+// checkFormat rejects repositories using extensions we cannot read
+func (r *Repository) checkFormat() error {
+	data, err := os.ReadFile(filepath.Join(r.commonDir, "config"))
+	if err != nil {
+		return nil
+	}
+	for _, line := range strings.Split(strings.ToLower(string(data)), "\n") {
+		key, value, ok := strings.Cut(strings.ReplaceAll(line, " ", ""), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), "\"")
+		if key == "objectformat" && value != "sha1" {
+			return fmt.Errorf("%w: object format %s", ErrUnsupported, value)
+		}
+		if key == "refstorage" && value != "files" {
+			return fmt.Errorf("%w: ref storage %s", ErrUnsupported, value)
+		}
+	}
+	return nil
+}
+
+// Close releases the pack files held by the repository
+func (r *Repository) Close() error {
+	var firstErr error
+	for _, p := range r.packs {
+		if err := p.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	r.packs = nil
+	return firstErr
+}
+
+// GitDir returns the per-worktree git directory
+func (r *Repository) GitDir() string {
+	return r.gitDir
+}
+
+// CommonDir returns the git directory holding shared objects and refs
+func (r *Repository) CommonDir() string {
+	return r.commonDir
+}
+
+// ReadObject returns the type and contents of an object
+func (r *Repository) ReadObject(h Hash) (ObjectType, []byte, error) {
+	for _, p := range r.packs {
+		if off, ok := p.find(h); ok {
+			return r.readPacked(p, off, 0)
+		}
+	}
+	for _, dir := range r.objectDirs {
+		typ, data, err := readLoose(dir, h)
+		if err == nil {
+			return typ, data, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return TypeInvalid, nil, err
+		}
+	}
+	return TypeInvalid, nil, fmt.Errorf("%w: %s", ErrNotFound, h)
+}
+
+func readLoose(dir string, h Hash) (ObjectType, []byte, error) {
+	hex := h.String()
+	file, err := os.Open(filepath.Join(dir, hex[:2], hex[2:]))
+	if err != nil {
+		return TypeInvalid, nil, err
+	}
+	defer file.Close()
+
+	zr, err := zlib.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return TypeInvalid, nil, fmt.Errorf("failed to inflate %s: %v", hex, err)
+	}
+	defer zr.Close()
+
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return TypeInvalid, nil, fmt.Errorf("failed to inflate %s: %v", hex, err)
+	}
+	nul := bytes.IndexByte(raw, 0)
+	if nul < 0 {
+		return TypeInvalid, nil, fmt.Errorf("malformed loose object %s", hex)
+	}
+	typeName, sizeStr, _ := strings.Cut(string(raw[:nul]), " ")
+	size, err := strconv.Atoi(sizeStr)
+	if err != nil || size != len(raw)-nul-1 {
+		return TypeInvalid, nil, fmt.Errorf("malformed loose object %s", hex)
+	}
+	return parseObjectType(typeName), raw[nul+1:], nil
+}
+
+func (r *Repository) readPacked(p *packfile, offset int64, depth int) (ObjectType, []byte, error) {
+	if depth > 10000 {
+		return TypeInvalid, nil, fmt.Errorf("delta chain too deep in %s", p.path)
+	}
+
+	key := cacheKey{p, offset}
+	r.mu.Lock()
+	if obj, ok := r.cache[key]; ok {
+		r.mu.Unlock()
+		return obj.typ, obj.data, nil
+	}
+	r.mu.Unlock()
+
+	entry, err := p.readEntry(offset)
+	if err != nil {
+		return TypeInvalid, nil, err
+	}
+	data, err := p.inflate(entry.dataOff, entry.size)
+	if err != nil {
+		return TypeInvalid, nil, err
+	}
+
+	typ := entry.typ
+	switch entry.typ {
+	case typeOfsDelta, typeRefDelta:
+		var baseType ObjectType
+		var base []byte
+		if entry.typ == typeOfsDelta {
+			baseType, base, err = r.readPacked(p, entry.baseOff, depth+1)
+		} else {
+			baseType, base, err = r.ReadObject(entry.baseHash)
+		}
+		if err != nil {
+			return TypeInvalid, nil, err
+		}
+		if data, err = applyDelta(base, data); err != nil {
+			return TypeInvalid, nil, fmt.Errorf("failed to apply delta in %s: %v", p.path, err)
+		}
+		typ = baseType
+	}
+
+	r.remember(key, cachedObject{typ, data})
+	return typ, data, nil
+}
+
+// remember stores a decoded pack object so delta chains can reuse it
+func (r *Repository) remember(key cacheKey, obj cachedObject) {
+	if len(obj.data) > maxCacheBytes/8 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cacheBytes+len(obj.data) > maxCacheBytes {
+		r.cache = make(map[cacheKey]cachedObject)
+		r.cacheBytes = 0
+	}
+	r.cache[key] = obj
+	r.cacheBytes += len(obj.data)
+}
+
+// Commit reads and parses a commit, peeling annotated tags
+func (r *Repository) Commit(h Hash) (*Commit, error) {
+	for i := 0; i < 10; i++ {
+		typ, data, err := r.ReadObject(h)
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case TypeCommit:
+			return parseCommit(h, data)
+		case TypeTag:
+			if h, _, err = parseTagTarget(data); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("%w: %s is a %s", errNotCommit, h, typ)
+		}
+	}
+	return nil, fmt.Errorf("tag chain too deep at %s", h)
+}
+
+// Tree reads and parses a tree object
+func (r *Repository) Tree(h Hash) ([]TreeEntry, error) {
+	typ, data, err := r.ReadObject(h)
+	if err != nil {
+		return nil, err
+	}
+	if typ != TypeTree {
+		return nil, fmt.Errorf("object %s is a %s, not a tree", h, typ)
+	}
+	return parseTree(data)
+}
+
+// Blob reads the contents of a blob object
+func (r *Repository) Blob(h Hash) ([]byte, error) {
+	typ, data, err := r.ReadObject(h)
+	if err != nil {
+		return nil, err
+	}
+	if typ != TypeBlob {
+		return nil, fmt.Errorf("object %s is a %s, not a blob", h, typ)
+	}
+	return data, nil
+}
+
+// Head resolves HEAD to a commit id
+func (r *Repository) Head() (Hash, error) {
+	return r.ResolveRef("HEAD")
+}
+
+// ResolveRef resolves a ref name (e.g. HEAD or refs/heads/main), following
+// symbolic refs
+func (r *Repository) ResolveRef(name string) (Hash, error) {
+	var packed map[string]Hash
+	for i := 0; i < 10; i++ {
+		target, symbolic, err := r.readLooseRef(name)
+		if err == nil {
+			if !symbolic {
+				return ParseHash(target)
+			}
+			name = target
+			continue
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return ZeroHash, err
+		}
+		if packed == nil {
+			if packed, err = r.packedRefs(); err != nil {
+				return ZeroHash, err
+			}
+		}
+		if h, ok := packed[name]; ok {
+			return h, nil
+		}
+		return ZeroHash, fmt.Errorf("%w: ref %s", ErrNotFound, name)
+	}
+	return ZeroHash, fmt.Errorf("symbolic ref loop at %s", name)
+}
+
+// readLooseRef returns the contents of a loose ref file and whether it is
+// symbolic
+func (r *Repository) readLooseRef(name string) (string, bool, error) {
+	dir := r.commonDir
+	if name == "HEAD" || !strings.HasPrefix(name, "refs/") {
+		dir = r.gitDir
+	}
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		return "", false, err
+	}
+	content := strings.TrimSpace(string(data))
+	if strings.HasPrefix(content, "ref:") {
+		return strings.TrimSpace(strings.TrimPrefix(content, "ref:")), true, nil
+	}
+	return content, false, nil
+}
+
+func (r *Repository) packedRefs() (map[string]Hash, error) {
+	refs := make(map[string]Hash)
+	data, err := os.ReadFile(filepath.Join(r.commonDir, "packed-refs"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return refs, nil
+		}
+		return nil, fmt.Errorf("failed to read packed-refs: %v", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+		hash, name, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		if h, err := ParseHash(hash); err == nil {
+			refs[strings.TrimSpace(name)] = h
+		}
+	}
+	return refs, nil
+}
+
+// Refs returns every ref under refs/ together with HEAD, resolved to object
+// ids. This is the starting set used by `git log --all`.
+func (r *Repository) Refs() (map[string]Hash, error) {
+	refs, err := r.packedRefs()
+	if err != nil {
+		return nil, err
+	}
+
+	var symbolic []string
+	refsDir := filepath.Join(r.commonDir, "refs")
+	err = filepath.WalkDir(refsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == refsDir && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(r.commonDir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		target, isSymbolic, err := r.readLooseRef(name)
+		if err != nil {
+			return nil
+		}
+		if isSymbolic {
+			symbolic = append(symbolic, name)
+			return nil
+		}
+		if h, err := ParseHash(target); err == nil {
+			refs[name] = h
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read refs: %v", err)
+	}
+
+	for _, name := range append(symbolic, "HEAD") {
+		if h, err := r.ResolveRef(name); err == nil {
+			refs[name] = h
+		}
+	}
+	return refs, nil
+}
@@ -0,0 +1,218 @@
+package gitobj
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Walk flags
+const (
+	flagSeen = 1 << iota
+	flagHidden
+	flagQueued
+)
+
+// Walk visits every commit reachable from tips but not from exclude, newest
+// first by committer date. This matches `git log <tips> --not <exclude>`.
+// Returning an error from fn stops the walk and returns that error.
+func (r *Repository) Walk(tips []Hash, exclude []Hash, fn func(*Commit) error) error {
+	flags := make(map[Hash]uint8)
+	queue := &commitQueue{}
+	visible := 0 // queued commits that are not hidden
+
+	hide := func(h Hash) {
+		if flags[h]&(flagQueued|flagHidden) == flagQueued {
+			visible--
+		}
+		flags[h] |= flagHidden
+	}
+
+	push := func(h Hash, hidden bool) error {
+		if flags[h]&flagSeen != 0 {
+			if hidden {
+				hide(h)
+			}
+			return nil
+		}
+		c, err := r.Commit(h)
+		if err != nil {
+			return err
+		}
+		// Annotated tags are peeled, so continue with the commit id
+		if c.Hash != h {
+			flags[h] |= flagSeen
+			if flags[c.Hash]&flagSeen != 0 {
+				if hidden {
+					hide(c.Hash)
+				}
+				return nil
+			}
+		}
+		flags[c.Hash] |= flagSeen | flagQueued
+		if hidden {
+			flags[c.Hash] |= flagHidden
+		} else {
+			visible++
+		}
+		heap.Push(queue, c)
+		return nil
+	}
+
+	for _, h := range exclude {
+		if err := push(h, true); err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, errNotCommit) {
+			return err
+		}
+	}
+	for _, h := range tips {
+		if err := push(h, false); err != nil {
+			if errors.Is(err, errNotCommit) {
+				continue
+			}
+			return err
+		}
+	}
+
+	// Once only excluded commits remain nothing else can be emitted
+	for visible > 0 {
+		c := heap.Pop(queue).(*Commit)
+		hidden := flags[c.Hash]&flagHidden != 0
+		flags[c.Hash] &^= flagQueued
+		if !hidden {
+			visible--
+		}
+
+		if !r.shallow[c.Hash] {
+			for _, p := range c.Parents {
+				if err := push(p, hidden); err != nil {
+					return err
+				}
+			}
+		}
+
+		if !hidden {
+			if err := fn(c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var errStopWalk = errors.New("stop walk")
+
+// IsAncestor reports whether ancestor is reachable from descendant
+func (r *Repository) IsAncestor(ancestor, descendant Hash) (bool, error) {
+	target, err := r.Commit(ancestor)
+	if err != nil {
+		return false, err
+	}
+	// Allow for a day of clock skew, as git does when limiting walks
+	cutoff := target.Committer.When.Add(-24 * time.Hour)
+
+	found := false
+	err = r.Walk([]Hash{descendant}, nil, func(c *Commit) error {
+		if c.Hash == target.Hash {
+			found = true
+			return errStopWalk
+		}
+		if c.Committer.When.Before(cutoff) {
+			return errStopWalk
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopWalk) {
+		return false, err
+	}
+	return found, nil
+}
+
+// RootCommit follows first parents from a commit back to the commit that
+// started its history. Clones and forks of a project share it.
+func (r *Repository) RootCommit(from Hash) (Hash, error) {
+	for {
+		c, err := r.Commit(from)
+		if err != nil {
+			return Hash{}, err
+		}
+		if len(c.Parents) == 0 {
+			return c.Hash, nil
+		}
+		from = c.Parents[0]
+	}
+}
+
+// Tips returns the distinct commit ids pointed at by refs and HEAD, in a
+// stable order
+func (r *Repository) Tips() ([]Hash, error) {
+	refs, err := r.Refs()
+	if err != nil {
+		return nil, err
+	}
+	unique := make(map[Hash]bool, len(refs))
+	tips := make([]Hash, 0, len(refs))
+	for _, h := range refs {
+		if !unique[h] {
+			unique[h] = true
+			tips = append(tips, h)
+		}
+	}
+	sort.Slice(tips, func(i, j int) bool {
+		return tips[i].String() < tips[j].String()
+	})
+	return tips, nil
+}
+
+// RefCommits returns the commit each ref points at, peeling annotated tags.
+// Refs that do not lead to a commit are left out.
+func (r *Repository) RefCommits() (map[string]Hash, error) {
+	refs, err := r.Refs()
+	if err != nil {
+		return nil, err
+	}
+	commits := make(map[string]Hash, len(refs))
+	for name, h := range refs {
+		c, err := r.Commit(h)
+		if err != nil {
+			if errors.Is(err, errNotCommit) {
+				continue
+			}
+			return nil, fmt.Errorf("resolving %s: %w", name, err)
+		}
+		commits[name] = c.Hash
+	}
+	return commits, nil
+}
+
+// Log returns every commit reachable from any ref or HEAD, newest first,
+// like `git log --all`
+func (r *Repository) Log() ([]*Commit, error) {
+	tips, err := r.Tips()
+	if err != nil {
+		return nil, err
+	}
+	var commits []*Commit
+	err = r.Walk(tips, nil, func(c *Commit) error {
+		commits = append(commits, c)
+		return nil
+	})
+	return commits, err
+}
+
+// commitQueue orders commits newest first by committer date
+type commitQueue []*Commit
+
+func (q commitQueue) Len() int { return len(q) }
+func (q commitQueue) Less(i, j int) bool {
+	return q[i].Committer.When.After(q[j].Committer.When)
+}
+func (q commitQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *commitQueue) Push(x any)   { *q = append(*q, x.(*Commit)) }
+func (q *commitQueue) Pop() any {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
@@ -0,0 +1,323 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/AccursedGalaxy/streakode/config"
+	"github.com/AccursedGalaxy/streakode/scan/gitobj"
+)
+
+// FormatIdentity renders an identity the way git prints it
+func FormatIdentity(name, email string) string {
+	return name + " <" + email + ">"
+}
+
+// ParseIdentity splits "Name <email>" into its parts. A string without an
+// email is returned as a name.
+func ParseIdentity(identity string) (name, email string) {
+	lt := strings.LastIndexByte(identity, '<')
+	gt := strings.LastIndexByte(identity, '>')
+	if lt < 0 || gt < lt {
+		return strings.TrimSpace(identity), ""
+	}
+	return strings.TrimSpace(identity[:lt]), identity[lt+1 : gt]
+}
+
+// IdentityMatcher decides whether a commit author is one of a person's
+// identities
+type IdentityMatcher struct {
+	author     *regexp.Regexp // git --author style pattern, nil when unset
+	authorText string
+	names      map[string]bool
+	emails     map[string]bool
+	patterns   []*regexp.Regexp
+	identities int
+}
+
+// NewIdentityMatcher builds a matcher from an author pattern, matched like
+// `git log --author` against "Name <email>", and a list of extra identities.
+// Identities are exact names or emails (compared case-insensitively), or
+// regular expressions written as /pattern/. A matcher without any pattern
+// accepts every author.
+func NewIdentityMatcher(author string, identities []string) *IdentityMatcher {
+	m := &IdentityMatcher{
+		names:  make(map[string]bool),
+		emails: make(map[string]bool),
+	}
+	if author != "" {
+		m.author = compileAuthorPattern(author)
+		m.authorText = author
+	}
+
+	for _, identity := range identities {
+		identity = strings.TrimSpace(identity)
+		switch {
+		case identity == "":
+			continue
+		case len(identity) > 2 && strings.HasPrefix(identity, "/") && strings.HasSuffix(identity, "/"):
+			re, err := regexp.Compile(identity[1 : len(identity)-1])
+			if err != nil {
+				re = regexp.MustCompile(regexp.QuoteMeta(identity))
+			}
+			m.patterns = append(m.patterns, re)
+		case strings.Contains(identity, "<"):
+			name, email := ParseIdentity(identity)
+			if name != "" {
+				m.names[strings.ToLower(name)] = true
+			}
+			if email != "" {
+				m.emails[strings.ToLower(email)] = true
+			}
+		case strings.Contains(identity, "@"):
+			m.emails[strings.ToLower(identity)] = true
+		default:
+			m.names[strings.ToLower(identity)] = true
+		}
+		m.identities++
+	}
+	return m
+}
+
+// ConfiguredIdentities returns the matcher for the configured author and all
+// of their additional identities
+func ConfiguredIdentities() *IdentityMatcher {
+	return NewIdentityMatcher(config.AppConfig.Author, config.AppConfig.Identities)
+}
+
+// authorIdentities is the matcher used when scanning for author: the author
+// pattern plus every identity configured for them. An empty author matches
+// everyone.
+func authorIdentities(author string) *IdentityMatcher {
+	if author == "" {
+		return nil
+	}
+	return NewIdentityMatcher(author, config.AppConfig.Identities)
+}
+
+// identityKey names the author and the identities configured for them, so
+// scans made for other identities are read again
+func identityKey(author string) string {
+	var identities []string
+	for _, identity := range config.AppConfig.Identities {
+		if identity = strings.TrimSpace(identity); identity != "" {
+			identities = append(identities, identity)
+		}
+	}
+	sort.Strings(identities)
+	return strings.Join(append([]string{author}, identities...), "\n")
+}
+
+// compileAuthorPattern mirrors git's --author, falling back to a literal
+// match when the pattern is not a valid regular expression
+func compileAuthorPattern(author string) *regexp.Regexp {
+	re, err := regexp.Compile(author)
+	if err != nil {
+		re = regexp.MustCompile(regexp.QuoteMeta(author))
+	}
+	return re
+}
+
+// MatchesAll reports whether the matcher accepts every author
+func (m *IdentityMatcher) MatchesAll() bool {
+	return m == nil || (m.author == nil && m.identities == 0)
+}
+
+// HasIdentities reports whether identities beyond the author pattern are set
+func (m *IdentityMatcher) HasIdentities() bool {
+	return m != nil && m.identities > 0
+}
+
+// Matches reports whether name and email belong to this person
+func (m *IdentityMatcher) Matches(name, email string) bool {
+	if m.MatchesAll() {
+		return true
+	}
+	full := FormatIdentity(name, email)
+	if m.author != nil && m.author.MatchString(full) {
+		return true
+	}
+	if m.names[strings.ToLower(name)] || (email != "" && m.emails[strings.ToLower(email)]) {
+		return true
+	}
+	for _, re := range m.patterns {
+		if re.MatchString(full) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesIdentity is Matches for an identity formatted as "Name <email>"
+func (m *IdentityMatcher) MatchesIdentity(identity string) bool {
+	name, email := ParseIdentity(identity)
+	return m.Matches(name, email)
+}
+
+// MatchesCommit reports whether a cached commit belongs to this person
+func (m *IdentityMatcher) MatchesCommit(commit CommitHistory) bool {
+	if commit.Identity != "" {
+		return m.MatchesIdentity(commit.Identity)
+	}
+	return m.MatchesIdentity(commit.Author)
+}
+
+// coAuthorTrailer is the trailer crediting commit co-authors
+const coAuthorTrailer = "Co-authored-by"
+
+// CoAuthors returns the identities a commit credits as co-authors
+func CoAuthors(commit CommitHistory) []string {
+	return commit.Trailers[coAuthorTrailer]
+}
+
+// MatchesCoAuthor reports whether any of the co-author identities belongs to
+// this person. A matcher accepting every author matches no co-author, since
+// every commit already belongs to it.
+func (m *IdentityMatcher) MatchesCoAuthor(coAuthors []string) bool {
+	if m.MatchesAll() {
+		return false
+	}
+	for _, coAuthor := range coAuthors {
+		if m.MatchesIdentity(coAuthor) {
+			return true
+		}
+	}
+	return false
+}
+
+// countCoAuthored reports whether commits crediting the author as a
+// co-author count toward streaks and goals
+func countCoAuthored() bool {
+	return config.AppConfig.CoAuthorSettings.CountTowardStreaks
+}
+
+// mailmapEntry is a single .mailmap rule
+type mailmapEntry struct {
+	properName, properEmail string
+	commitName, commitEmail string // commitName is optional
+}
+
+// Mailmap maps commit identities to canonical ones, as described in
+// gitmailmap(5)
+type Mailmap struct {
+	entries []mailmapEntry
+}
+
+// ParseMailmap parses the contents of a .mailmap file
+func ParseMailmap(data []byte) *Mailmap {
+	m := &Mailmap{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		// Collect up to two "Name <email>" pairs
+		var names, emails []string
+		rest := line
+		for len(emails) < 2 {
+			lt := strings.IndexByte(rest, '<')
+			gt := strings.IndexByte(rest, '>')
+			if lt < 0 || gt < lt {
+				break
+			}
+			names = append(names, strings.TrimSpace(rest[:lt]))
+			emails = append(emails, strings.TrimSpace(rest[lt+1:gt]))
+			rest = rest[gt+1:]
+		}
+
+		switch len(emails) {
+		case 1:
+			// Proper Name <commit@email>
+			if names[0] != "" {
+				m.entries = append(m.entries, mailmapEntry{properName: names[0], commitEmail: emails[0]})
+			}
+		case 2:
+			// [Proper Name] <proper@email> [Commit Name] <commit@email>
+			m.entries = append(m.entries, mailmapEntry{
+				properName:  names[0],
+				properEmail: emails[0],
+				commitName:  names[1],
+				commitEmail: emails[1],
+			})
+		}
+	}
+	return m
+}
+
+// Resolve returns the canonical name and email for a commit identity
+func (m *Mailmap) Resolve(name, email string) (string, string) {
+	if m == nil {
+		return name, email
+	}
+
+	// Entries naming the commit author take precedence over email-only ones
+	var best *mailmapEntry
+	for i := range m.entries {
+		e := &m.entries[i]
+		if !strings.EqualFold(e.commitEmail, email) {
+			continue
+		}
+		if e.commitName != "" {
+			if strings.EqualFold(e.commitName, name) {
+				best = e
+				break
+			}
+			continue
+		}
+		if best == nil {
+			best = e
+		}
+	}
+	if best == nil {
+		return name, email
+	}
+	if best.properName != "" {
+		name = best.properName
+	}
+	if best.properEmail != "" {
+		email = best.properEmail
+	}
+	return name, email
+}
+
+// readMailmap loads a repository's .mailmap from the working tree, or from
+// HEAD for bare repositories. A missing file yields an empty mailmap.
+func readMailmap(repoPath string) *Mailmap {
+	if data, err := os.ReadFile(filepath.Join(repoPath, ".mailmap")); err == nil {
+		return ParseMailmap(data)
+	}
+
+	repo, err := gitobj.Open(repoPath)
+	if err != nil {
+		return nil
+	}
+	defer repo.Close()
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil
+	}
+	commit, err := repo.Commit(head)
+	if err != nil {
+		return nil
+	}
+	entries, err := repo.Tree(commit.Tree)
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		if entry.Name == ".mailmap" && !entry.IsTree() {
+			if data, err := repo.Blob(entry.Hash); err == nil {
+				return ParseMailmap(data)
+			}
+		}
+	}
+	return nil
+}
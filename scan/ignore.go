@@ -0,0 +1,279 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFile is the name of the per-directory exclusion file honored during
+// discovery, written like a .gitignore
+const IgnoreFile = ".streakodeignore"
+
+// IgnoreRule is a single gitignore style exclusion rule
+type IgnoreRule struct {
+	Source  string // "excluded_patterns", "excluded_paths" or an ignore file
+	Line    int    // line within an ignore file, 0 for config entries
+	Pattern string // the rule as written
+
+	base     string // directory the rule is relative to, "" for every scan directory
+	glob     string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+func (r IgnoreRule) String() string {
+	if r.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", r.Source, r.Line, r.Pattern)
+	}
+	return fmt.Sprintf("%s: %s", r.Source, r.Pattern)
+}
+
+// parseIgnoreRule parses one line of gitignore syntax. Blank lines and
+// comments yield false.
+func parseIgnoreRule(line string) (IgnoreRule, bool) {
+	rule := IgnoreRule{Pattern: strings.TrimSpace(line)}
+
+	// Trailing spaces are ignored unless escaped
+	line = strings.TrimLeft(line, " \t")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	// A slash anywhere but the end anchors the pattern to its base directory
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return rule, false
+	}
+	rule.glob = line
+	return rule, true
+}
+
+// matches reports whether the rule matches rel, a slash separated path
+// relative to the rule's base directory
+func (r IgnoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.anchored {
+		return matchGlob(r.glob, rel)
+	}
+	return matchSegment(r.glob, path.Base(rel))
+}
+
+// Exclusions decides which directories discovery skips. Rules follow
+// .gitignore semantics: the last matching rule wins, "!" re-includes a path,
+// and nothing below an excluded directory can be re-included. Config rules
+// come first, followed by .streakodeignore files from the top down, so deeper
+// files override shallower ones.
+type Exclusions struct {
+	roots  []string
+	rules  []IgnoreRule
+	loaded map[string]bool // directories whose ignore file has been read
+}
+
+// NewExclusions builds exclusions for the scan directories. Patterns are
+// relative to every scan directory; paths are absolute and may start with "~/".
+func NewExclusions(dirs []string, patterns []string, paths []string) *Exclusions {
+	e := &Exclusions{loaded: make(map[string]bool)}
+	for _, dir := range dirs {
+		e.roots = append(e.roots, filepath.Clean(dir))
+	}
+
+	for _, pattern := range patterns {
+		if rule, ok := parseIgnoreRule(pattern); ok {
+			rule.Source = "excluded_patterns"
+			e.rules = append(e.rules, rule)
+		}
+	}
+
+	for _, excluded := range paths {
+		negate := strings.HasPrefix(excluded, "!")
+		excluded = expandHome(strings.TrimPrefix(excluded, "!"))
+		if !filepath.IsAbs(excluded) {
+			continue
+		}
+		rule, ok := parseIgnoreRule(filepath.ToSlash(excluded))
+		if !ok {
+			continue
+		}
+		rule.Source = "excluded_paths"
+		rule.Pattern = strings.TrimPrefix(rule.Pattern, "!")
+		rule.negate = negate
+		rule.base = filepath.VolumeName(excluded) + string(filepath.Separator)
+		rule.anchored = true
+		e.rules = append(e.rules, rule)
+	}
+	return e
+}
+
+// expandHome replaces a leading "~/" with the home directory
+func expandHome(p string) string {
+	if !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(home, p[2:])
+}
+
+// LoadIgnoreFile reads the .streakodeignore file in dir, if there is one.
+// Each directory is read at most once.
+func (e *Exclusions) LoadIgnoreFile(dir string) error {
+	if e == nil || e.loaded[dir] {
+		return nil
+	}
+	e.loaded[dir] = true
+
+	file := filepath.Join(dir, IgnoreFile)
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", file, err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+			rule.Source = file
+			rule.Line = line
+			rule.base = dir
+			e.rules = append(e.rules, rule)
+		}
+	}
+	return nil
+}
+
+// lookup returns the last rule matching p, ignoring its parent directories
+func (e *Exclusions) lookup(p string, isDir bool) *IgnoreRule {
+	if e == nil {
+		return nil
+	}
+	var match *IgnoreRule
+	for i := range e.rules {
+		rule := &e.rules[i]
+		for _, base := range e.basesOf(rule) {
+			rel, ok := relativeTo(base, p)
+			if !ok || rel == "." {
+				continue
+			}
+			if rule.matches(rel, isDir) {
+				match = rule
+				break
+			}
+		}
+	}
+	return match
+}
+
+// basesOf returns the directories a rule is relative to
+func (e *Exclusions) basesOf(rule *IgnoreRule) []string {
+	if rule.base == "" {
+		return e.roots
+	}
+	return []string{rule.base}
+}
+
+// relativeTo returns p as a slash separated path relative to base, and false
+// when p lies outside base
+func relativeTo(base, p string) (string, bool) {
+	rel, err := filepath.Rel(base, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// excludedBy returns the rule excluding the directory p, or nil when it is
+// included. Parent directories are assumed to have been checked already.
+func (e *Exclusions) excludedBy(p string) *IgnoreRule {
+	if rule := e.lookup(p, true); rule != nil && !rule.negate {
+		return rule
+	}
+	return nil
+}
+
+// Explanation describes why a path is or is not excluded
+type Explanation struct {
+	Path     string
+	Root     string // scan directory containing the path, "" if none does
+	Excluded bool
+	Rule     *IgnoreRule // deciding rule, nil when no rule matched
+	Via      string      // excluded parent directory the rule matched, if any
+}
+
+// Explain checks p and each of its parents below the scan directory against
+// the rules, loading the ignore files along the way
+func (e *Exclusions) Explain(p string) Explanation {
+	p = filepath.Clean(expandHome(p))
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	explanation := Explanation{Path: p}
+
+	for _, root := range e.roots {
+		if _, ok := relativeTo(root, p); ok {
+			explanation.Root = root
+			break
+		}
+	}
+
+	// Walk down from the scan directory, or the filesystem root when the path
+	// lies outside every scan directory
+	var chain []string
+	for dir := p; ; dir = filepath.Dir(dir) {
+		chain = append([]string{dir}, chain...)
+		if dir == explanation.Root || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	for i, dir := range chain {
+		isDir := true
+		if i == len(chain)-1 {
+			info, err := os.Stat(dir)
+			isDir = err != nil || info.IsDir()
+		}
+		rule := e.lookup(dir, isDir)
+		if i == len(chain)-1 {
+			explanation.Rule = rule
+			explanation.Excluded = rule != nil && !rule.negate
+			break
+		}
+		if rule != nil && !rule.negate {
+			explanation.Rule = rule
+			explanation.Excluded = true
+			explanation.Via = dir
+			break
+		}
+		if explanation.Root != "" {
+			e.LoadIgnoreFile(dir)
+		}
+	}
+	return explanation
+}
@@ -0,0 +1,387 @@
+package scan
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// languageByFilename maps well-known file names to their language
+var languageByFilename = map[string]string{
+	"makefile":          "Makefile",
+	"gnumakefile":       "Makefile",
+	"dockerfile":        "Dockerfile",
+	"containerfile":     "Dockerfile",
+	"cmakelists.txt":    "CMake",
+	"rakefile":          "Ruby",
+	"gemfile":           "Ruby",
+	"guardfile":         "Ruby",
+	"podfile":           "Ruby",
+	"vagrantfile":       "Ruby",
+	"jenkinsfile":       "Groovy",
+	"justfile":          "Just",
+	"build.bazel":       "Starlark",
+	"build":             "Starlark",
+	"workspace":         "Starlark",
+	"meson.build":       "Meson",
+	"go.mod":            "Go Module",
+	"go.work":           "Go Module",
+	".bashrc":           "Shell",
+	".bash_profile":     "Shell",
+	".zshrc":            "Shell",
+	".profile":          "Shell",
+	"pkgbuild":          "Shell",
+	".vimrc":            "Vim Script",
+	"_vimrc":            "Vim Script",
+	".emacs":            "Emacs Lisp",
+	"procfile":          "Procfile",
+	"license":           "Text",
+	"copying":           "Text",
+	"readme":            "Text",
+	"requirements.txt":  "Pip Requirements",
+	"nginx.conf":        "Nginx",
+	"cargo.lock":        "TOML",
+	"pipfile":           "TOML",
+	"tsconfig.json":     "JSON with Comments",
+	"jsconfig.json":     "JSON with Comments",
+	".eslintrc.json":    "JSON with Comments",
+	"devcontainer.json": "JSON with Comments",
+}
+
+// languageByExtension maps lower-case file extensions to their language
+var languageByExtension = map[string]string{
+	".go":         "Go",
+	".py":         "Python",
+	".pyi":        "Python",
+	".pyw":        "Python",
+	".ipynb":      "Jupyter Notebook",
+	".js":         "JavaScript",
+	".mjs":        "JavaScript",
+	".cjs":        "JavaScript",
+	".jsx":        "JavaScript",
+	".ts":         "TypeScript",
+	".mts":        "TypeScript",
+	".cts":        "TypeScript",
+	".tsx":        "TSX",
+	".vue":        "Vue",
+	".svelte":     "Svelte",
+	".rs":         "Rust",
+	".c":          "C",
+	".cc":         "C++",
+	".cpp":        "C++",
+	".cxx":        "C++",
+	".c++":        "C++",
+	".hh":         "C++",
+	".hpp":        "C++",
+	".hxx":        "C++",
+	".h++":        "C++",
+	".ipp":        "C++",
+	".m":          "Objective-C",
+	".mm":         "Objective-C++",
+	".cs":         "C#",
+	".fs":         "F#",
+	".fsx":        "F#",
+	".vb":         "Visual Basic .NET",
+	".java":       "Java",
+	".kt":         "Kotlin",
+	".kts":        "Kotlin",
+	".scala":      "Scala",
+	".sc":         "Scala",
+	".groovy":     "Groovy",
+	".gradle":     "Groovy",
+	".clj":        "Clojure",
+	".cljs":       "Clojure",
+	".cljc":       "Clojure",
+	".swift":      "Swift",
+	".dart":       "Dart",
+	".rb":         "Ruby",
+	".erb":        "HTML+ERB",
+	".gemspec":    "Ruby",
+	".rake":       "Ruby",
+	".php":        "PHP",
+	".pl":         "Perl",
+	".pm":         "Perl",
+	".lua":        "Lua",
+	".r":          "R",
+	".jl":         "Julia",
+	".ex":         "Elixir",
+	".exs":        "Elixir",
+	".erl":        "Erlang",
+	".hrl":        "Erlang",
+	".hs":         "Haskell",
+	".lhs":        "Haskell",
+	".ml":         "OCaml",
+	".mli":        "OCaml",
+	".elm":        "Elm",
+	".zig":        "Zig",
+	".nim":        "Nim",
+	".v":          "V",
+	".d":          "D",
+	".cr":         "Crystal",
+	".asm":        "Assembly",
+	".s":          "Assembly",
+	".sh":         "Shell",
+	".bash":       "Shell",
+	".zsh":        "Shell",
+	".ksh":        "Shell",
+	".fish":       "Fish",
+	".ps1":        "PowerShell",
+	".psm1":       "PowerShell",
+	".bat":        "Batchfile",
+	".cmd":        "Batchfile",
+	".vim":        "Vim Script",
+	".el":         "Emacs Lisp",
+	".lisp":       "Common Lisp",
+	".scm":        "Scheme",
+	".rkt":        "Racket",
+	".html":       "HTML",
+	".htm":        "HTML",
+	".xhtml":      "HTML",
+	".css":        "CSS",
+	".scss":       "SCSS",
+	".sass":       "Sass",
+	".less":       "Less",
+	".xml":        "XML",
+	".xsl":        "XSLT",
+	".svg":        "SVG",
+	".json":       "JSON",
+	".jsonc":      "JSON with Comments",
+	".json5":      "JSON5",
+	".yaml":       "YAML",
+	".yml":        "YAML",
+	".toml":       "TOML",
+	".ini":        "INI",
+	".cfg":        "INI",
+	".md":         "Markdown",
+	".markdown":   "Markdown",
+	".mdx":        "MDX",
+	".rst":        "reStructuredText",
+	".adoc":       "AsciiDoc",
+	".tex":        "TeX",
+	".txt":        "Text",
+	".csv":        "CSV",
+	".sql":        "SQL",
+	".graphql":    "GraphQL",
+	".gql":        "GraphQL",
+	".proto":      "Protocol Buffer",
+	".tf":         "HCL",
+	".hcl":        "HCL",
+	".nix":        "Nix",
+	".cmake":      "CMake",
+	".mk":         "Makefile",
+	".mak":        "Makefile",
+	".dockerfile": "Dockerfile",
+	".tmpl":       "Go Template",
+	".gotmpl":     "Go Template",
+	".templ":      "templ",
+	".sol":        "Solidity",
+	".wasm":       "WebAssembly",
+	".wat":        "WebAssembly",
+}
+
+// languageByInterpreter maps shebang interpreters to their language
+var languageByInterpreter = map[string]string{
+	"sh":      "Shell",
+	"bash":    "Shell",
+	"zsh":     "Shell",
+	"ksh":     "Shell",
+	"dash":    "Shell",
+	"ash":     "Shell",
+	"fish":    "Fish",
+	"python":  "Python",
+	"node":    "JavaScript",
+	"nodejs":  "JavaScript",
+	"deno":    "TypeScript",
+	"bun":     "JavaScript",
+	"ts-node": "TypeScript",
+	"ruby":    "Ruby",
+	"perl":    "Perl",
+	"php":     "PHP",
+	"lua":     "Lua",
+	"luajit":  "Lua",
+	"rscript": "R",
+	"julia":   "Julia",
+	"elixir":  "Elixir",
+	"escript": "Erlang",
+	"awk":     "Awk",
+	"gawk":    "Awk",
+	"make":    "Makefile",
+	"pwsh":    "PowerShell",
+	"tclsh":   "Tcl",
+	"groovy":  "Groovy",
+	"scala":   "Scala",
+	"swift":   "Swift",
+}
+
+// canonicalLanguages resolves case-insensitive names from linguist-language
+// attributes and config to the names used here
+var canonicalLanguages = func() map[string]string {
+	names := make(map[string]string)
+	for _, table := range []map[string]string{languageByExtension, languageByFilename, languageByInterpreter} {
+		for _, language := range table {
+			names[strings.ToLower(language)] = language
+		}
+	}
+	// Common aliases
+	names["cpp"] = "C++"
+	names["csharp"] = "C#"
+	names["golang"] = "Go"
+	names["js"] = "JavaScript"
+	names["ts"] = "TypeScript"
+	names["py"] = "Python"
+	names["bash"] = "Shell"
+	names["sh"] = "Shell"
+	names["objc"] = "Objective-C"
+	names["md"] = "Markdown"
+	names["yml"] = "YAML"
+	return names
+}()
+
+// CanonicalLanguage returns the canonical spelling of a language name
+func CanonicalLanguage(name string) string {
+	if canonical, ok := canonicalLanguages[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return canonical
+	}
+	return strings.TrimSpace(name)
+}
+
+// DetectLanguage returns the language of a file from its name, extension or
+// shebang line, or "" when it is not recognized. Content may be nil, in
+// which case ambiguous extensions fall back to their most common language.
+func DetectLanguage(file string, content []byte) string {
+	base := strings.ToLower(path.Base(file))
+	if language, ok := languageByFilename[base]; ok {
+		return language
+	}
+
+	ext := path.Ext(base)
+	if ext == ".h" {
+		return headerLanguage(content)
+	}
+	if language, ok := languageByExtension[ext]; ok {
+		return language
+	}
+	// Dockerfile.dev, Makefile.am and friends
+	if stem := strings.TrimSuffix(base, ext); ext != "" {
+		if language, ok := languageByFilename[stem]; ok && (language == "Dockerfile" || language == "Makefile") {
+			return language
+		}
+	}
+
+	return shebangLanguage(content)
+}
+
+// shebangLanguage detects a script's language from its #! line
+func shebangLanguage(content []byte) string {
+	if !bytes.HasPrefix(content, []byte("#!")) {
+		return ""
+	}
+	line := content[2:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	// #!/usr/bin/env [-S] [VAR=value...] interpreter
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, "-") || strings.Contains(field, "=") {
+				continue
+			}
+			interpreter = path.Base(field)
+			break
+		}
+	}
+
+	// python3.12 -> python, perl5 -> perl
+	interpreter = strings.ToLower(strings.TrimRight(interpreter, "0123456789."))
+	return languageByInterpreter[interpreter]
+}
+
+var (
+	cppHeaderPattern  = regexp.MustCompile(`(?m)^\s*(class\s+\w+\s*[:{]|namespace\s+\w*\s*\{|template\s*<|#include\s*<(iostream|string|vector|map|memory|cstdint|cstdio|algorithm)>)|std::`)
+	objcHeaderPattern = regexp.MustCompile(`(?m)^\s*(@interface|@protocol|@property|@end|#import\s)`)
+)
+
+// headerLanguage tells C, C++ and Objective-C headers apart by their content
+func headerLanguage(content []byte) string {
+	switch {
+	case objcHeaderPattern.Match(content):
+		return "Objective-C"
+	case cppHeaderPattern.Match(content):
+		return "C++"
+	default:
+		return "C"
+	}
+}
+
+// vendoredPattern matches directories of third-party code
+var vendoredPattern = regexp.MustCompile(`(^|/)(vendor|node_modules|bower_components|jspm_packages|third[_-]?party|Godeps/_workspace|\.yarn|Pods|Carthage/Checkouts)/`)
+
+// IsVendored reports whether a path holds third-party code
+func IsVendored(file string) bool {
+	return vendoredPattern.MatchString(file)
+}
+
+// generatedNames are file name globs of generated files and lockfiles
+var generatedNames = []string{
+	"*.pb.go", "*.pb.gw.go", "*.pb.cc", "*.pb.h", "*_pb2.py", "*_pb2_grpc.py", "*_pb2.pyi",
+	"*.generated.*", "*_generated.go", "*.g.dart", "*.freezed.dart", "*.designer.cs",
+	"*.min.js", "*.min.css", "*.js.map", "*.css.map",
+	"package-lock.json", "npm-shrinkwrap.json", "yarn.lock", "pnpm-lock.yaml", "bun.lockb",
+	"go.sum", "cargo.lock", "poetry.lock", "pipfile.lock", "composer.lock", "gemfile.lock", "flake.lock",
+}
+
+// generatedMarkers are found near the top of generated source files
+var generatedMarkers = regexp.MustCompile(`(?i)(code generated .*do not edit|@generated|<auto-generated|auto-generated file|autogenerated file|generated by protoc|this file was automatically generated)`)
+
+// IsGenerated reports whether a file was produced by a tool rather than
+// written by hand, judging by its name and, when given, its first lines
+func IsGenerated(file string, content []byte) bool {
+	base := strings.ToLower(path.Base(file))
+	for _, pattern := range generatedNames {
+		if matchSegment(pattern, base) {
+			return true
+		}
+	}
+	return generatedMarkers.Match(headLines(content, 10))
+}
+
+// IsMinified reports whether JavaScript or CSS content has been minified,
+// detected by an average line length above 110 characters
+func IsMinified(file string, content []byte) bool {
+	if !isMinifiable(file) {
+		return false
+	}
+	lines := bytes.Count(content, []byte("\n")) + 1
+	return len(content) > 0 && len(content)/lines > 110
+}
+
+// isMinifiable reports whether a file is JavaScript or CSS, which may be
+// minified
+func isMinifiable(file string) bool {
+	switch strings.ToLower(path.Ext(file)) {
+	case ".js", ".mjs", ".cjs", ".css":
+		return true
+	}
+	return false
+}
+
+// headLines returns the first n lines of content
+func headLines(content []byte, n int) []byte {
+	end := 0
+	for i := 0; i < n && end < len(content); i++ {
+		next := bytes.IndexByte(content[end:], '\n')
+		if next < 0 {
+			return content
+		}
+		end += next + 1
+	}
+	return content[:end]
+}
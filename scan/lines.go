@@ -0,0 +1,423 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AccursedGalaxy/streakode/config"
+	"github.com/AccursedGalaxy/streakode/scan/gitcmd"
+	"github.com/AccursedGalaxy/streakode/scan/gitobj"
+)
+
+// BlobStats are the facts about a blob that language statistics need. They
+// depend on the blob's content alone, so they are memoized by blob hash.
+type BlobStats struct {
+	Binary  bool   `json:"binary,omitempty"`
+	Size    int    `json:"size"`
+	Lines   int    `json:"lines"`
+	Blank   int    `json:"blank"`
+	Comment int    `json:"comment"`           // lines holding only comments, in Syntax
+	Syntax  string `json:"syntax,omitempty"`  // comment syntax Comment was counted with
+	Marked  bool   `json:"marked,omitempty"`  // carries a generated code marker
+	Shebang string `json:"shebang,omitempty"` // language named by a #! line
+	Header  string `json:"header,omitempty"`  // language of the content as a C family header
+}
+
+// blobStatsOf summarizes content, counting comments in the named syntax
+func blobStatsOf(content []byte, syntax string) BlobStats {
+	if gitobj.IsBinary(content) {
+		return BlobStats{Binary: true, Size: len(content)}
+	}
+	stats := BlobStats{
+		Size:    len(content),
+		Lines:   countLines(content),
+		Syntax:  syntax,
+		Marked:  generatedMarkers.Match(headLines(content, 10)),
+		Shebang: shebangLanguage(content),
+		Header:  headerLanguage(content),
+	}
+	stats.Blank, stats.Comment = countSourceLines(content, syntax)
+	return stats
+}
+
+// BlobRecord is a memoized BlobStats with the time a scan last needed it
+type BlobRecord struct {
+	Stats BlobStats `json:"stats"`
+	Used  time.Time `json:"used"`
+}
+
+// BlobMemo remembers the statistics of blobs across scans, so a file is only
+// counted again when its content changed. It is safe for concurrent use.
+type BlobMemo struct {
+	mu      sync.Mutex
+	records map[string]BlobRecord
+	now     time.Time
+}
+
+// NewBlobMemo starts from the records kept by an earlier scan
+func NewBlobMemo(records map[string]BlobRecord) *BlobMemo {
+	memo := &BlobMemo{records: make(map[string]BlobRecord, len(records)), now: time.Now().UTC()}
+	for hash, record := range records {
+		memo.records[hash] = record
+	}
+	return memo
+}
+
+// Records returns the memoized statistics, leaving out blobs no scan needed
+// for maxAge
+func (m *BlobMemo) Records(maxAge time.Duration) map[string]BlobRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	records := make(map[string]BlobRecord, len(m.records))
+	for hash, record := range m.records {
+		if m.now.Sub(record.Used) <= maxAge {
+			records[hash] = record
+		}
+	}
+	return records
+}
+
+func (m *BlobMemo) get(hash string) (BlobStats, bool) {
+	if m == nil {
+		return BlobStats{}, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.records[hash]
+	if ok {
+		record.Used = m.now
+		m.records[hash] = record
+	}
+	return record.Stats, ok
+}
+
+func (m *BlobMemo) put(hash string, stats BlobStats) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[hash] = BlobRecord{Stats: stats, Used: m.now}
+}
+
+type blobMemoKey struct{}
+
+// WithBlobMemo makes scans under ctx look up and record blob statistics in
+// memo
+func WithBlobMemo(ctx context.Context, memo *BlobMemo) context.Context {
+	return context.WithValue(ctx, blobMemoKey{}, memo)
+}
+
+// blobMemoFrom returns the memo attached to ctx, nil when there is none
+func blobMemoFrom(ctx context.Context) *BlobMemo {
+	memo, _ := ctx.Value(blobMemoKey{}).(*BlobMemo)
+	return memo
+}
+
+// treeFile is a file of the HEAD tree
+type treeFile struct {
+	Path    string
+	Blob    string
+	Symlink bool
+}
+
+// headTree lists the files of the HEAD tree and opens a reader for their
+// blobs. It reads the object store directly and only falls back to the git
+// binary when the repository cannot be read natively. An unborn HEAD yields
+// no files.
+func headTree(ctx context.Context, repoPath string) (string, []treeFile, blobReader, error) {
+	tree, files, reader, err := headTreeNative(repoPath)
+	if err == nil {
+		return tree, files, reader, nil
+	}
+	if config.AppConfig.Debug {
+		fmt.Printf("Debug: Native tree listing failed for %s, falling back to git: %v\n", repoPath, err)
+	}
+	return headTreeGit(ctx, repoPath)
+}
+
+func headTreeNative(repoPath string) (string, []treeFile, blobReader, error) {
+	repo, err := gitobj.Open(repoPath)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		repo.Close()
+		return "", nil, nil, err
+	}
+	commit, err := repo.Commit(head)
+	if err != nil {
+		repo.Close()
+		return "", nil, nil, err
+	}
+	listed, err := repo.ListFiles(commit.Tree)
+	if err != nil {
+		repo.Close()
+		return "", nil, nil, err
+	}
+	files := make([]treeFile, 0, len(listed))
+	for _, f := range listed {
+		files = append(files, treeFile{Path: f.Path, Blob: f.Hash.String(), Symlink: f.Mode&0o170000 == 0o120000})
+	}
+	return commit.Tree.String(), files, nativeBlobs{repo}, nil
+}
+
+func headTreeGit(ctx context.Context, repoPath string) (string, []treeFile, blobReader, error) {
+	output, err := gitcmd.Output(ctx, repoPath, "rev-parse", "-q", "--verify", "HEAD^{tree}")
+	if err != nil {
+		if ctx.Err() == nil && len(bytes.TrimSpace(output)) == 0 {
+			if _, headErr := gitcmd.Output(ctx, repoPath, "rev-parse", "--git-dir"); headErr == nil {
+				return "", nil, nil, nil
+			}
+		}
+		return "", nil, nil, err
+	}
+	tree := strings.TrimSpace(string(output))
+
+	output, err = gitcmd.Output(ctx, repoPath, "ls-tree", "-r", "-z", "--full-tree", tree)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	var files []treeFile
+	for _, record := range strings.Split(string(output), "\x00") {
+		// "<mode> <type> <object>\t<path>"
+		info, file, ok := strings.Cut(record, "\t")
+		fields := strings.Fields(info)
+		if !ok || len(fields) != 3 || fields[1] != "blob" {
+			continue
+		}
+		mode, _ := strconv.ParseUint(fields[0], 8, 32)
+		files = append(files, treeFile{Path: file, Blob: fields[2], Symlink: mode&0o170000 == 0o120000})
+	}
+
+	batch, err := gitcmd.NewBatch(ctx, repoPath)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return tree, files, batchBlobs{batch}, nil
+}
+
+// blobReader reads blobs by hash
+type blobReader interface {
+	readBlob(hash string) ([]byte, error)
+	Close() error
+}
+
+type nativeBlobs struct{ repo *gitobj.Repository }
+
+func (n nativeBlobs) readBlob(hash string) ([]byte, error) {
+	h, err := gitobj.ParseHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return n.repo.Blob(h)
+}
+
+func (n nativeBlobs) Close() error { return n.repo.Close() }
+
+type batchBlobs struct{ batch *gitcmd.Batch }
+
+func (b batchBlobs) readBlob(hash string) ([]byte, error) {
+	kind, data, err := b.batch.Read(hash)
+	if err != nil {
+		return nil, err
+	}
+	if kind != "blob" {
+		return nil, fmt.Errorf("object %s is a %s, not a blob", hash, kind)
+	}
+	return data, nil
+}
+
+func (b batchBlobs) Close() error { return b.batch.Close() }
+
+// treeStats are the language and line statistics of a HEAD tree
+type treeStats struct {
+	tree      string
+	languages map[string]int // lines per language
+	code      int
+	comment   int
+	blank     int
+}
+
+// setTreeStats records the statistics of the checked in tree
+func (m *RepoMetadata) setTreeStats(stats treeStats) {
+	m.Languages = stats.languages
+	m.TotalLines = calculateTotalLines(stats.languages)
+	m.CodeLines = stats.code
+	m.CommentLines = stats.comment
+	m.BlankLines = stats.blank
+	m.TreeHash = stats.tree
+}
+
+// treeCounter counts the lines of the files of one tree, reusing memoized
+// blob statistics
+type treeCounter struct {
+	reader     blobReader
+	memo       *BlobMemo
+	attributes *gitAttributes
+	read       int // blobs read because they were not memoized
+}
+
+// blobStats returns the statistics of a blob with comments counted in the
+// named syntax
+func (c *treeCounter) blobStats(hash, syntax string) (BlobStats, error) {
+	if stats, ok := c.memo.get(hash); ok && (stats.Binary || stats.Syntax == syntax) {
+		return stats, nil
+	}
+	content, err := c.reader.readBlob(hash)
+	if err != nil {
+		return BlobStats{}, err
+	}
+	c.read++
+	stats := blobStatsOf(content, syntax)
+	c.memo.put(hash, stats)
+	return stats, nil
+}
+
+// classify determines the language and line statistics of a tree file. A
+// non-empty reason explains why the file does not count toward language
+// statistics: vendored, generated, minified, binary or unrecognized files,
+// excluded languages, and the linguist-* attributes that override detection.
+func (c *treeCounter) classify(file treeFile) (language string, stats BlobStats, reason string) {
+	attrs := c.attributes.lookup(file.Path)
+	if file.Symlink {
+		return "", stats, "symlink"
+	}
+	if reason := pathExclusion(file.Path, attrs); reason != "" {
+		return "", stats, reason
+	}
+	generated, generatedSet := attrBool(attrs, "linguist-generated")
+	if generated || (!generatedSet && IsGenerated(file.Path, nil)) {
+		return "", stats, "generated"
+	}
+
+	// The path alone names the language of most files, so comments can be
+	// counted on the first read
+	override := attrs["linguist-language"]
+	if override == "true" {
+		override = ""
+	}
+	language = DetectLanguage(file.Path, nil)
+	if override != "" {
+		language = CanonicalLanguage(override)
+	}
+	stats, err := c.blobStats(file.Blob, languageComments[language])
+	if err != nil {
+		return "", stats, fmt.Sprintf("unreadable: %v", err)
+	}
+	if stats.Binary {
+		return "", stats, "binary"
+	}
+	if !generatedSet {
+		if stats.Marked {
+			return "", stats, "generated"
+		}
+		if isMinifiable(file.Path) && stats.Size > 0 && stats.Size/stats.Lines > 110 {
+			return "", stats, "minified"
+		}
+	}
+
+	if override == "" {
+		switch {
+		case strings.ToLower(path.Ext(file.Path)) == ".h":
+			language = stats.Header
+		case language == "":
+			language = stats.Shebang
+		}
+	}
+	if language == "" {
+		return "", stats, "unknown language"
+	}
+	if isExcludedLanguage(language) {
+		return "", stats, "excluded language " + language
+	}
+	if syntax := languageComments[language]; stats.Syntax != syntax {
+		if stats, err = c.blobStats(file.Blob, syntax); err != nil {
+			return "", stats, fmt.Sprintf("unreadable: %v", err)
+		}
+	}
+	return language, stats, ""
+}
+
+// fetchLanguageStats counts the lines of the files checked in at HEAD per
+// language. Uncommitted changes are not counted.
+func fetchLanguageStats(ctx context.Context, repoPath string) (treeStats, error) {
+	if config.AppConfig.Debug {
+		fmt.Printf("Debug: Fetching language stats for %s\n", repoPath)
+	}
+
+	stats := treeStats{languages: make(map[string]int)}
+	tree, files, reader, err := headTree(ctx, repoPath)
+	if err != nil {
+		if config.AppConfig.Debug {
+			fmt.Printf("Debug: Listing tracked files failed: %v\n", err)
+		}
+		return stats, err
+	}
+	if reader == nil {
+		return stats, nil
+	}
+	defer reader.Close()
+	stats.tree = tree
+
+	if config.AppConfig.Debug {
+		fmt.Printf("Debug: Found %d tracked files\n", len(files))
+	}
+
+	counter := &treeCounter{reader: reader, memo: blobMemoFrom(ctx)}
+	paths := make([]string, len(files))
+	blobs := make(map[string]string, len(files))
+	for i, file := range files {
+		paths[i] = file.Path
+		blobs[file.Path] = file.Blob
+	}
+	counter.attributes = readGitAttributes(paths, func(file string) ([]byte, error) {
+		return reader.readBlob(blobs[file])
+	})
+
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		if ext := path.Ext(file.Path); ext != "" && isExcludedExtension(ext) {
+			if config.AppConfig.Debug {
+				fmt.Printf("Debug: Skipping excluded extension: %s\n", ext)
+			}
+			continue
+		}
+
+		language, blob, reason := counter.classify(file)
+		if reason != "" {
+			if config.AppConfig.Debug {
+				fmt.Printf("Debug: Skipping %s (%s)\n", file.Path, reason)
+			}
+			continue
+		}
+		if blob.Lines >= config.AppConfig.LanguageSettings.MinimumLines {
+			stats.languages[language] += blob.Lines
+			stats.blank += blob.Blank
+			stats.comment += blob.Comment
+			stats.code += blob.Lines - blob.Blank - blob.Comment
+			if config.AppConfig.Debug {
+				fmt.Printf("Debug: Added %d lines for %s (%s)\n", blob.Lines, file.Path, language)
+			}
+		}
+	}
+
+	if config.AppConfig.Debug {
+		fmt.Printf("Debug: Read %d of %d blobs, the rest were memoized\n", counter.read, len(files))
+		fmt.Println("Debug: Language statistics:")
+		for lang, lines := range stats.languages {
+			fmt.Printf("Debug: %s: %d lines\n", lang, lines)
+		}
+	}
+
+	return stats, nil
+}
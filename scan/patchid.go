@@ -0,0 +1,106 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/AccursedGalaxy/streakode/config"
+	"github.com/AccursedGalaxy/streakode/scan/gitcmd"
+)
+
+// dedupPatchIDs reports whether commits with identical changes, such as
+// rebased or cherry-picked copies, are counted once
+func dedupPatchIDs() bool {
+	return config.AppConfig.ScanSettings.DedupPatchIDs
+}
+
+// readPatchIDs returns the stable patch-id of each commit by hash. Merges
+// and commits without changes have no patch-id and are left out.
+func readPatchIDs(ctx context.Context, repoPath string, hashes []string) (map[string]string, error) {
+	ids := make(map[string]string, len(hashes))
+	if len(hashes) == 0 {
+		return ids, nil
+	}
+
+	diff := gitcmd.Command(ctx, repoPath, "diff-tree", "--stdin", "--root", "-p")
+	diff.Stdin = strings.NewReader(strings.Join(hashes, "\n") + "\n")
+	patchID := gitcmd.Command(ctx, repoPath, "patch-id", "--stable")
+	pipe, err := diff.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error piping diff-tree: %v", err)
+	}
+	patchID.Stdin = pipe
+	out, err := patchID.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error piping patch-id: %v", err)
+	}
+	if config.AppConfig.Debug {
+		fmt.Printf("Debug: Computing patch-ids of %d commits in %s\n", len(hashes), repoPath)
+	}
+
+	if err := patchID.Start(); err != nil {
+		return nil, fmt.Errorf("error starting git patch-id: %v", err)
+	}
+	if err := diff.Start(); err != nil {
+		patchID.Wait()
+		return nil, fmt.Errorf("error starting git diff-tree: %v", err)
+	}
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		// "<patch-id> <commit>"
+		if id, commit, ok := strings.Cut(scanner.Text(), " "); ok {
+			ids[commit] = id
+		}
+	}
+	diffErr := diff.Wait()
+	if err := patchID.Wait(); err != nil {
+		return nil, fmt.Errorf("git patch-id failed: %v", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("computing patch-ids stopped: %v", err)
+	}
+	if diffErr != nil {
+		return nil, fmt.Errorf("git diff-tree failed: %v", diffErr)
+	}
+	return ids, nil
+}
+
+// setPatchIDs records the patch-id of each entry
+func setPatchIDs(ctx context.Context, repoPath string, entries []logEntry) error {
+	hashes := make([]string, len(entries))
+	for i, entry := range entries {
+		hashes[i] = entry.Hash
+	}
+	ids, err := readPatchIDs(ctx, repoPath, hashes)
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		entries[i].PatchID = ids[entries[i].Hash]
+	}
+	return nil
+}
+
+// collapseHistory keeps the earliest authored commit of each patch-id and
+// returns the rest of the history in its original order
+func collapseHistory(history []CommitHistory) []CommitHistory {
+	earliest := make(map[string]int)
+	for i, commit := range history {
+		if commit.PatchID == "" {
+			continue
+		}
+		if j, ok := earliest[commit.PatchID]; !ok || commit.Date.Before(history[j].Date) {
+			earliest[commit.PatchID] = i
+		}
+	}
+
+	collapsed := make([]CommitHistory, 0, len(history))
+	for i, commit := range history {
+		if commit.PatchID == "" || earliest[commit.PatchID] == i {
+			collapsed = append(collapsed, commit)
+		}
+	}
+	return collapsed
+}
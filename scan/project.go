@@ -0,0 +1,118 @@
+package scan
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AccursedGalaxy/streakode/config"
+)
+
+// Project is one logical project: every clone, fork and worktree whose
+// history starts at the same root commit
+type Project struct {
+	Path   string       // canonical clone, used for display
+	Clones []string     // every clone, canonical first
+	Meta   RepoMetadata // merged metadata counting each commit once
+}
+
+// GroupProjects groups repositories by root commit. Repositories without a
+// known root stand alone. Projects are ordered by canonical path.
+func GroupProjects(repos map[string]RepoMetadata) []Project {
+	groups := make(map[string][]RepoMetadata)
+	for path, repo := range repos {
+		key := repo.RootCommit
+		if key == "" {
+			key = "path:" + path
+		}
+		groups[key] = append(groups[key], repo)
+	}
+
+	projects := make([]Project, 0, len(groups))
+	for _, clones := range groups {
+		sortClones(clones)
+		project := Project{Path: clones[0].Path, Meta: mergeClones(clones)}
+		for _, clone := range clones {
+			project.Clones = append(project.Clones, clone.Path)
+		}
+		projects = append(projects, project)
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].Path < projects[j].Path
+	})
+	return projects
+}
+
+// sortClones puts the canonical clone first: the first clone under a
+// configured canonical path, otherwise the most recently active one, with
+// shorter paths breaking ties
+func sortClones(clones []RepoMetadata) {
+	rank := func(path string) int {
+		for i, canonical := range config.AppConfig.ProjectSettings.CanonicalPaths {
+			if underCanonicalPath(path, canonical) {
+				return i
+			}
+		}
+		return len(config.AppConfig.ProjectSettings.CanonicalPaths)
+	}
+	sort.SliceStable(clones, func(i, j int) bool {
+		a, b := clones[i], clones[j]
+		if ra, rb := rank(a.Path), rank(b.Path); ra != rb {
+			return ra < rb
+		}
+		if !a.LastCommit.Equal(b.LastCommit) {
+			return a.LastCommit.After(b.LastCommit)
+		}
+		if len(a.Path) != len(b.Path) {
+			return len(a.Path) < len(b.Path)
+		}
+		return a.Path < b.Path
+	})
+}
+
+// underCanonicalPath reports whether path is, lies below or matches the glob
+// canonical, which may start with "~/"
+func underCanonicalPath(path, canonical string) bool {
+	canonical = filepath.Clean(expandHome(canonical))
+	if rel, ok := relativeTo(canonical, path); ok && rel != "" {
+		return true
+	}
+	return matchGlob(filepath.ToSlash(strings.TrimPrefix(canonical, "/")), filepath.ToSlash(strings.TrimPrefix(path, "/")))
+}
+
+// mergeClones merges the metadata of clones, canonical first. Commits are
+// combined by hash so that each counts once; the working tree statistics
+// are the canonical clone's.
+func mergeClones(clones []RepoMetadata) RepoMetadata {
+	merged := clones[0]
+	if len(clones) == 1 {
+		return merged
+	}
+
+	// Clones usually collapsed the same copies, so only the most any one
+	// clone collapsed is carried over
+	sets := make([]commitColumns, 0, len(clones))
+	history := merged.CommitHistory
+	collapsed := 0
+	for _, clone := range clones {
+		columns := clone.columns()
+		if columns.collapsed > collapsed {
+			collapsed = columns.collapsed
+		}
+		columns.collapsed = 0
+		sets = append(sets, columns)
+		if clone.Path != merged.Path {
+			history = mergeHistory(history, clone.CommitHistory, time.Time{})
+		}
+	}
+	columns := mergeCommits(sets...)
+	columns.collapsed += collapsed
+	merged.setColumns(columns)
+	merged.CommitHistory = history
+	if history != nil {
+		merged.DailyStats = buildDailyStats(history)
+	}
+	merged.updateCommitMetrics()
+	return merged
+}
@@ -0,0 +1,380 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AccursedGalaxy/streakode/config"
+)
+
+// UpdateRepoMeta brings metadata from an earlier scan up to date by reading
+// only the commits that are not reachable from the ref tips recorded then.
+// A full rescan happens when history was rewritten (force-push, rebase,
+// pruned objects) or when the earlier scan did not record ref tips.
+func UpdateRepoMeta(ctx context.Context, prev RepoMetadata, author string) RepoMetadata {
+	repoPath := prev.Path
+	if len(prev.RefTips) == 0 {
+		return fetchRepoMeta(ctx, repoPath, author)
+	}
+
+	if _, err := os.Stat(repoPath); err != nil {
+		meta := RepoMetadata{Path: repoPath, LastAnalyzed: time.Now().UTC()}
+		meta.scanFailed("opening repository", err)
+		return meta
+	}
+
+	// Streak rules judging days by changed lines need them for every commit
+	needsLines := ConfiguredStreakPolicy().NeedsLines()
+	if needsLines && len(prev.CommitLines) != len(prev.CommitDates) {
+		return fetchRepoMeta(ctx, repoPath, author)
+	}
+	// Commits are counted once across clones by hash; caches from before
+	// hashes were kept are read again
+	if len(prev.CommitHashes) != len(prev.CommitDates) {
+		return fetchRepoMeta(ctx, repoPath, author)
+	}
+	// Counting co-authored commits or not changes which commits are dated
+	if prev.CoAuthored != countCoAuthored() {
+		return fetchRepoMeta(ctx, repoPath, author)
+	}
+	// Collapsing by patch-id needs the patch-ids of every counted commit
+	if prev.PatchDeduped != dedupPatchIDs() {
+		return fetchRepoMeta(ctx, repoPath, author)
+	}
+	// Line counts depend on the change rules they were filtered with
+	if prev.ChangeRules != configuredChangeFilter().key() {
+		return fetchRepoMeta(ctx, repoPath, author)
+	}
+	// Commits of identities added since were never read, those of identities
+	// removed must be dropped
+	if prev.IdentityKey != identityKey(author) {
+		return fetchRepoMeta(ctx, repoPath, author)
+	}
+
+	tips, err := readRefTips(ctx, repoPath)
+	if err != nil {
+		if config.AppConfig.Debug {
+			fmt.Printf("Debug: Reading refs failed, rescanning %s: %v\n", repoPath, err)
+		}
+		return fetchRepoMeta(ctx, repoPath, author)
+	}
+	if reason := historyRewritten(ctx, repoPath, prev.RefTips, tips); reason != "" {
+		if config.AppConfig.Debug {
+			fmt.Printf("Debug: History of %s was rewritten (%s), rescanning\n", repoPath, reason)
+		}
+		return fetchRepoMeta(ctx, repoPath, author)
+	}
+
+	meta := prev
+	meta.LastAnalyzed = time.Now().UTC()
+	meta.ScanErrors = nil
+	meta.RefTips = tips
+
+	newTips := tipHashes(tips)
+	oldTips := tipHashes(prev.RefTips)
+	changed := strings.Join(newTips, ",") != strings.Join(oldTips, ",")
+
+	if changed {
+		entries, err := readCommits(ctx, repoPath, logOptions{
+			Authors: authorIdentities(author), Tips: newTips, Exclude: oldTips, NumStat: needsLines,
+			CoAuthors: countCoAuthored(), PatchIDs: dedupPatchIDs(),
+		})
+		if err != nil {
+			if config.AppConfig.Debug {
+				fmt.Printf("Debug: Incremental read failed, rescanning %s: %v\n", repoPath, err)
+			}
+			return fetchRepoMeta(ctx, repoPath, author)
+		}
+		if config.AppConfig.Debug {
+			fmt.Printf("Debug: %d new commits in %s\n", len(entries), repoPath)
+		}
+		meta.setColumns(mergeCommits(columnsOf(entries, needsLines), prev.columns()))
+	}
+
+	// Scans from before projects were grouped did not record the root
+	if meta.RootCommit == "" {
+		if root, err := readRootCommit(ctx, repoPath); err == nil {
+			meta.RootCommit = root
+		}
+	}
+	meta.RemoteURL = readRemoteURL(repoPath)
+
+	// Date based metrics move with the clock even when nothing changed
+	meta.updateCommitMetrics()
+
+	if !meta.AuthorVerified || !config.AppConfig.DetailedStats {
+		return meta
+	}
+
+	// Detailed stats missing from the earlier scan are collected in full
+	if prev.Languages == nil {
+		meta.initDetailedStats()
+		meta.updateDetailedStats(ctx, repoPath, author)
+		return meta
+	}
+
+	since := detailedHistorySince()
+	var history []CommitHistory
+	if changed {
+		entries, err := readCommits(ctx, repoPath, logOptions{
+			Authors: authorIdentities(author), Since: since, NumStat: true, Tips: newTips, Exclude: oldTips,
+			CoAuthors: true, PatchIDs: dedupPatchIDs(),
+		})
+		if err != nil {
+			meta.scanFailed("collecting detailed stats", err)
+		}
+		for _, entry := range entries {
+			history = append(history, entry.toHistory())
+		}
+	}
+
+	// Languages follow the HEAD tree; caches from before language detection
+	// keyed them by file extension, and older ones read the working tree
+	if changed || extensionKeyed(prev.Languages) || prev.TreeHash == "" {
+		if stats, err := fetchLanguageStats(ctx, repoPath); err == nil {
+			meta.setTreeStats(stats)
+		} else {
+			meta.scanFailed("collecting language stats", err)
+		}
+	}
+
+	// A longer window than before only needs the older part read, up to
+	// where the earlier history started
+	if !prev.historyCovers(since) {
+		until := prev.HistorySince
+		if config.AppConfig.Debug {
+			fmt.Printf("Debug: Extending detailed history of %s back to %s\n", repoPath, since.Format("2006-01-02"))
+		}
+		entries, err := readCommits(ctx, repoPath, logOptions{
+			Authors: authorIdentities(author), Since: since, Until: until, NumStat: true, Tips: newTips,
+			CoAuthors: true, PatchIDs: dedupPatchIDs(),
+		})
+		if err != nil {
+			meta.scanFailed("collecting detailed stats", err)
+		} else {
+			for _, entry := range entries {
+				history = append(history, entry.toHistory())
+			}
+		}
+	}
+
+	meta.CommitHistory = mergeHistory(history, prev.CommitHistory, since)
+	meta.DailyStats = buildDailyStats(meta.CommitHistory)
+	meta.setHistoryWindow(since)
+
+	return meta
+}
+
+// historyRewritten returns why previously recorded tips can no longer be
+// extended incrementally, or "" if they can. Branches, remote branches and
+// tags must only move forward. Other refs such as HEAD or refs/stash are
+// expected to jump around and are treated like deleted refs, whose commits
+// stay counted.
+func historyRewritten(ctx context.Context, repoPath string, oldTips, newTips map[string]string) string {
+	for ref, oldTip := range oldTips {
+		if !strings.HasPrefix(ref, "refs/heads/") &&
+			!strings.HasPrefix(ref, "refs/remotes/") &&
+			!strings.HasPrefix(ref, "refs/tags/") {
+			continue
+		}
+		newTip, ok := newTips[ref]
+		if !ok || newTip == oldTip {
+			continue
+		}
+		ok, err := isAncestor(ctx, repoPath, oldTip, newTip)
+		if err != nil {
+			return fmt.Sprintf("%s: %v", ref, err)
+		}
+		if !ok {
+			return fmt.Sprintf("%s moved from %s to %s", ref, oldTip, newTip)
+		}
+	}
+	return ""
+}
+
+// extensionKeyed reports whether language stats are keyed by file extension
+func extensionKeyed(languages map[string]int) bool {
+	for language := range languages {
+		if strings.HasPrefix(language, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// tipHashes returns the distinct commits of a ref map in a stable order
+func tipHashes(tips map[string]string) []string {
+	seen := make(map[string]bool, len(tips))
+	hashes := make([]string, 0, len(tips))
+	for _, h := range tips {
+		if !seen[h] {
+			seen[h] = true
+			hashes = append(hashes, h)
+		}
+	}
+	sort.Strings(hashes)
+	return hashes
+}
+
+// commitColumns are the per-commit values kept for incremental refreshes,
+// newest first. Lines, hashes and patch-ids are nil when they were not
+// recorded.
+type commitColumns struct {
+	dates     []time.Time
+	lines     []int
+	hashes    []string
+	patchIDs  []string
+	collapsed int // copies already dropped by patch-id
+}
+
+// columnsOf extracts the columns of log entries, preserving log order
+func columnsOf(entries []logEntry, withLines bool) commitColumns {
+	c := commitColumns{dates: commitDates(entries), hashes: make([]string, len(entries))}
+	for i, entry := range entries {
+		c.hashes[i] = entry.Hash
+	}
+	if withLines {
+		c.lines = commitLines(entries)
+	}
+	if dedupPatchIDs() {
+		c.patchIDs = make([]string, len(entries))
+		for i, entry := range entries {
+			c.patchIDs[i] = entry.PatchID
+		}
+	}
+	return c
+}
+
+// columns returns the commit columns recorded in m
+func (m *RepoMetadata) columns() commitColumns {
+	return commitColumns{
+		dates: m.CommitDates, lines: m.CommitLines, hashes: m.CommitHashes,
+		patchIDs: m.CommitPatchIDs, collapsed: m.CollapsedCommits,
+	}
+}
+
+// setColumns records commit columns in m
+func (m *RepoMetadata) setColumns(c commitColumns) {
+	m.CommitDates, m.CommitLines, m.CommitHashes = c.dates, c.lines, c.hashes
+	m.CommitPatchIDs, m.CollapsedCommits = c.patchIDs, c.collapsed
+}
+
+// mergeCommits combines sets of commit columns, newest first. Lines, hashes
+// and patch-ids are kept only when every set recorded them; with hashes, a
+// commit found in several sets is kept once, and with patch-ids only the
+// earliest authored copy of the same change is kept.
+func mergeCommits(sets ...commitColumns) commitColumns {
+	type record struct {
+		date    time.Time
+		lines   int
+		hash    string
+		patchID string
+	}
+
+	keepLines, anyLines, keepHashes, keepPatchIDs := true, false, true, true
+	total, collapsed := 0, 0
+	for _, c := range sets {
+		keepLines = keepLines && len(c.lines) == len(c.dates)
+		anyLines = anyLines || c.lines != nil
+		keepHashes = keepHashes && len(c.hashes) == len(c.dates)
+		keepPatchIDs = keepPatchIDs && c.patchIDs != nil && len(c.patchIDs) == len(c.dates)
+		total += len(c.dates)
+		collapsed += c.collapsed
+	}
+	keepLines = keepLines && anyLines
+	keepPatchIDs = keepPatchIDs && len(sets) > 0
+
+	seen := make(map[string]bool, total)
+	records := make([]record, 0, total)
+	for _, c := range sets {
+		for i, date := range c.dates {
+			r := record{date: date}
+			if keepLines {
+				r.lines = c.lines[i]
+			}
+			if keepHashes {
+				r.hash = c.hashes[i]
+				if seen[r.hash] {
+					continue
+				}
+				seen[r.hash] = true
+			}
+			if keepPatchIDs {
+				r.patchID = c.patchIDs[i]
+			}
+			records = append(records, r)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].date.After(records[j].date)
+	})
+
+	// Records are newest first, so the last copy of a change is the earliest
+	if keepPatchIDs {
+		earliest := make(map[string]int)
+		for i, r := range records {
+			if r.patchID != "" {
+				earliest[r.patchID] = i
+			}
+		}
+		kept := records[:0]
+		for i, r := range records {
+			if r.patchID == "" || earliest[r.patchID] == i {
+				kept = append(kept, r)
+			}
+		}
+		collapsed += len(records) - len(kept)
+		records = kept
+	}
+
+	merged := commitColumns{dates: make([]time.Time, len(records)), collapsed: collapsed}
+	if keepLines {
+		merged.lines = make([]int, len(records))
+	}
+	if keepHashes {
+		merged.hashes = make([]string, len(records))
+	}
+	if keepPatchIDs {
+		merged.patchIDs = make([]string, len(records))
+	}
+	for i, r := range records {
+		merged.dates[i] = r.date
+		if keepLines {
+			merged.lines[i] = r.lines
+		}
+		if keepHashes {
+			merged.hashes[i] = r.hash
+		}
+		if keepPatchIDs {
+			merged.patchIDs[i] = r.patchID
+		}
+	}
+	return merged
+}
+
+// mergeHistory combines new commits with earlier history, dropping
+// duplicates and anything that fell out of the detailed window. A zero since
+// keeps everything. Commits with the same patch-id keep the earliest copy.
+func mergeHistory(added, existing []CommitHistory, since time.Time) []CommitHistory {
+	seen := make(map[string]bool, len(added)+len(existing))
+	merged := make([]CommitHistory, 0, len(added)+len(existing))
+	for _, list := range [][]CommitHistory{added, existing} {
+		for _, commit := range list {
+			if seen[commit.Hash] || commit.Date.Before(since) {
+				continue
+			}
+			seen[commit.Hash] = true
+			merged = append(merged, commit)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Date.After(merged[j].Date)
+	})
+	merged = collapseHistory(merged)
+	markOutliers(merged)
+	return merged
+}
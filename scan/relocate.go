@@ -0,0 +1,150 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/AccursedGalaxy/streakode/config"
+	"github.com/AccursedGalaxy/streakode/scan/gitobj"
+)
+
+// Fingerprint identifies a repository independent of where it is checked
+// out: its root commit and origin remote. Clones of one project share it.
+// It is empty when the root commit is unknown.
+func (m RepoMetadata) Fingerprint() string {
+	return fingerprint(m.RootCommit, m.RemoteURL)
+}
+
+func fingerprint(root, remote string) string {
+	if root == "" || remote == "" {
+		return root
+	}
+	return root + " " + remote
+}
+
+// readRemoteURL returns the normalized URL of the repository's origin
+// remote, or of its first remote when there is no origin, "" without remotes
+func readRemoteURL(repoPath string) string {
+	gitDir, err := gitobj.FindGitDir(repoPath)
+	if err != nil {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(gitobj.CommonDir(gitDir), "config"))
+	if err != nil {
+		return ""
+	}
+
+	var first, origin string
+	remote := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			// [remote "name"]
+			remote = ""
+			section, name, ok := strings.Cut(strings.Trim(line, "[]"), " ")
+			if ok && strings.EqualFold(section, "remote") {
+				remote = strings.Trim(strings.TrimSpace(name), `"`)
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if remote == "" || !ok || !strings.EqualFold(strings.TrimSpace(key), "url") {
+			continue
+		}
+		value = normalizeRemoteURL(strings.Trim(strings.TrimSpace(value), `"`))
+		if first == "" {
+			first = value
+		}
+		if remote == "origin" && origin == "" {
+			origin = value
+		}
+	}
+	if origin != "" {
+		return origin
+	}
+	return first
+}
+
+// normalizeRemoteURL reduces the ways of writing one remote to a single
+// form: "git@github.com:me/tool.git", "ssh://git@github.com/me/tool" and
+// "https://github.com/me/tool/" all become "github.com/me/tool"
+func normalizeRemoteURL(remote string) string {
+	remote = strings.TrimSuffix(strings.TrimRight(remote, "/"), ".git")
+	if strings.Contains(remote, "://") {
+		if u, err := url.Parse(remote); err == nil && u.Host != "" {
+			return strings.ToLower(u.Hostname()) + "/" + strings.Trim(u.Path, "/")
+		}
+		return remote
+	}
+	// scp-like syntax, [user@]host:path, unless it is a local path
+	if host, repoPath, ok := strings.Cut(remote, ":"); ok && !strings.Contains(host, "/") && len(host) > 1 {
+		if _, h, ok := strings.Cut(host, "@"); ok {
+			host = h
+		}
+		return strings.ToLower(host) + "/" + strings.Trim(repoPath, "/")
+	}
+	return remote
+}
+
+// relocations finds the earlier scans of repositories that were moved or
+// renamed: cached entries whose path is gone, matched by fingerprint. Each
+// entry is handed out once.
+type relocations struct {
+	byFingerprint map[string][]string // fingerprint -> gone paths, sorted
+}
+
+// findRelocations collects the entries of previous whose path no longer
+// exists
+func findRelocations(previous map[string]RepoMetadata) *relocations {
+	r := &relocations{byFingerprint: make(map[string][]string)}
+	for repoPath, meta := range previous {
+		key := meta.Fingerprint()
+		if key == "" {
+			continue
+		}
+		if _, err := os.Stat(repoPath); !os.IsNotExist(err) {
+			continue
+		}
+		r.byFingerprint[key] = append(r.byFingerprint[key], repoPath)
+	}
+	for _, paths := range r.byFingerprint {
+		sort.Strings(paths)
+	}
+	return r
+}
+
+// claim returns the gone path whose cached entry belongs to the repository
+// at repoPath, if any. Entries scanned without a remote also match by root
+// commit alone.
+func (r *relocations) claim(ctx context.Context, repoPath string) (string, bool) {
+	if len(r.byFingerprint) == 0 {
+		return "", false
+	}
+	root, err := readRootCommit(ctx, repoPath)
+	if err != nil {
+		return "", false
+	}
+	for _, key := range []string{fingerprint(root, readRemoteURL(repoPath)), root} {
+		paths := r.byFingerprint[key]
+		if len(paths) == 0 {
+			continue
+		}
+		r.byFingerprint[key] = paths[1:]
+		if len(paths) == 1 {
+			delete(r.byFingerprint, key)
+		}
+		if config.AppConfig.Debug {
+			fmt.Printf("Debug: %s was moved to %s\n", paths[0], repoPath)
+		}
+		return paths[0], true
+	}
+	return "", false
+}
@@ -0,0 +1,87 @@
+package scan
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AccursedGalaxy/streakode/config"
+)
+
+// RepoStatus is the outcome of scanning one discovered repository
+type RepoStatus string
+
+const (
+	StatusScanned     RepoStatus = "scanned"     // counted toward your stats
+	StatusSkipped     RepoStatus = "skipped"     // scanned, but without recent commits of yours
+	StatusExcluded    RepoStatus = "excluded"    // matched an exclusion rule or duplicated another repository
+	StatusFailed      RepoStatus = "failed"      // could not be read, fully or in part
+	StatusInterrupted RepoStatus = "interrupted" // not read because the scan was cancelled
+)
+
+// RepoOutcome records what happened to one repository during a scan
+type RepoOutcome struct {
+	Path     string        `json:"path"`
+	Kind     RepoKind      `json:"kind,omitempty"` // "" when the directory itself could not be read
+	Status   RepoStatus    `json:"status"`
+	Reason   string        `json:"reason,omitempty"`
+	Duration time.Duration `json:"duration"` // time spent reading the repository
+
+	MovedFrom string `json:"moved_from,omitempty"` // cached path of a moved or renamed repository
+}
+
+// ScanReport describes the outcome of a scan, one entry per discovered
+// repository or unreadable directory, in discovery order
+type ScanReport struct {
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	Dirs     []string      `json:"dirs"`
+	Repos    []RepoOutcome `json:"repos"`
+}
+
+// Count returns how many repositories ended with status
+func (r *ScanReport) Count(status RepoStatus) int {
+	count := 0
+	for _, repo := range r.Repos {
+		if repo.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// Failed returns the repositories that could not be read
+func (r *ScanReport) Failed() []RepoOutcome {
+	var failed []RepoOutcome
+	for _, repo := range r.Repos {
+		if repo.Status == StatusFailed {
+			failed = append(failed, repo)
+		}
+	}
+	return failed
+}
+
+// outcomeOf classifies a scanned repository
+func outcomeOf(meta RepoMetadata, kind RepoKind, duration time.Duration) RepoOutcome {
+	outcome := RepoOutcome{Path: meta.Path, Kind: kind, Status: StatusScanned, Duration: duration}
+	switch {
+	case len(meta.ScanErrors) > 0:
+		outcome.Status = StatusFailed
+		outcome.Reason = strings.Join(meta.ScanErrors, "; ")
+	case !meta.AuthorVerified:
+		outcome.Status = StatusSkipped
+		outcome.Reason = "no commits by the configured author"
+	case meta.Dormant:
+		outcome.Status = StatusSkipped
+		outcome.Reason = fmt.Sprintf("dormant, last commit %s", meta.LastCommit.Format("2006-01-02"))
+	}
+	return outcome
+}
+
+// scanFailed records a failure to read part of a repository
+func (m *RepoMetadata) scanFailed(what string, err error) {
+	if config.AppConfig.Debug {
+		fmt.Printf("Debug: %s failed for %s: %v\n", what, m.Path, err)
+	}
+	m.ScanErrors = append(m.ScanErrors, fmt.Sprintf("%s: %v", what, err))
+}
@@ -1,13 +1,15 @@
 package scan
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"path"
+	"runtime"
 	"sort"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/AccursedGalaxy/streakode/config"
@@ -18,9 +20,26 @@ type CommitHistory struct {
 	Hash        string    `json:"hash"`
 	MessageHead string    `json:"message_head"`
 	Author      string    `json:"author"`
+	Identity    string    `json:"identity"` // canonical "Name <email>" after .mailmap
 	FileCount   int       `json:"file_count"`
 	Additions   int       `json:"additions"`
 	Deletions   int       `json:"deletions"`
+
+	// Lines before change_settings rules, when they differ from the counted ones
+	RawAdditions int  `json:"raw_additions,omitempty"`
+	RawDeletions int  `json:"raw_deletions,omitempty"`
+	Outlier      bool `json:"outlier,omitempty"` // changed far more lines than the repository's usual commit
+
+	Languages map[string]int `json:"languages,omitempty"` // changed lines per language
+
+	// Conventional Commits fields parsed from the message
+	Type     string              `json:"type,omitempty"`
+	Scope    string              `json:"scope,omitempty"`
+	Breaking bool                `json:"breaking,omitempty"`
+	Trailers map[string][]string `json:"trailers,omitempty"`
+
+	CoAuthored bool   `json:"co_authored,omitempty"` // credited through a Co-authored-by trailer, someone else drove
+	PatchID    string `json:"patch_id,omitempty"`    // stable patch-id when rebased and cherry-picked copies are collapsed
 }
 
 type DailyStats struct {
@@ -66,49 +85,78 @@ type RepoMetadata struct {
 	TotalFiles    int                   `json:"total_files"`
 	Languages     map[string]int        `json:"languages"`
 	Contributors  map[string]int        `json:"contributors"`
+	HistorySince  time.Time             `json:"history_since"`         // start of the window CommitHistory covers
+	HistoryAll    bool                  `json:"history_all,omitempty"` // CommitHistory covers all history
+
+	// State for incremental refreshes
+	RefTips      map[string]string `json:"ref_tips"`                // ref name -> commit hash at last scan
+	CommitDates  []time.Time       `json:"commit_dates"`            // author dates of all counted commits, newest first
+	CommitLines  []int             `json:"commit_lines,omitempty"`  // changed lines per CommitDates entry, kept when streaks need them
+	CommitHashes []string          `json:"commit_hashes,omitempty"` // hash per CommitDates entry
+	CoAuthored   bool              `json:"co_authored,omitempty"`   // CommitDates include commits crediting the author as a co-author
+	RootCommit   string            `json:"root_commit,omitempty"`   // first-parent root of HEAD, shared by clones and forks
+	RemoteURL    string            `json:"remote_url,omitempty"`    // normalized origin URL, part of the fingerprint
+	ScanErrors   []string          `json:"scan_errors,omitempty"`   // what could not be read during the last scan
+
+	// Rebased and cherry-picked copies of a commit collapsed by patch-id
+	PatchDeduped     bool     `json:"patch_deduped,omitempty"`     // CommitDates were collapsed by patch-id
+	CommitPatchIDs   []string `json:"commit_patch_ids,omitempty"`  // patch-id per CommitDates entry, "" for merges
+	CollapsedCommits int      `json:"collapsed_commits,omitempty"` // copies dropped in favor of the earliest authored one
+
+	ChangeRules string `json:"change_rules,omitempty"` // change_settings rules line counts were filtered with
+	IdentityKey string `json:"identity_key,omitempty"` // author and identities whose commits were counted
+
+	// TotalLines of the HEAD tree split into code, comment and blank lines
+	CodeLines    int    `json:"code_lines,omitempty"`
+	CommentLines int    `json:"comment_lines,omitempty"`
+	BlankLines   int    `json:"blank_lines,omitempty"`
+	TreeHash     string `json:"tree_hash,omitempty"` // HEAD tree the language statistics were counted from
+
+	FrozenDays []string `json:"frozen_days,omitempty"` // days of the current streak covered by a freeze
 }
 
-// DateRange represents a time period with start (inclusive) and end (exclusive) dates
+// DateRange represents a time period with start (inclusive) and end (exclusive) dates.
+// Start and End are days as returned by DayOf, midnight in the configured zone.
 type DateRange struct {
 	Start time.Time
 	End   time.Time
 }
 
 // IsInDateRange checks if a date falls within a date range
-// The range is inclusive of the start date and exclusive of the end date
+// The range is inclusive of the start date and exclusive of the end date.
+// Dates count toward the day DayOf puts them on, as they do for streaks.
 func IsInDateRange(date time.Time, dateRange DateRange) bool {
-	dateYMD := date.Format("2006-01-02")
-	startYMD := dateRange.Start.Format("2006-01-02")
-	endYMD := dateRange.End.Format("2006-01-02")
+	dateYMD := DayKey(date)
+	startYMD := LocalTime(dateRange.Start).Format(dayKeyLayout)
+	endYMD := LocalTime(dateRange.End).Format(dayKeyLayout)
 
 	return dateYMD >= startYMD && dateYMD < endYMD
 }
 
 // GetCurrentWeekRange returns the date range for the current week (Monday to Sunday)
 func GetCurrentWeekRange() DateRange {
-	now := time.Now().UTC()
+	return weekRangeOf(DayOf(time.Now()))
+}
 
+// weekRangeOf returns the week (Monday to Sunday) holding the day today
+func weekRangeOf(today time.Time) DateRange {
 	if config.AppConfig.Debug {
 		fmt.Printf("Debug: Calculating week range\n")
-		fmt.Printf("Debug: Current time: %s\n", now.Format("2006-01-02 15:04:05 -0700"))
-		fmt.Printf("Debug: Current weekday: %s\n", now.Weekday())
+		fmt.Printf("Debug: Current day: %s\n", today.Format("2006-01-02 -0700"))
+		fmt.Printf("Debug: Current weekday: %s\n", today.Weekday())
 	}
 
-	// Get the start of the current day
-	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
-
 	// Calculate days since last Monday
-	daysFromMonday := int(now.Weekday())
+	daysFromMonday := int(today.Weekday())
 	if daysFromMonday == 0 { // Sunday
 		daysFromMonday = 7
 	}
 	daysFromMonday-- // Adjust to make Monday 0
 
 	// Calculate start of week (last Monday)
-	startDate := startOfDay.AddDate(0, 0, -daysFromMonday)
+	startDate := today.AddDate(0, 0, -daysFromMonday)
 
 	if config.AppConfig.Debug {
-		fmt.Printf("Debug: Start of day: %s\n", startOfDay.Format("2006-01-02"))
 		fmt.Printf("Debug: Days from Monday: %d\n", daysFromMonday)
 		fmt.Printf("Debug: Week start date: %s\n", startDate.Format("2006-01-02"))
 	}
@@ -130,21 +178,17 @@ func GetPreviousWeekRange() DateRange {
 
 // GetMonthRange returns the date range for the specified number of months back
 func GetMonthRange(monthsBack int) DateRange {
-	now := time.Now()
+	today := DayOf(time.Now())
 
 	// Calculate start of the target month
-	startDate := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	startDate := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
 	startDate = startDate.AddDate(0, -monthsBack, 0)
 
-	// Calculate end of the month (23:59:59 of the last day)
-	endDate := time.Date(startDate.Year(), startDate.Month()+1, 1, 0, 0, 0, 0, startDate.Location())
-	endDate = endDate.Add(-time.Second) // Move to 23:59:59 of the previous day
-
-	return DateRange{Start: startDate, End: endDate}
+	return DateRange{Start: startDate, End: startDate.AddDate(0, 1, 0)}
 }
 
 // countCommitsInRange counts commits within a specific date range
-func countCommitsInRange(dates []string, dateRange DateRange) int {
+func countCommitsInRange(dates []time.Time, dateRange DateRange) int {
 	count := 0
 	uniqueDays := make(map[string]bool)
 
@@ -154,29 +198,11 @@ func countCommitsInRange(dates []string, dateRange DateRange) int {
 			dateRange.End.Format("2006-01-02"))
 	}
 
-	for _, dateStr := range dates {
-		// Split the date string since it contains multiple fields
-		parts := strings.Split(dateStr, "|")
-		if len(parts) < 1 {
-			continue
-		}
-
-		commitDate, err := time.Parse(time.RFC3339, parts[0])
-		if err != nil {
-			if config.AppConfig.Debug {
-				fmt.Printf("Debug: Failed to parse date %s: %v\n", parts[0], err)
-			}
-			continue
-		}
-
-		// Convert to UTC for consistent comparison
-		commitDate = commitDate.UTC()
-		dayKey := commitDate.Format("2006-01-02")
-
-		// Check if the commit falls within the date range (inclusive)
-		if !commitDate.Before(dateRange.Start) && commitDate.Before(dateRange.End) {
+	for _, commitDate := range dates {
+		// Check if the commit falls within the date range
+		if IsInDateRange(commitDate, dateRange) {
 			count++
-			uniqueDays[dayKey] = true
+			uniqueDays[DayKey(commitDate)] = true
 			if config.AppConfig.Debug && count%10 == 0 {
 				fmt.Printf("Debug: Found %d commits across %d unique days\n",
 					count, len(uniqueDays))
@@ -192,7 +218,7 @@ func countCommitsInRange(dates []string, dateRange DateRange) int {
 }
 
 // Refactored version of countLastWeeksCommits using date ranges
-func countLastWeeksCommits(dates []string) int {
+func countLastWeeksCommits(dates []time.Time) int {
 	previousWeek := GetPreviousWeekRange()
 	if config.AppConfig.Debug {
 		fmt.Printf("Debug: Calculating last week's commits (%s to %s)\n",
@@ -202,15 +228,13 @@ func countLastWeeksCommits(dates []string) int {
 	return countCommitsInRange(dates, previousWeek)
 }
 
-// Refactored version of countRecentCommits using date ranges
-func countRecentCommits(dates []string, days int) int {
-	now := time.Now().UTC()
-	startDate := now.AddDate(0, 0, -days) // Go back 'days' days
-	startDate = time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, time.UTC)
-
+// Refactored version of countRecentCommits using date ranges. Counts today
+// and the days before it.
+func countRecentCommits(dates []time.Time, days int) int {
+	today := DayOf(time.Now())
 	dateRange := DateRange{
-		Start: startDate,
-		End:   now,
+		Start: today.AddDate(0, 0, -days), // Go back 'days' days
+		End:   today.AddDate(0, 0, 1),
 	}
 
 	if config.AppConfig.Debug {
@@ -223,7 +247,7 @@ func countRecentCommits(dates []string, days int) int {
 
 // Refactored version of countCommitsInPeriod using DateRange
 func countCommitsInPeriod(history []CommitHistory, start, end time.Time) int {
-	dateRange := DateRange{Start: start, End: end}
+	dateRange := DateRange{Start: DayOf(start), End: DayOf(end)}
 	count := 0
 
 	if config.AppConfig.Debug {
@@ -250,7 +274,7 @@ func countCommitsInPeriod(history []CommitHistory, start, end time.Time) int {
 }
 
 // fetchRepoMeta - gets metadata for a single repository and verifies user
-func fetchRepoMeta(repoPath, author string) RepoMetadata {
+func fetchRepoMeta(ctx context.Context, repoPath, author string) RepoMetadata {
 	if config.AppConfig.Debug {
 		fmt.Printf("\nDebug: Fetching metadata for repo: %s (author: %s)\n", repoPath, author)
 	}
@@ -261,91 +285,109 @@ func fetchRepoMeta(repoPath, author string) RepoMetadata {
 	}
 
 	// Check if directory exists and is accessible
-	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
-		if config.AppConfig.Debug {
-			fmt.Printf("Debug: Directory not found: %s\n", repoPath)
-		}
+	if _, err := os.Stat(repoPath); err != nil {
+		meta.scanFailed("opening repository", err)
 		return meta
 	}
 
-	// Get commit dates in a single git command using RFC3339 format
-	cmd := exec.Command("git", "-C", repoPath, "log", "--all",
-		"--author="+author,
-		"--pretty=format:%aI|%H|%an|%ae|%s")
-
-	if config.AppConfig.Debug {
-		fmt.Printf("Debug: Running git command: %v\n", cmd.String())
+	tips, err := readRefTips(ctx, repoPath)
+	if err != nil {
+		meta.scanFailed("reading refs", err)
+		return meta
+	}
+	meta.RefTips = tips
+	if len(tips) == 0 {
+		return meta
 	}
+	if root, err := readRootCommit(ctx, repoPath); err == nil {
+		meta.RootCommit = root
+	} else if config.AppConfig.Debug {
+		fmt.Printf("Debug: Reading root commit failed: %v\n", err)
+	}
+	meta.RemoteURL = readRemoteURL(repoPath)
 
-	output, err := cmd.Output()
+	needsLines := ConfiguredStreakPolicy().NeedsLines()
+	entries, err := readCommits(ctx, repoPath, logOptions{
+		Authors: authorIdentities(author), Tips: tipHashes(tips), NumStat: needsLines, CoAuthors: countCoAuthored(),
+		PatchIDs: dedupPatchIDs(),
+	})
 	if err != nil {
-		if config.AppConfig.Debug {
-			fmt.Printf("Debug: Git command failed: %v\n", err)
-		}
+		meta.scanFailed("reading commits", err)
 		return meta
 	}
 
-	if len(output) > 0 {
-		meta.AuthorVerified = true
-		dates := strings.Split(string(output), "\n")
-		meta.CommitCount = len(dates)
+	meta.setColumns(mergeCommits(columnsOf(entries, needsLines)))
+	meta.CoAuthored = countCoAuthored()
+	meta.PatchDeduped = dedupPatchIDs()
+	meta.ChangeRules = configuredChangeFilter().key()
+	meta.IdentityKey = identityKey(author)
+	if config.AppConfig.Debug && meta.CollapsedCommits > 0 {
+		fmt.Printf("Debug: Collapsed %d rebased or cherry-picked commits in %s\n", meta.CollapsedCommits, repoPath)
+	}
+	meta.updateCommitMetrics()
 
+	// Detailed stats if configured
+	if meta.AuthorVerified && config.AppConfig.DetailedStats {
 		if config.AppConfig.Debug {
-			fmt.Printf("Debug: Found %d commits\n", meta.CommitCount)
+			fmt.Println("Debug: Collecting detailed stats...")
 		}
+		meta.initDetailedStats()
+		meta.updateDetailedStats(ctx, repoPath, author)
+	}
 
-		// Parse first date for last commit
-		parts := strings.Split(dates[0], "|")
-		if len(parts) >= 1 {
-			if lastCommitTime, err := time.Parse(time.RFC3339, parts[0]); err == nil {
-				meta.LastCommit = lastCommitTime.UTC()
-				meta.Dormant = time.Since(meta.LastCommit) > time.Duration(config.AppConfig.DormantThreshold)*24*time.Hour
-
-				if config.AppConfig.Debug {
-					fmt.Printf("Debug: Last commit: %s (Dormant: %v)\n",
-						meta.LastCommit.Format("2006-01-02 15:04:05"),
-						meta.Dormant)
-				}
-			}
-		}
+	return meta
+}
 
-		// Quick stats
-		meta.WeeklyCommits = countRecentCommits(dates, 7)
-		monthlyTotal := countRecentCommits(dates, 30)
-		meta.MonthlyCommits = monthlyTotal
-		meta.LastWeeksCommits = countLastWeeksCommits(dates)
+// updateCommitMetrics derives counts, streaks and dormancy from CommitDates
+func (m *RepoMetadata) updateCommitMetrics() {
+	dates := m.CommitDates
+	m.AuthorVerified = len(dates) > 0
+	m.CommitCount = len(dates)
+	m.CurrentStreak, m.LongestStreak, m.MostActiveDay = 0, 0, ""
+	m.FrozenDays = nil
+	if len(dates) == 0 {
+		return
+	}
 
-		if config.AppConfig.Debug {
-			fmt.Printf("Debug: Weekly commits: %d\n", meta.WeeklyCommits)
-			fmt.Printf("Debug: Monthly commits: %d\n", monthlyTotal)
-			fmt.Printf("Debug: Last week's commits: %d\n", meta.LastWeeksCommits)
-		}
+	if config.AppConfig.Debug {
+		fmt.Printf("Debug: Found %d commits\n", m.CommitCount)
+	}
 
-		// Only compute streak info if the repo is active
-		if !meta.Dormant {
-			streakInfo := calculateStreakInfo(dates)
-			meta.CurrentStreak = streakInfo.Current
-			meta.LongestStreak = streakInfo.Longest
-			meta.MostActiveDay = findMostActiveDay(dates)
+	// Commits are kept newest first
+	m.LastCommit = dates[0].UTC()
+	m.Dormant = time.Since(m.LastCommit) > time.Duration(config.AppConfig.DormantThreshold)*24*time.Hour
 
-			if config.AppConfig.Debug {
-				fmt.Printf("Debug: Current streak: %d days\n", meta.CurrentStreak)
-				fmt.Printf("Debug: Longest streak: %d days\n", meta.LongestStreak)
-				fmt.Printf("Debug: Most active day: %s\n", meta.MostActiveDay)
-			}
-		}
+	if config.AppConfig.Debug {
+		fmt.Printf("Debug: Last commit: %s (Dormant: %v)\n",
+			m.LastCommit.Format("2006-01-02 15:04:05"),
+			m.Dormant)
+	}
 
-		// Detailed stats if configured
-		if config.AppConfig.DetailedStats {
-			if config.AppConfig.Debug {
-				fmt.Println("Debug: Collecting detailed stats...")
-			}
-			meta.initDetailedStats()
-			meta.updateDetailedStats(repoPath, author)
-		}
+	// Quick stats
+	m.WeeklyCommits = countRecentCommits(dates, 7)
+	m.MonthlyCommits = countRecentCommits(dates, 30)
+	m.LastWeeksCommits = countLastWeeksCommits(dates)
+
+	if config.AppConfig.Debug {
+		fmt.Printf("Debug: Weekly commits: %d\n", m.WeeklyCommits)
+		fmt.Printf("Debug: Monthly commits: %d\n", m.MonthlyCommits)
+		fmt.Printf("Debug: Last week's commits: %d\n", m.LastWeeksCommits)
 	}
 
-	return meta
+	// Only compute streak info if the repo is active
+	if !m.Dormant {
+		streakInfo := ConfiguredStreakPolicy().Calculate(activityOf(dates, m.CommitLines))
+		m.CurrentStreak = streakInfo.Current
+		m.LongestStreak = streakInfo.Longest
+		m.FrozenDays = streakInfo.FrozenDays
+		m.MostActiveDay = findMostActiveDay(dates)
+
+		if config.AppConfig.Debug {
+			fmt.Printf("Debug: Current streak: %d days\n", m.CurrentStreak)
+			fmt.Printf("Debug: Longest streak: %d days\n", m.LongestStreak)
+			fmt.Printf("Debug: Most active day: %s\n", m.MostActiveDay)
+		}
+	}
 }
 
 // Initialize maps only when needed
@@ -355,255 +397,294 @@ func (m *RepoMetadata) initDetailedStats() {
 	m.Contributors = make(map[string]int)
 }
 
-func (m *RepoMetadata) updateDetailedStats(repoPath, author string) {
-	since := time.Now().AddDate(0, 0, -30) // Only fetch last 30 days for detailed stats
+// detailedHistorySince is the start of the window kept in CommitHistory. The
+// zero time means all history is kept.
+func detailedHistorySince() time.Time {
+	days := config.AppConfig.HistoryDays
+	if days < 0 {
+		return time.Time{}
+	}
+	if days == 0 {
+		days = 30
+	}
+	return time.Now().AddDate(0, 0, -days)
+}
+
+// historyCovers reports whether the detailed history reaches back to since
+func (m *RepoMetadata) historyCovers(since time.Time) bool {
+	if m.HistoryAll {
+		return true
+	}
+	return !since.IsZero() && !m.HistorySince.IsZero() && !since.Before(m.HistorySince)
+}
+
+// setHistoryWindow records the window CommitHistory now covers
+func (m *RepoMetadata) setHistoryWindow(since time.Time) {
+	m.HistorySince = since
+	m.HistoryAll = since.IsZero()
+}
+
+func (m *RepoMetadata) updateDetailedStats(ctx context.Context, repoPath, author string) {
+	since := detailedHistorySince()
 
 	// Fetch commit history
-	if history, err := fetchDetailedCommitInfo(repoPath, author, since); err == nil {
+	if history, err := fetchDetailedCommitInfo(ctx, repoPath, author, since); err == nil {
 		m.CommitHistory = history
+		m.DailyStats = buildDailyStats(history)
+		m.setHistoryWindow(since)
 	} else {
-		fmt.Printf("Error collecting detailed stats for %s: %v\n", repoPath, err)
+		m.scanFailed("collecting detailed stats", err)
 	}
 
 	// Fetch language statistics
-	if languages, err := fetchLanguageStats(repoPath); err == nil {
-		m.Languages = languages
-
-		// Calculate total lines across all languages
-		totalLines := 0
-		for _, lines := range languages {
-			totalLines += lines
-		}
-		m.TotalLines = totalLines
+	if stats, err := fetchLanguageStats(ctx, repoPath); err == nil {
+		m.setTreeStats(stats)
 	} else {
-		fmt.Printf("Error collecting language stats for %s: %v\n", repoPath, err)
+		m.scanFailed("collecting language stats", err)
 	}
 }
 
-func fetchDetailedCommitInfo(repoPath string, author string, since time.Time) ([]CommitHistory, error) {
-	var history []CommitHistory
-
-	// Get detailed git log with stats using RFC3339 format
-	gitCmd := exec.Command("git", "-C", repoPath, "log",
-		"--all",
-		"--author="+author,
-		"--pretty=format:%aI|%H|%an|%s",
-		"--numstat",
-		"--after="+since.Format("2006-01-02"))
-
-	output, err := gitCmd.Output()
+func fetchDetailedCommitInfo(ctx context.Context, repoPath string, author string, since time.Time) ([]CommitHistory, error) {
+	entries, err := readCommits(ctx, repoPath, logOptions{
+		Authors: authorIdentities(author), Since: since, NumStat: true, CoAuthors: true, PatchIDs: dedupPatchIDs(),
+	})
 	if err != nil {
-		return nil, fmt.Errorf("git command failed: %v", err)
+		return nil, err
 	}
 
-	// Parse the git log output
-	lines := strings.Split(string(output), "\n")
-	var currentCommit *CommitHistory
-
-	for _, line := range lines {
-		if strings.Contains(line, "|") {
-			// This is a commit header line
-			parts := strings.Split(line, "|")
-			if len(parts) == 4 {
-				if currentCommit != nil {
-					history = append(history, *currentCommit)
-				}
-
-				commitTime, err := time.Parse(time.RFC3339, parts[0])
-				if err != nil {
-					continue
-				}
-				currentCommit = &CommitHistory{
-					Hash:        parts[1],
-					Date:        commitTime.UTC(), // Store in UTC for consistency
-					Author:      parts[2],
-					MessageHead: parts[3],
-				}
-			}
-		} else if line != "" && currentCommit != nil {
-			// This is a stats line
-			parts := strings.Fields(line)
-			if len(parts) == 3 {
-				// Handle binary files and renames
-				if parts[0] == "-" || parts[1] == "-" {
-					currentCommit.FileCount++
-					continue
-				}
-				additions, _ := strconv.Atoi(parts[0])
-				deletions, _ := strconv.Atoi(parts[1])
-				currentCommit.Additions += additions
-				currentCommit.Deletions += deletions
-				currentCommit.FileCount++
-			}
-		}
-	}
-
-	if currentCommit != nil {
-		history = append(history, *currentCommit)
+	history := make([]CommitHistory, 0, len(entries))
+	for _, entry := range entries {
+		history = append(history, entry.toHistory())
 	}
-
+	history = collapseHistory(history)
+	markOutliers(history)
 	return history, nil
 }
 
-// ScanDirectories - scans for Git repositories in the specified directories
-func ScanDirectories(dirs []string, author string, shouldExclude func(string) bool) ([]RepoMetadata, error) {
-	var repos []RepoMetadata
-	var skippedDirs []string
-
-	for _, dir := range dirs {
-		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-			// Handle directory access errors gracefully
-			if err != nil {
-				skippedDirs = append(skippedDirs, dir)
-				return filepath.SkipDir
-			}
-			if info == nil {
-				return nil
-			}
-			if info.IsDir() && info.Name() == ".git" {
-				repoPath := filepath.Dir(path)
-				if shouldExclude(repoPath) {
-					return nil
-				}
-				meta := fetchRepoMeta(repoPath, author)
-				if meta.AuthorVerified {
-					if !meta.Dormant {
-						repos = append(repos, meta)
-					}
-				}
-			}
-			return nil
-		})
-
-		// Handle initial directory access error
-		if err != nil {
-			skippedDirs = append(skippedDirs, dir)
-			continue // Skip to next directory instead of returning error
-		}
+// commitDates extracts author dates, preserving log order
+func commitDates(entries []logEntry) []time.Time {
+	dates := make([]time.Time, len(entries))
+	for i, entry := range entries {
+		dates[i] = entry.Date
 	}
+	return dates
+}
 
-	// Print warnings for skipped directories
-	if len(skippedDirs) > 0 {
-		fmt.Println("\nWarning: The following directories were skipped due to access issues:")
-		for _, dir := range skippedDirs {
-			fmt.Printf("- %s\n", dir)
-		}
+// commitLines extracts changed lines, preserving log order
+func commitLines(entries []logEntry) []int {
+	lines := make([]int, len(entries))
+	for i, entry := range entries {
+		lines[i] = entry.Additions + entry.Deletions
 	}
-
-	return repos, nil
+	return lines
 }
 
-// Add this new function to track both current and longest streaks
-type StreakInfo struct {
-	Current int
-	Longest int
+// ScanProgress is a snapshot of an ongoing directory scan
+type ScanProgress struct {
+	Found   int  // repositories discovered so far
+	Scanned int  // repositories whose metadata has been read
+	Skipped int  // excluded repositories and unreadable directories
+	Done    bool // set on the final report once discovery and scanning finished
 }
 
-func calculateStreakInfo(dates []string) StreakInfo {
-	if len(dates) == 0 {
-		return StreakInfo{0, 0}
-	}
+// ProgressFunc receives scan progress. Calls are serialized, never concurrent.
+type ProgressFunc func(ScanProgress)
 
-	dateCache := NewDateCache()
-	uniqueDates := make(map[string]bool)
-	var sortedDates []string
+// scanJob is a discovered repository waiting to be scanned
+type scanJob struct {
+	index     int // position in discovery order, also in the report
+	path      string
+	kind      RepoKind
+	movedFrom string // cached path of the repository before it moved, if any
+}
 
-	if config.AppConfig.Debug {
-		fmt.Printf("Debug: Processing %d commit dates\n", len(dates))
-	}
+// scanResult is the metadata read for a scanJob
+type scanResult struct {
+	index       int
+	meta        RepoMetadata
+	kind        RepoKind
+	duration    time.Duration
+	interrupted bool   // the scan was cancelled before the repository was read
+	movedFrom   string // path the repository was cached under before it moved
+}
 
-	// First pass - parse dates and get unique days in UTC
-	for _, dateStr := range dates {
-		// Split the date string since it contains multiple fields
-		parts := strings.Split(dateStr, "|")
-		if len(parts) < 1 {
-			continue
-		}
+// WithRepoTimeout bounds the time spent reading one repository by the
+// configured scan_settings.repo_timeout, if any
+func WithRepoTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout := repoTimeout(); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
 
-		date, err := time.Parse(time.RFC3339, parts[0])
-		if err != nil {
-			if config.AppConfig.Debug {
-				fmt.Printf("Debug: Failed to parse date %s: %v\n", parts[0], err)
-			}
-			continue
-		}
+func repoTimeout() time.Duration {
+	return time.Duration(config.AppConfig.ScanSettings.RepoTimeout) * time.Second
+}
 
-		// Convert to UTC for consistent date comparison
-		date = date.UTC()
-		ymd := date.Format("2006-01-02")
-		if !uniqueDates[ymd] {
-			uniqueDates[ymd] = true
-			sortedDates = append(sortedDates, ymd)
-			dateCache.Add(dateStr, date)
+// ScanDirectories - scans for Git repositories in the specified directories.
+// Discovery feeds a bounded pool of workers; results are returned in discovery
+// order so the output does not depend on scheduling. Repositories found in
+// previous are refreshed incrementally instead of being read from scratch.
+// Directories matched by exclusions are not searched. The report lists every
+// repository discovered and what became of it; the error is only set when
+// none of the scan directories could be read or ctx was cancelled before
+// every repository was read. A cancelled scan still returns the
+// repositories read completely.
+func ScanDirectories(ctx context.Context, dirs []string, author string, exclusions *Exclusions, previous map[string]RepoMetadata, progress ProgressFunc) ([]RepoMetadata, ScanReport, error) {
+	var (
+		state      ScanProgress
+		progressMu sync.Mutex
+	)
+	notify := func(update func(*ScanProgress)) {
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		update(&state)
+		if progress != nil {
+			progress(state)
 		}
 	}
+	report := ScanReport{Started: time.Now().UTC(), Dirs: dirs}
+
+	workers := config.AppConfig.ScanSettings.Concurrency
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	jobs := make(chan scanJob)
+	results := make(chan scanResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				start := time.Now()
+				repoCtx, cancel := WithRepoTimeout(ctx)
+				prev, known := previous[job.path]
+				if job.movedFrom != "" {
+					// Moved or renamed repositories pick up where their last scan left off
+					prev, known = previous[job.movedFrom], true
+					prev.Path = job.path
+				}
+				var meta RepoMetadata
+				if known {
+					meta = UpdateRepoMeta(repoCtx, prev, author)
+				} else {
+					meta = fetchRepoMeta(repoCtx, job.path, author)
+				}
+				stopped := repoCtx.Err() != nil && len(meta.ScanErrors) > 0
+				cancel()
 
-	// Sort dates in reverse chronological order
-	sort.Sort(sort.Reverse(sort.StringSlice(sortedDates)))
-
-	if config.AppConfig.Debug {
-		fmt.Printf("Debug: Found %d unique dates\n", len(sortedDates))
-	}
+				result := scanResult{
+					index: job.index, meta: meta, kind: job.kind, duration: time.Since(start), movedFrom: job.movedFrom,
+				}
+				switch {
+				case stopped && ctx.Err() != nil:
+					result.interrupted = true
+				case stopped:
+					// A repository that timed out keeps what the last scan knew
+					if known {
+						result.meta = prev
+					}
+					result.meta.ScanErrors = []string{fmt.Sprintf("timed out after %s", repoTimeout())}
+				}
+				notify(func(p *ScanProgress) { p.Scanned++ })
+				results <- result
+			}
+		}()
+	}
+
+	// Discovery runs alongside the workers and closes the pipeline when done.
+	// Outcomes are only written here until jobs is closed, and the collected
+	// results fill in the rest afterwards.
+	var outcomes []RepoOutcome
+	unreadableDirs := 0
+	go func() {
+		defer close(jobs)
+		opts := DiscoveryOptions()
+		opts.Exclusions = exclusions
+		moved := findRelocations(previous)
+		DiscoverRepositories(dirs, opts, func(repo DiscoveredRepo) {
+			notify(func(p *ScanProgress) { p.Found++ })
+			outcome := RepoOutcome{Path: repo.Path, Kind: repo.Kind, Status: StatusExcluded}
+			switch {
+			case repo.ExcludedBy != nil:
+				outcome.Reason = "excluded by " + repo.ExcludedBy.String()
+			case repo.Duplicate != "":
+				outcome.Reason = "shares its object store with " + repo.Duplicate
+			case ctx.Err() != nil:
+				outcome.Status = StatusInterrupted
+				outcome.Reason = "scan interrupted before it was read"
+			default:
+				outcomes = append(outcomes, RepoOutcome{Path: repo.Path, Kind: repo.Kind})
+				job := scanJob{index: len(outcomes) - 1, path: repo.Path, kind: repo.Kind}
+				if _, known := previous[repo.Path]; !known {
+					job.movedFrom, _ = moved.claim(ctx, repo.Path)
+				}
+				jobs <- job
+				return
+			}
+			outcomes = append(outcomes, outcome)
+			notify(func(p *ScanProgress) { p.Skipped++ })
+		}, func(path string, err error) {
+			for _, dir := range dirs {
+				if path == dir {
+					unreadableDirs++
+				}
+			}
+			outcomes = append(outcomes, RepoOutcome{Path: path, Status: StatusFailed, Reason: err.Error()})
+			notify(func(p *ScanProgress) { p.Skipped++ })
+		})
+	}()
 
-	// Calculate current streak
-	currentStreak := 0
-	longestStreak := 0
-	today := time.Now().UTC().Format("2006-01-02")
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
 
-	// Check if there's a commit today
-	if len(sortedDates) > 0 && sortedDates[0] == today {
-		currentStreak = 1
-		longestStreak = 1
+	var collected []scanResult
+	for result := range results {
+		collected = append(collected, result)
 	}
+	sort.Slice(collected, func(i, j int) bool {
+		return collected[i].index < collected[j].index
+	})
 
-	// Calculate streaks
-	for i := 0; i < len(sortedDates)-1; i++ {
-		current, _ := time.Parse("2006-01-02", sortedDates[i])
-		next, _ := time.Parse("2006-01-02", sortedDates[i+1])
-
-		dayDiff := current.Sub(next).Hours() / 24
-
-		if config.AppConfig.Debug && i < 5 { // Limit debug output
-			fmt.Printf("Debug: Comparing %s and %s (%.1f days)\n",
-				sortedDates[i], sortedDates[i+1], dayDiff)
-		}
-
-		if dayDiff <= 1 {
-			currentStreak++
-			if currentStreak > longestStreak {
-				longestStreak = currentStreak
-			}
-		} else {
-			if config.AppConfig.Debug {
-				fmt.Printf("Debug: Streak break found after %d days\n", currentStreak)
+	var repos []RepoMetadata
+	for _, result := range collected {
+		if result.interrupted {
+			outcomes[result.index] = RepoOutcome{
+				Path: result.meta.Path, Kind: result.kind, Status: StatusInterrupted,
+				Reason: "scan interrupted while it was read", Duration: result.duration,
 			}
-			break
+			continue
+		}
+		outcomes[result.index] = outcomeOf(result.meta, result.kind, result.duration)
+		outcomes[result.index].MovedFrom = result.movedFrom
+		if result.meta.AuthorVerified && !result.meta.Dormant {
+			repos = append(repos, result.meta)
 		}
 	}
+	report.Repos = outcomes
+	report.Duration = time.Since(report.Started)
 
-	if config.AppConfig.Debug {
-		fmt.Printf("Debug: Final streaks - Current: %d, Longest: %d\n",
-			currentStreak, longestStreak)
-	}
+	notify(func(p *ScanProgress) { p.Done = true })
 
-	return StreakInfo{currentStreak, longestStreak}
+	if err := ctx.Err(); err != nil && report.Count(StatusInterrupted) > 0 {
+		return repos, report, fmt.Errorf("scan interrupted: %v", err)
+	}
+	if len(dirs) > 0 && unreadableDirs == len(dirs) {
+		return repos, report, fmt.Errorf("none of the %d scan directories could be read", len(dirs))
+	}
+	return repos, report, nil
 }
 
 // FindMostActiveDay - finds the most active day in the last n days
-func findMostActiveDay(dates []string) string {
+func findMostActiveDay(dates []time.Time) string {
 	dayCount := make(map[string]int)
-	for _, dateStr := range dates {
-		// Split the date string since it contains multiple fields
-		parts := strings.Split(dateStr, "|")
-		if len(parts) < 1 {
-			continue
-		}
-
-		commitDate, err := time.Parse(time.RFC3339, parts[0])
-		if err != nil {
-			continue
-		}
-		day := commitDate.Weekday().String()
+	for _, commitDate := range dates {
+		day := DayOf(commitDate).Weekday().String()
 		dayCount[day]++
 	}
 
@@ -618,81 +699,92 @@ func findMostActiveDay(dates []string) string {
 	return maxDay
 }
 
-func fetchLanguageStats(repoPath string) (map[string]int, error) {
-	if config.AppConfig.Debug {
-		fmt.Printf("Debug: Fetching language stats for %s\n", repoPath)
-	}
-
-	languages := make(map[string]int)
-
-	cmd := exec.Command("git", "-C", repoPath, "ls-files")
-	output, err := cmd.Output()
-	if err != nil {
-		if config.AppConfig.Debug {
-			fmt.Printf("Debug: Git ls-files failed: %v\n", err)
+// Helper function to check if an extension is excluded
+func isExcludedExtension(ext string) bool {
+	for _, excluded := range config.AppConfig.LanguageSettings.ExcludedExtensions {
+		if strings.EqualFold(ext, excluded) {
+			return true
 		}
-		return languages, fmt.Errorf("git ls-files failed: %v", err)
 	}
+	return false
+}
 
-	files := strings.Split(string(output), "\n")
-	if config.AppConfig.Debug {
-		fmt.Printf("Debug: Found %d tracked files\n", len(files))
+// pathExclusion returns why a path never counts toward language statistics,
+// or "" if it may
+func pathExclusion(file string, attrs map[string]string) string {
+	if detectable, ok := attrBool(attrs, "linguist-detectable"); ok && !detectable {
+		return "linguist-detectable unset"
 	}
-
-	for _, file := range files {
-		if file == "" {
-			continue
-		}
-
-		if ext := filepath.Ext(file); ext != "" {
-			if isExcludedExtension(ext) {
-				if config.AppConfig.Debug {
-					fmt.Printf("Debug: Skipping excluded extension: %s\n", ext)
-				}
-				continue
-			}
-
-			fullPath := filepath.Join(repoPath, file)
-			if lines, err := countFileLines(fullPath); err == nil {
-				if lines >= config.AppConfig.LanguageSettings.MinimumLines {
-					languages[ext] += lines
-					if config.AppConfig.Debug {
-						fmt.Printf("Debug: Added %d lines for %s (%s)\n", lines, file, ext)
-					}
-				}
-			} else if config.AppConfig.Debug {
-				fmt.Printf("Debug: Error counting lines in %s: %v\n", file, err)
-			}
-		}
+	if documentation, _ := attrBool(attrs, "linguist-documentation"); documentation {
+		return "documentation"
+	}
+	vendored, vendoredSet := attrBool(attrs, "linguist-vendored")
+	if !vendoredSet {
+		vendored = IsVendored(file)
+	}
+	if vendored {
+		return "vendored"
 	}
+	return ""
+}
 
-	if config.AppConfig.Debug {
-		fmt.Println("Debug: Language statistics:")
-		for lang, lines := range languages {
-			fmt.Printf("Debug: %s: %d lines\n", lang, lines)
-		}
+// pathLanguage returns the language a changed file counts toward, judged by
+// its path and attributes alone, or "" if it does not count
+func pathLanguage(file string, attrs map[string]string) string {
+	if pathExclusion(file, attrs) != "" {
+		return ""
+	}
+	generated, generatedSet := attrBool(attrs, "linguist-generated")
+	if generated || (!generatedSet && IsGenerated(file, nil)) {
+		return ""
+	}
+	if ext := path.Ext(file); ext != "" && isExcludedExtension(ext) {
+		return ""
 	}
 
-	return languages, nil
+	language := DetectLanguage(file, nil)
+	if override := attrs["linguist-language"]; override != "" && override != "true" {
+		language = CanonicalLanguage(override)
+	}
+	if language == "" || isExcludedLanguage(language) {
+		return ""
+	}
+	return language
 }
 
-// Helper function to check if an extension is excluded
-func isExcludedExtension(ext string) bool {
-	for _, excluded := range config.AppConfig.LanguageSettings.ExcludedExtensions {
-		if strings.EqualFold(ext, excluded) {
+// isExcludedLanguage reports whether a language is excluded in the config
+func isExcludedLanguage(language string) bool {
+	for _, excluded := range config.AppConfig.LanguageSettings.ExcludedLanguages {
+		if strings.EqualFold(CanonicalLanguage(excluded), language) {
 			return true
 		}
 	}
 	return false
 }
 
-// Helper function to count lines in a file
-func countFileLines(filePath string) (int, error) {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return 0, err
+// LanguagesWritten sums the changed lines per language of commits made at or
+// after since. A zero since includes every commit.
+func LanguagesWritten(history []CommitHistory, since time.Time) map[string]int {
+	written := make(map[string]int)
+	for _, commit := range history {
+		if commit.Date.Before(since) {
+			continue
+		}
+		for language, lines := range commit.Languages {
+			written[language] += lines
+		}
 	}
-	return len(strings.Split(string(content), "\n")), nil
+	return written
+}
+
+// countLines counts the lines in file content, the last one whether or not
+// it ends with a newline
+func countLines(content []byte) int {
+	lines := bytes.Count(content, []byte("\n"))
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		lines++
+	}
+	return lines
 }
 
 // Add these utility functions to analyze the enhanced data
@@ -703,8 +795,7 @@ func (m *RepoMetadata) GetCommitTrend(days int) map[string]int {
 
 	for _, commit := range m.CommitHistory {
 		if commit.Date.After(since) {
-			dateStr := commit.Date.Format("2006-01-02")
-			trend[dateStr]++
+			trend[DayKey(commit.Date)]++
 		}
 	}
 	return trend
@@ -742,7 +833,7 @@ func (m *RepoMetadata) CalculatePeakHours() []TimeSlot {
 
 	// Aggregate commit data by hour
 	for _, commit := range m.CommitHistory {
-		hour := commit.Date.Hour()
+		hour := LocalTime(commit.Date).Hour()
 		slot := hourStats[hour]
 		slot.Commits++
 		slot.Lines += commit.Additions + commit.Deletions
@@ -855,26 +946,28 @@ func (m *RepoMetadata) ValidateData() ValidationResult {
 	}
 
 	// Get monthly range
-	monthRange := DateRange{
-		Start: time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()),
-		End:   now.AddDate(0, 1, 0), // Add one month to include current month
-	}
+	monthRange := GetMonthRange(0)
 
 	monthlyTotal := 0
 	var lastCommit time.Time
 	var lastCommitDay string
 
 	for _, commit := range m.CommitHistory {
+		// Co-authored commits only count when the config says so
+		if commit.CoAuthored && !countCoAuthored() {
+			continue
+		}
+
 		// Track last commit
 		if lastCommit.IsZero() || commit.Date.After(lastCommit) {
 			lastCommit = commit.Date
-			lastCommitDay = commit.Date.Format("2006-01-02")
+			lastCommitDay = DayKey(commit.Date)
 		}
 
 		// Count weekly commits
 		if IsInDateRange(commit.Date, weekRange) {
 			weeklyTotal++
-			uniqueDaysThisWeek[commit.Date.Format("2006-01-02")] = true
+			uniqueDaysThisWeek[DayKey(commit.Date)] = true
 		}
 
 		// Count monthly commits
@@ -902,8 +995,9 @@ func (m *RepoMetadata) ValidateData() ValidationResult {
 				daysSinceLastCommit, lastCommitDay)
 		}
 
-		// Verify current streak with grace period
-		if m.CurrentStreak > 0 {
+		// Verify current streak with grace period, unless the streak rules
+		// let it survive days without commits
+		if m.CurrentStreak > 0 && !ConfiguredStreakPolicy().AllowsGaps() {
 			if daysSinceLastCommit > 2 {
 				result.Issues = append(result.Issues,
 					fmt.Sprintf("Invalid current streak: %d (more than 2 days since last commit)",
@@ -964,6 +1058,7 @@ func (m *RepoMetadata) ValidateData() ValidationResult {
 
 // FetchRepoMetadata - gets metadata for a single repository
 func FetchRepoMetadata(repoPath string) RepoMetadata {
+	ctx := context.Background()
 	if config.AppConfig.Debug {
 		fmt.Printf("\nDebug: Fetching metadata for repo: %s\n", repoPath)
 	}
@@ -981,24 +1076,16 @@ func FetchRepoMetadata(repoPath string) RepoMetadata {
 		return meta
 	}
 
-	// Get commit dates in a single git command using RFC3339 format
-	cmd := exec.Command("git", "-C", repoPath, "log", "--all",
-		"--pretty=format:%aI|%H|%an|%ae|%s")
-
-	if config.AppConfig.Debug {
-		fmt.Printf("Debug: Running git command: %v\n", cmd.String())
-	}
-
-	output, err := cmd.Output()
+	entries, err := readCommits(ctx, repoPath, logOptions{NumStat: true})
 	if err != nil {
 		if config.AppConfig.Debug {
-			fmt.Printf("Debug: Git command failed: %v\n", err)
+			fmt.Printf("Debug: Reading commits failed: %v\n", err)
 		}
 		return meta
 	}
 
-	if len(output) > 0 {
-		commits := strings.Split(string(output), "\n")
+	if len(entries) > 0 {
+		commits := commitDates(entries)
 		meta.CommitCount = len(commits)
 
 		if config.AppConfig.Debug {
@@ -1006,39 +1093,15 @@ func FetchRepoMetadata(repoPath string) RepoMetadata {
 		}
 
 		// Process each commit
-		for _, commit := range commits {
-			parts := strings.Split(commit, "|")
-			if len(parts) != 5 {
-				continue
-			}
-
-			commitDate, err := time.Parse(time.RFC3339, parts[0])
-			if err != nil {
-				continue
-			}
-
-			// Convert to UTC for consistency
-			commitDate = commitDate.UTC()
+		for _, entry := range entries {
+			history := entry.toHistory()
+			history.Author = history.Identity
 
 			// Update last commit time
-			if meta.LastCommit.IsZero() || commitDate.After(meta.LastCommit) {
-				meta.LastCommit = commitDate
+			if meta.LastCommit.IsZero() || history.Date.After(meta.LastCommit) {
+				meta.LastCommit = history.Date
 			}
 
-			// Create commit history entry
-			history := CommitHistory{
-				Date:        commitDate,
-				Hash:        parts[1],
-				Author:      fmt.Sprintf("%s <%s>", parts[2], parts[3]),
-				MessageHead: parts[4],
-			}
-
-			// Get commit stats
-			stats := getCommitStats(repoPath, parts[1])
-			history.FileCount = stats.FileCount
-			history.Additions = stats.Additions
-			history.Deletions = stats.Deletions
-
 			meta.CommitHistory = append(meta.CommitHistory, history)
 		}
 
@@ -1054,59 +1117,21 @@ func FetchRepoMetadata(repoPath string) RepoMetadata {
 		}
 
 		// Calculate streak info
-		streakInfo := calculateStreakInfo(commits)
+		streakInfo := ConfiguredStreakPolicy().Calculate(activityOf(commits, commitLines(entries)))
 		meta.CurrentStreak = streakInfo.Current
 		meta.LongestStreak = streakInfo.Longest
+		meta.FrozenDays = streakInfo.FrozenDays
 		meta.MostActiveDay = findMostActiveDay(commits)
 
 		// Get language statistics
-		if languages, err := fetchLanguageStats(repoPath); err == nil {
-			meta.Languages = languages
-			meta.TotalLines = calculateTotalLines(languages)
+		if stats, err := fetchLanguageStats(ctx, repoPath); err == nil {
+			meta.setTreeStats(stats)
 		}
 	}
 
 	return meta
 }
 
-type commitStats struct {
-	FileCount int
-	Additions int
-	Deletions int
-}
-
-func getCommitStats(repoPath, hash string) commitStats {
-	var stats commitStats
-	cmd := exec.Command("git", "-C", repoPath, "show", "--numstat", "--format=", hash)
-	output, err := cmd.Output()
-	if err != nil {
-		return stats
-	}
-
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-		parts := strings.Fields(line)
-		if len(parts) != 3 {
-			continue
-		}
-		// Handle binary files and renames
-		if parts[0] == "-" || parts[1] == "-" {
-			stats.FileCount++
-			continue
-		}
-		add, _ := strconv.Atoi(parts[0])
-		del, _ := strconv.Atoi(parts[1])
-		stats.Additions += add
-		stats.Deletions += del
-		stats.FileCount++
-	}
-
-	return stats
-}
-
 func calculateTotalLines(languages map[string]int) int {
 	total := 0
 	for _, lines := range languages {
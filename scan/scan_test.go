@@ -1,10 +1,12 @@
 package scan
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -175,21 +177,194 @@ func TestStreakCalculation(t *testing.T) {
 	}
 }
 
+func TestDayBucketing(t *testing.T) {
+	oldZone, oldRollover := config.AppConfig.Timezone, config.AppConfig.DayRolloverHour
+	defer func() {
+		config.AppConfig.Timezone, config.AppConfig.DayRolloverHour = oldZone, oldRollover
+	}()
+
+	config.AppConfig.Timezone = "America/Los_Angeles"
+	config.AppConfig.DayRolloverHour = 4
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+
+	// 06:00 UTC on March 10th is still the evening of March 9th in Los Angeles
+	if got := DayKey(time.Date(2024, 3, 10, 6, 0, 0, 0, time.UTC)); got != "2024-03-09" {
+		t.Errorf("Expected UTC morning commit on 2024-03-09, got %s", got)
+	}
+	// A 2am commit counts toward the previous day, a 4am one does not
+	if got := DayKey(time.Date(2024, 3, 10, 2, 30, 0, 0, loc)); got != "2024-03-09" {
+		t.Errorf("Expected commit before rollover on 2024-03-09, got %s", got)
+	}
+	if got := DayKey(time.Date(2024, 3, 10, 4, 0, 0, 0, loc)); got != "2024-03-10" {
+		t.Errorf("Expected commit at rollover on 2024-03-10, got %s", got)
+	}
+
+	// Late-night commits keep a streak going across the DST change
+	dates := []time.Time{
+		time.Date(2024, 3, 8, 22, 0, 0, 0, loc),
+		time.Date(2024, 3, 10, 1, 0, 0, 0, loc), // counts as March 9th
+		time.Date(2024, 3, 10, 23, 0, 0, 0, loc),
+		time.Date(2024, 3, 12, 9, 0, 0, 0, loc),
+	}
+	if streaks := CalculateStreaks(dates); streaks.Longest != 3 {
+		t.Errorf("Expected longest streak of 3, got %d", streaks.Longest)
+	}
+
+	stats := buildDailyStats([]CommitHistory{
+		{Hash: "a", Date: dates[0].UTC(), Additions: 1},
+		{Hash: "b", Date: dates[1].UTC(), Additions: 2},
+	})
+	if len(stats) != 2 || stats["2024-03-09"].Lines != 2 {
+		t.Errorf("Expected commits bucketed into 2024-03-08 and 2024-03-09, got %v", stats)
+	}
+}
+
+func TestDateRangesFollowDays(t *testing.T) {
+	oldZone, oldRollover := config.AppConfig.Timezone, config.AppConfig.DayRolloverHour
+	defer func() {
+		config.AppConfig.Timezone, config.AppConfig.DayRolloverHour = oldZone, oldRollover
+	}()
+
+	config.AppConfig.Timezone = "America/Los_Angeles"
+	config.AppConfig.DayRolloverHour = 4
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+
+	// Monday March 11th 2024, the day after the DST change
+	week := weekRangeOf(DayOf(time.Date(2024, 3, 11, 10, 0, 0, 0, loc)))
+	if week.Start.Weekday() != time.Monday || week.Start.Format("2006-01-02") != "2024-03-11" ||
+		week.End.Format("2006-01-02") != "2024-03-18" || week.Start.Location().String() != loc.String() {
+		t.Fatalf("Expected the week of 2024-03-11 in Los Angeles, got %v to %v", week.Start, week.End)
+	}
+
+	tests := []struct {
+		date time.Time
+		in   bool
+	}{
+		{time.Date(2024, 3, 11, 2, 0, 0, 0, loc), false},       // before rollover, still Sunday
+		{time.Date(2024, 3, 11, 7, 0, 0, 0, time.UTC), false},  // midnight in Los Angeles, still Sunday
+		{time.Date(2024, 3, 11, 4, 0, 0, 0, loc), true},        // Monday from the rollover on
+		{time.Date(2024, 3, 18, 9, 0, 0, 0, time.UTC), true},   // 2am Monday after, still Sunday
+		{time.Date(2024, 3, 18, 12, 0, 0, 0, time.UTC), false}, // 5am Monday after
+	}
+	for _, tt := range tests {
+		if got := IsInDateRange(tt.date, week); got != tt.in {
+			t.Errorf("IsInDateRange(%v) = %v, want %v (day %s)", tt.date, got, tt.in, DayKey(tt.date))
+		}
+	}
+
+	// Recent counts bucket commits the way streak days do
+	today := DayOf(time.Now())
+	dates := []time.Time{
+		today.Add(2 * time.Hour),                   // before rollover, yesterday
+		today.Add(5 * time.Hour),                   // today
+		today.AddDate(0, 0, -7).Add(5 * time.Hour), // first day of the last 7
+		today.AddDate(0, 0, -7).Add(2 * time.Hour), // before rollover, 8 days ago
+	}
+	if got := countRecentCommits(dates, 0); got != 1 {
+		t.Errorf("Expected 1 commit today, got %d", got)
+	}
+	if got := countRecentCommits(dates, 7); got != 3 {
+		t.Errorf("Expected 3 commits in the last 7 days, got %d", got)
+	}
+}
+
+func TestStreakPolicy(t *testing.T) {
+	today, _ := parseDayKey(Today())
+	noon := func(daysAgo int) time.Time {
+		day := today.AddDate(0, 0, -daysAgo)
+		return time.Date(day.Year(), day.Month(), day.Day(), 12, 0, 0, 0, Location())
+	}
+	activity := func(lines int, daysAgo ...int) []Activity {
+		var a []Activity
+		for _, d := range daysAgo {
+			a = append(a, Activity{Date: noon(d), Lines: lines})
+		}
+		return a
+	}
+
+	// Days 0-2 and 4-6 active, day 3 missed
+	commits := activity(10, 0, 1, 2, 4, 5, 6)
+
+	plain := StreakPolicy{MinCommits: 1}
+	if info := plain.Calculate(commits); info.Current != 3 || info.Longest != 3 {
+		t.Errorf("Expected current 3 and longest 3, got %d and %d", info.Current, info.Longest)
+	}
+
+	rest := StreakPolicy{MinCommits: 1, RestDays: map[string]bool{DayKey(noon(3)): true}}
+	if info := rest.Calculate(commits); info.Current != 6 {
+		t.Errorf("Expected rest day to keep the streak at 6, got %d", info.Current)
+	}
+
+	// Three active days earn a freeze that covers the missed day
+	freeze := StreakPolicy{MinCommits: 1, FreezeEvery: 3, MaxFreezes: 1}
+	info := freeze.Calculate(commits)
+	if info.Current != 6 || len(info.FrozenDays) != 1 || info.FrozenDays[0] != DayKey(noon(3)) {
+		t.Errorf("Expected freeze on %s keeping a 6 day streak, got %d with %v",
+			DayKey(noon(3)), info.Current, info.FrozenDays)
+	}
+
+	// Days below the line threshold do not count
+	small := append(activity(10, 0, 1), activity(2, 2)...)
+	lines := StreakPolicy{MinCommits: 1, MinLines: 5}
+	if info := lines.Calculate(small); info.Current != 2 {
+		t.Errorf("Expected line threshold to end the streak at 2, got %d", info.Current)
+	}
+
+	// Missing today does not break a streak, missing yesterday does
+	if info := plain.Calculate(activity(10, 1, 2)); info.Current != 2 {
+		t.Errorf("Expected streak through yesterday of 2, got %d", info.Current)
+	}
+	if info := plain.Calculate(activity(10, 2, 3)); info.Current != 0 || info.Longest != 2 {
+		t.Errorf("Expected broken streak with longest 2, got %d and %d", info.Current, info.Longest)
+	}
+
+	// Weekends off: a Friday commit carries over to Monday
+	monday := today
+	for monday.Weekday() != time.Monday {
+		monday = monday.AddDate(0, 0, -1)
+	}
+	daysAgo := int(today.Sub(monday).Hours() / 24)
+	weekends := StreakPolicy{MinCommits: 1, WeekendsOff: true}
+	if info := weekends.Calculate(activity(10, daysAgo, daysAgo+3)); info.Longest != 2 {
+		t.Errorf("Expected Friday and Monday to form a streak of 2, got %d", info.Longest)
+	}
+}
+
 func TestLanguageStats(t *testing.T) {
 	repoPath, cleanup := setupTestRepo(t)
 	defer cleanup()
 
 	// Create test files with different extensions
 	files := map[string]string{
-		"main.go":    "package main\n\nfunc main() {\n\tfmt.Println(\"Hello\")\n}\n",
-		"test.py":    "def test():\n    print('test')\n",
-		"README.md":  "# Test Repo\n\nThis is a test\n",
-		"style.css":  "body {\n    margin: 0;\n}\n",
-		"index.html": "<!DOCTYPE html>\n<html>\n<body>\n</body>\n</html>\n",
+		"main.go":             "package main\n\nfunc main() {\n\tfmt.Println(\"Hello\")\n}\n",
+		"test.py":             "def test():\n    print('test')\n",
+		"README.md":           "# Test Repo\n\nThis is a test\n",
+		"style.css":           "body {\n    margin: 0;\n}\n",
+		"index.html":          "<!DOCTYPE html>\n<html>\n<body>\n</body>\n</html>\n",
+		"Makefile":            "all:\n\tgo build\n",
+		"Dockerfile":          "FROM golang\nRUN go build\n",
+		"bin/deploy":          "#!/usr/bin/env bash\necho deploy\n",
+		"notes.txt":           "just text\n",
+		"vendor/lib/lib.go":   "package lib\n",
+		"api/api.pb.go":       "package api\n",
+		"gen/types.go":        "// Code generated by stringer. DO NOT EDIT.\n\npackage gen\n",
+		"static/app.min.js":   "var a=1;" + strings.Repeat("a", 200) + "\n",
+		"docs/example.go":     "package docs\n",
+		"templates/page.tmpl": "<html></html>\n",
+		".gitattributes":      "docs/** linguist-documentation\n*.tmpl linguist-language=html\n",
 	}
 
 	for name, content := range files {
 		path := filepath.Join(repoPath, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
 		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
 			t.Fatalf("Failed to write file %s: %v", name, err)
 		}
@@ -209,29 +384,77 @@ func TestLanguageStats(t *testing.T) {
 		}
 	}
 
-	// Configure excluded extensions for the test
+	// Configure excluded extensions and languages for the test
+	originalSettings := config.AppConfig.LanguageSettings
+	defer func() { config.AppConfig.LanguageSettings = originalSettings }()
 	config.AppConfig.LanguageSettings.ExcludedExtensions = []string{".md"}
+	config.AppConfig.LanguageSettings.ExcludedLanguages = []string{"text"}
 	config.AppConfig.LanguageSettings.MinimumLines = 1
 
 	meta := FetchRepoMetadata(repoPath)
 
 	// Verify language statistics
-	expectedExtensions := []string{".go", ".py", ".css", ".html"}
-	for _, ext := range expectedExtensions {
-		if _, ok := meta.Languages[ext]; !ok {
-			t.Errorf("Expected to find %s in language stats", ext)
+	expectedLanguages := map[string]int{
+		"Go":         5, // main.go only: vendored, generated and documentation files are skipped
+		"Python":     2,
+		"CSS":        3,
+		"HTML":       6, // index.html and page.tmpl via linguist-language
+		"Makefile":   2,
+		"Dockerfile": 2,
+		"Shell":      2,
+	}
+	for language, lines := range expectedLanguages {
+		if got, ok := meta.Languages[language]; !ok || got != lines {
+			t.Errorf("Expected %d lines of %s, got %d", lines, language, got)
 		}
 	}
 
-	// Verify excluded extensions
-	if lines, ok := meta.Languages[".md"]; ok {
-		t.Errorf("Markdown files should be excluded from language stats, but found %d lines", lines)
+	// Verify excluded extensions and languages
+	for _, excluded := range []string{"Markdown", "Text", "JavaScript", ".go"} {
+		if lines, ok := meta.Languages[excluded]; ok {
+			t.Errorf("%s should be excluded from language stats, but found %d lines", excluded, lines)
+		}
 	}
 
 	// Log all found languages for debugging
 	t.Log("Found languages:")
-	for ext, lines := range meta.Languages {
-		t.Logf("- %s: %d lines", ext, lines)
+	for language, lines := range meta.Languages {
+		t.Logf("- %s: %d lines", language, lines)
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		file    string
+		content string
+		want    string
+	}{
+		{"cmd/main.go", "", "Go"},
+		{"Makefile", "", "Makefile"},
+		{"build/Dockerfile.dev", "", "Dockerfile"},
+		{"scripts/run", "#!/bin/sh\n", "Shell"},
+		{"scripts/tool", "#!/usr/bin/env -S python3.12 -u\n", "Python"},
+		{"scripts/serve", "#!/usr/bin/env node\n", "JavaScript"},
+		{"include/list.h", "struct list { int n; };\n", "C"},
+		{"include/list.h", "namespace util {\nclass List {};\n}\n", "C++"},
+		{"include/View.h", "@interface View : NSObject\n@end\n", "Objective-C"},
+		{"LICENSE", "", "Text"},
+		{"data.bin", "", ""},
+	}
+	for _, tt := range tests {
+		if got := DetectLanguage(tt.file, []byte(tt.content)); got != tt.want {
+			t.Errorf("DetectLanguage(%q) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+
+	if !IsVendored("node_modules/react/index.js") || IsVendored("src/vendors.go") {
+		t.Error("Expected only node_modules to be vendored")
+	}
+	if !IsGenerated("api/service.pb.go", nil) || !IsGenerated("package-lock.json", nil) {
+		t.Error("Expected protobuf output and lockfiles to be generated")
+	}
+	if IsGenerated("main.go", []byte("package main\n")) {
+		t.Error("Expected hand-written file not to be generated")
 	}
 }
 
@@ -333,7 +556,7 @@ func TestAuthorFiltering(t *testing.T) {
 	t.Logf("Git log output:\n%s", string(output))
 
 	// Test with author filter
-	meta := fetchRepoMeta(repoPath, "Test User")
+	meta := fetchRepoMeta(context.Background(), repoPath, "Test User")
 
 	// Should only count commits from Test User
 	expectedCount := 2
@@ -346,7 +569,7 @@ func TestAuthorFiltering(t *testing.T) {
 	}
 
 	// Test with different author
-	meta = fetchRepoMeta(repoPath, "Other User")
+	meta = fetchRepoMeta(context.Background(), repoPath, "Other User")
 	expectedCount = 1
 	if meta.CommitCount != expectedCount {
 		t.Errorf("Expected %d commit from Other User, got %d", expectedCount, meta.CommitCount)
@@ -356,3 +579,1367 @@ func TestAuthorFiltering(t *testing.T) {
 		}
 	}
 }
+
+func TestReadCommitsNativeMatchesGit(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	now := time.Now().UTC()
+	createTestCommit(t, repoPath, now.AddDate(0, 0, -2), "first commit")
+	createTestCommit(t, repoPath, now.AddDate(0, 0, -1), "feat: subject | with a pipe")
+	createTestCommit(t, repoPath, now, "fix(api)!: latest commit\n\nBody text.\n\nCo-authored-by: Pat <pat@example.com>")
+
+	native, err := readCommitsNative(context.Background(), repoPath, logOptions{NumStat: true})
+	if err != nil {
+		t.Fatalf("Native reader failed: %v", err)
+	}
+	fallback, err := readCommitsGit(context.Background(), repoPath, logOptions{NumStat: true})
+	if err != nil {
+		t.Fatalf("Git reader failed: %v", err)
+	}
+
+	if len(native) != 3 || len(fallback) != len(native) {
+		t.Fatalf("Expected 3 commits from both readers, got %d native and %d git", len(native), len(fallback))
+	}
+	for i := range native {
+		n, g := native[i], fallback[i]
+		if n.Hash != g.Hash || n.Subject != g.Subject || !n.Date.Equal(g.Date) {
+			t.Errorf("Commit %d differs: native %+v, git %+v", i, n, g)
+		}
+		if n.FileCount != g.FileCount || n.Additions != g.Additions || n.Deletions != g.Deletions {
+			t.Errorf("Stats for %s differ: native %d/+%d/-%d, git %d/+%d/-%d", n.Hash,
+				n.FileCount, n.Additions, n.Deletions, g.FileCount, g.Additions, g.Deletions)
+		}
+		if nh, gh := n.toHistory(), g.toHistory(); nh.Type != gh.Type || nh.Scope != gh.Scope ||
+			nh.Breaking != gh.Breaking || fmt.Sprint(nh.Trailers) != fmt.Sprint(gh.Trailers) {
+			t.Errorf("Parsed messages for %s differ: native %+v, git %+v", n.Hash, nh, gh)
+		}
+	}
+
+	latest := native[0].toHistory()
+	if latest.Type != "fix" || latest.Scope != "api" || !latest.Breaking ||
+		len(latest.Trailers["Co-authored-by"]) != 1 {
+		t.Errorf("Expected a breaking api fix with a co-author, got %+v", latest)
+	}
+
+	if native[1].Subject != "feat: subject | with a pipe" {
+		t.Errorf("Expected subject with pipe to survive, got %q", native[1].Subject)
+	}
+}
+
+func TestReadRefTipsGit(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	now := time.Now().UTC()
+	createTestCommit(t, repoPath, now.AddDate(0, 0, -1), "first commit")
+	for _, args := range [][]string{
+		{"git", "tag", "-a", "v1.0", "-m", "release"},
+		{"git", "branch", "feature"},
+	} {
+		command := exec.Command(args[0], args[1:]...)
+		command.Dir = repoPath
+		if output, err := command.CombinedOutput(); err != nil {
+			t.Fatalf("Failed to run %v: %v\n%s", args, err, output)
+		}
+	}
+	createTestCommit(t, repoPath, now, "second commit")
+
+	tips, err := readRefTipsGit(context.Background(), repoPath)
+	if err != nil {
+		t.Fatalf("Git ref listing failed: %v", err)
+	}
+
+	gitOutput := func(args ...string) string {
+		output, err := exec.Command("git", append([]string{"-C", repoPath}, args...)...).Output()
+		if err != nil {
+			t.Fatalf("Failed to run git %v: %v", args, err)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	want := map[string]string{
+		"HEAD":                            gitOutput("rev-parse", "HEAD"),
+		gitOutput("symbolic-ref", "HEAD"): gitOutput("rev-parse", "HEAD"),
+		"refs/heads/feature":              gitOutput("rev-parse", "feature"),
+		"refs/tags/v1.0":                  gitOutput("rev-parse", "v1.0^{commit}"),
+	}
+	if !reflect.DeepEqual(tips, want) {
+		t.Errorf("Expected tips %v, got %v", want, tips)
+	}
+
+	// Both readers agree
+	native, err := readRefTipsNative(repoPath)
+	if err != nil {
+		t.Fatalf("Native ref listing failed: %v", err)
+	}
+	if !reflect.DeepEqual(native, tips) {
+		t.Errorf("Native tips %v differ from git tips %v", native, tips)
+	}
+}
+
+func TestParseCommitMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		expected CommitMessage
+	}{
+		{"plain", "Update readme", CommitMessage{}},
+		{"type only", "feat: add streaks", CommitMessage{Type: "feat"}},
+		{"scope", "Fix(parser): handle tabs\n", CommitMessage{Type: "fix", Scope: "parser"}},
+		{"breaking marker", "refactor(api)!: drop v1", CommitMessage{Type: "refactor", Scope: "api", Breaking: true}},
+		{"no space after colon", "feat:add", CommitMessage{}},
+		{"merge", "Merge branch 'feat: x'", CommitMessage{}},
+		{
+			"trailers",
+			"chore: bump deps\n\nRoutine update.\n\nSigned-off-by: A <a@example.com>\nco-authored-by: B <b@example.com>\n",
+			CommitMessage{Type: "chore", Trailers: map[string][]string{
+				"Signed-off-by":  {"A <a@example.com>"},
+				"Co-authored-by": {"B <b@example.com>"},
+			}},
+		},
+		{
+			"breaking trailer",
+			"feat: new config\n\nBREAKING CHANGE: the old keys are gone\n  and not migrated",
+			CommitMessage{Type: "feat", Breaking: true, Trailers: map[string][]string{
+				"BREAKING CHANGE": {"the old keys are gone and not migrated"},
+			}},
+		},
+		{"prose paragraph", "docs: explain\n\nSee: the wiki for details.\nIt has more.", CommitMessage{Type: "docs"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseCommitMessage(tt.message); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTypeTrend(t *testing.T) {
+	config.AppConfig.Timezone = "UTC"
+	config.AppConfig.DayRolloverHour = 0
+	defer func() { config.AppConfig.Timezone = "" }()
+
+	now := time.Now().UTC()
+	history := []CommitHistory{
+		{Date: now, Type: "feat"},
+		{Date: now.AddDate(0, 0, -1), Type: "fix"},
+		{Date: now.AddDate(0, 0, -2), Type: "fix"},
+		{Date: now.AddDate(0, 0, -8), Type: "feat"},
+		{Date: now.AddDate(0, 0, -9), Type: ""},
+		{Date: now.AddDate(0, 0, -30), Type: "fix"}, // outside two weeks
+	}
+
+	periods := TypeTrend(history, 2)
+	if len(periods) != 2 {
+		t.Fatalf("Expected 2 periods, got %d", len(periods))
+	}
+	if ratio, ok := periods[1].FixFeatRatio(); !ok || ratio != 2 {
+		t.Errorf("Expected a ratio of 2 this week, got %v (%v)", ratio, ok)
+	}
+	if ratio, ok := periods[0].FixFeatRatio(); !ok || ratio != 0 {
+		t.Errorf("Expected a ratio of 0 last week, got %v (%v)", ratio, ok)
+	}
+	if periods[0].Counts[""] != 1 {
+		t.Errorf("Expected one non-conventional commit last week, got %v", periods[0].Counts)
+	}
+
+	types := SortedTypes(CommitTypes(history, time.Time{}))
+	if strings.Join(types, ",") != "fix,feat," {
+		t.Errorf("Expected types sorted as fix, feat, other, got %q", types)
+	}
+}
+
+func TestLanguagesWritten(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	commit := func(author string, files map[string]string, message string) {
+		for name, content := range files {
+			path := filepath.Join(repoPath, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatalf("Failed to create directory for %s: %v", name, err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write file %s: %v", name, err)
+			}
+		}
+		for _, args := range [][]string{{"add", "."}, {"commit", "-q", "--author", author, "-m", message}} {
+			command := exec.Command("git", args...)
+			command.Dir = repoPath
+			if err := command.Run(); err != nil {
+				t.Fatalf("Failed to run git %v: %v", args, err)
+			}
+		}
+	}
+
+	commit("Teammate <mate@example.com>", map[string]string{
+		"src/Big.java": strings.Repeat("class Big {}\n", 500),
+	}, "teammate's java")
+	commit("Test User <test@example.com>", map[string]string{
+		"main.go":        "package main\n\nfunc main() {}\n",
+		"src/Big.java":   strings.Repeat("class Big {}\n", 499) + "class Bigger {}\n",
+		"vendor/x/x.go":  "package x\n",
+		"scripts/tool":   "#!/bin/sh\n",
+		"docs/README.md": "# docs\n",
+	}, "my change")
+
+	originalSettings := config.AppConfig.LanguageSettings
+	defer func() { config.AppConfig.LanguageSettings = originalSettings }()
+	config.AppConfig.LanguageSettings.ExcludedExtensions = nil
+	config.AppConfig.LanguageSettings.ExcludedLanguages = []string{"Markdown"}
+
+	history, err := fetchDetailedCommitInfo(context.Background(), repoPath, "test@example.com", time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("Expected only my commit, got %d", len(history))
+	}
+
+	// Only the lines I changed count: one Java line replaced, three Go lines
+	// added; vendored, excluded and unrecognized files are left out
+	written := LanguagesWritten(history, time.Time{})
+	if len(written) != 2 || written["Go"] != 3 || written["Java"] != 2 {
+		t.Errorf("Expected Go 3 and Java 2 changed lines, got %v", written)
+	}
+
+	for path, want := range map[string]string{
+		"a/b.go":                "a/b.go",
+		"old.go => new.go":      "new.go",
+		"src/{old => new}/a.go": "src/new/a.go",
+		"src/{ => new}/a.go":    "src/new/a.go",
+	} {
+		if got := numstatPath(path); got != want {
+			t.Errorf("numstatPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestScanDirectoriesDeterministic(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"charlie", "alpha", "bravo", "delta", "excluded"} {
+		repoPath := filepath.Join(root, name)
+		if err := os.MkdirAll(repoPath, 0755); err != nil {
+			t.Fatalf("Failed to create repo dir: %v", err)
+		}
+		for _, cmd := range [][]string{
+			{"git", "init", "-q"},
+			{"git", "config", "user.name", "Test User"},
+			{"git", "config", "user.email", "test@example.com"},
+		} {
+			command := exec.Command(cmd[0], cmd[1:]...)
+			command.Dir = repoPath
+			if err := command.Run(); err != nil {
+				t.Fatalf("Failed to run %v: %v", cmd, err)
+			}
+		}
+		createTestCommit(t, repoPath, time.Now().UTC(), "commit in "+name)
+	}
+
+	exclusions := NewExclusions([]string{root}, []string{"excluded"}, nil)
+
+	originalConcurrency := config.AppConfig.ScanSettings.Concurrency
+	originalThreshold := config.AppConfig.DormantThreshold
+	defer func() {
+		config.AppConfig.ScanSettings.Concurrency = originalConcurrency
+		config.AppConfig.DormantThreshold = originalThreshold
+	}()
+	config.AppConfig.DormantThreshold = 30
+
+	var previous []string
+	for _, workers := range []int{1, 4} {
+		config.AppConfig.ScanSettings.Concurrency = workers
+
+		var last ScanProgress
+		calls := 0
+		repos, _, err := ScanDirectories(context.Background(), []string{root}, "Test User", exclusions, nil, func(p ScanProgress) {
+			calls++
+			last = p
+		})
+		if err != nil {
+			t.Fatalf("ScanDirectories failed: %v", err)
+		}
+
+		var paths []string
+		for _, repo := range repos {
+			paths = append(paths, filepath.Base(repo.Path))
+		}
+		expected := []string{"alpha", "bravo", "charlie", "delta"}
+		if strings.Join(paths, ",") != strings.Join(expected, ",") {
+			t.Errorf("With %d workers expected repos %v, got %v", workers, expected, paths)
+		}
+		if previous != nil && strings.Join(previous, ",") != strings.Join(paths, ",") {
+			t.Errorf("Results differ between worker counts: %v vs %v", previous, paths)
+		}
+		previous = paths
+
+		if !last.Done || last.Found != 5 || last.Scanned != 4 || last.Skipped != 1 {
+			t.Errorf("Unexpected final progress with %d workers: %+v after %d calls", workers, last, calls)
+		}
+	}
+}
+
+func TestDiscoverRepositories(t *testing.T) {
+	root := t.TempDir()
+	git := func(dir string, args ...string) {
+		args = append([]string{"-c", "user.name=Test User", "-c", "user.email=test@example.com",
+			"-c", "protocol.file.allow=always"}, args...)
+		command := exec.Command("git", args...)
+		command.Dir = dir
+		if output, err := command.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+	newRepo := func(path string) {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", path, err)
+		}
+		git(path, "init", "-q")
+		git(path, "commit", "-q", "--allow-empty", "-m", "initial")
+	}
+
+	newRepo(filepath.Join(root, "alpha"))
+	git(filepath.Join(root, "alpha"), "worktree", "add", "-q", filepath.Join(root, "alpha-wt"))
+	git(root, "clone", "-q", "--bare", filepath.Join(root, "alpha"), filepath.Join(root, "bare.git"))
+	newRepo(filepath.Join(root, "deep", "a", "b", "nested"))
+	newRepo(filepath.Join(root, "library"))
+	newRepo(filepath.Join(root, "parent"))
+	git(filepath.Join(root, "parent"), "submodule", "add", "-q", filepath.Join(root, "library"), "sub")
+	if err := os.Symlink(root, filepath.Join(root, "loop")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	discover := func(opts DiscoverOptions) map[string]DiscoveredRepo {
+		repos := make(map[string]DiscoveredRepo)
+		DiscoverRepositories([]string{root}, opts, func(repo DiscoveredRepo) {
+			rel, _ := filepath.Rel(root, repo.Path)
+			repos[rel] = repo
+		}, func(path string, err error) {
+			t.Errorf("Unexpected skipped directory %s: %v", path, err)
+		})
+		return repos
+	}
+
+	repos := discover(DiscoverOptions{})
+	want := map[string]RepoKind{
+		"alpha":    RepoStandard,
+		"alpha-wt": RepoWorktree,
+		"bare.git": RepoBare,
+		filepath.Join("deep", "a", "b", "nested"): RepoStandard,
+		"library": RepoStandard,
+		"parent":  RepoStandard,
+	}
+	if len(repos) != len(want) {
+		t.Errorf("Expected %d repositories, got %v", len(want), repos)
+	}
+	for path, kind := range want {
+		if repo, ok := repos[path]; !ok || repo.Kind != kind {
+			t.Errorf("Expected %s to be found as %s, got %+v", path, kind, repo)
+		}
+	}
+	if repos["alpha-wt"].Duplicate != filepath.Join(root, "alpha") {
+		t.Errorf("Expected worktree to be a duplicate of alpha, got %q", repos["alpha-wt"].Duplicate)
+	}
+
+	// Submodules are only found when searching inside repositories
+	withSubmodules := discover(DiscoverOptions{Submodules: true})
+	if repo := withSubmodules[filepath.Join("parent", "sub")]; repo.Kind != RepoSubmodule {
+		t.Errorf("Expected parent/sub to be found as a submodule, got %+v", repo)
+	}
+
+	shallow := discover(DiscoverOptions{MaxDepth: 2})
+	if _, ok := shallow[filepath.Join("deep", "a", "b", "nested")]; ok {
+		t.Error("Expected max depth to stop before the nested repository")
+	}
+}
+
+func TestIncrementalRefresh(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	originalThreshold := config.AppConfig.DormantThreshold
+	originalDetailed := config.AppConfig.DetailedStats
+	defer func() {
+		config.AppConfig.DormantThreshold = originalThreshold
+		config.AppConfig.DetailedStats = originalDetailed
+	}()
+	config.AppConfig.DormantThreshold = 30
+	config.AppConfig.DetailedStats = true
+
+	now := time.Now().UTC()
+	createTestCommit(t, repoPath, now.AddDate(0, 0, -2), "first")
+	createTestCommit(t, repoPath, now.AddDate(0, 0, -1), "second")
+
+	meta := fetchRepoMeta(context.Background(), repoPath, "Test User")
+	if meta.CommitCount != 2 || len(meta.RefTips) == 0 {
+		t.Fatalf("Expected 2 commits with recorded ref tips, got %d commits and %v", meta.CommitCount, meta.RefTips)
+	}
+
+	// Unchanged refs keep the same data
+	unchanged := UpdateRepoMeta(context.Background(), meta, "Test User")
+	if unchanged.CommitCount != 2 || len(unchanged.CommitHistory) != 2 {
+		t.Errorf("Expected 2 commits after no-op refresh, got %d (%d in history)",
+			unchanged.CommitCount, len(unchanged.CommitHistory))
+	}
+
+	// New commits are appended
+	createTestCommit(t, repoPath, now, "third")
+	updated := UpdateRepoMeta(context.Background(), meta, "Test User")
+	if updated.CommitCount != 3 || len(updated.CommitHistory) != 3 {
+		t.Errorf("Expected 3 commits after incremental refresh, got %d (%d in history)",
+			updated.CommitCount, len(updated.CommitHistory))
+	}
+	if updated.CommitHistory[0].MessageHead != "third" {
+		t.Errorf("Expected newest commit first, got %q", updated.CommitHistory[0].MessageHead)
+	}
+	if updated.CurrentStreak != 3 {
+		t.Errorf("Expected current streak of 3, got %d", updated.CurrentStreak)
+	}
+
+	// Rewriting history forces a full rescan
+	command := exec.Command("git", "reset", "--hard", "-q", "HEAD~2")
+	command.Dir = repoPath
+	if err := command.Run(); err != nil {
+		t.Fatalf("Failed to reset: %v", err)
+	}
+	createTestCommit(t, repoPath, now, "rewritten")
+	rewritten := UpdateRepoMeta(context.Background(), updated, "Test User")
+	full := fetchRepoMeta(context.Background(), repoPath, "Test User")
+	if rewritten.CommitCount != full.CommitCount || len(rewritten.CommitHistory) != len(full.CommitHistory) {
+		t.Errorf("Expected rewritten history to match a full scan (%d commits), got %d",
+			full.CommitCount, rewritten.CommitCount)
+	}
+	if rewritten.CommitCount != 2 {
+		t.Errorf("Expected 2 commits after rewrite, got %d", rewritten.CommitCount)
+	}
+}
+
+func TestHistoryWindow(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	originalThreshold := config.AppConfig.DormantThreshold
+	originalDetailed := config.AppConfig.DetailedStats
+	originalDays := config.AppConfig.HistoryDays
+	defer func() {
+		config.AppConfig.DormantThreshold = originalThreshold
+		config.AppConfig.DetailedStats = originalDetailed
+		config.AppConfig.HistoryDays = originalDays
+	}()
+	config.AppConfig.DormantThreshold = 30
+	config.AppConfig.DetailedStats = true
+	config.AppConfig.HistoryDays = 30
+
+	now := time.Now().UTC()
+	createTestCommit(t, repoPath, now.AddDate(-1, 0, 0), "last year")
+	createTestCommit(t, repoPath, now.AddDate(0, 0, -60), "two months ago")
+	createTestCommit(t, repoPath, now.AddDate(0, 0, -1), "yesterday")
+
+	meta := fetchRepoMeta(context.Background(), repoPath, "Test User")
+	if len(meta.CommitHistory) != 1 || meta.HistoryAll {
+		t.Fatalf("Expected 1 commit in a 30 day window, got %d", len(meta.CommitHistory))
+	}
+
+	// Growing the window reads the older commits without a full rescan
+	config.AppConfig.HistoryDays = 90
+	extended := UpdateRepoMeta(context.Background(), meta, "Test User")
+	if len(extended.CommitHistory) != 2 {
+		t.Errorf("Expected 2 commits in a 90 day window, got %d", len(extended.CommitHistory))
+	}
+
+	config.AppConfig.HistoryDays = -1
+	all := UpdateRepoMeta(context.Background(), extended, "Test User")
+	if len(all.CommitHistory) != 3 || !all.HistoryAll {
+		t.Errorf("Expected all 3 commits in history, got %d", len(all.CommitHistory))
+	}
+	if all.CommitHistory[2].MessageHead != "last year" {
+		t.Errorf("Expected oldest commit last, got %q", all.CommitHistory[2].MessageHead)
+	}
+
+	// New commits are appended to all-time history
+	createTestCommit(t, repoPath, now, "today")
+	updated := UpdateRepoMeta(context.Background(), all, "Test User")
+	if len(updated.CommitHistory) != 4 {
+		t.Errorf("Expected 4 commits after refresh, got %d", len(updated.CommitHistory))
+	}
+
+	// Shrinking the window drops older commits
+	config.AppConfig.HistoryDays = 30
+	shrunk := UpdateRepoMeta(context.Background(), updated, "Test User")
+	if len(shrunk.CommitHistory) != 2 || shrunk.HistoryAll {
+		t.Errorf("Expected 2 commits after shrinking the window, got %d", len(shrunk.CommitHistory))
+	}
+}
+
+func TestIdentitiesAndMailmap(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	originalAuthor := config.AppConfig.Author
+	originalIdentities := config.AppConfig.Identities
+	originalThreshold := config.AppConfig.DormantThreshold
+	defer func() {
+		config.AppConfig.Author = originalAuthor
+		config.AppConfig.Identities = originalIdentities
+		config.AppConfig.DormantThreshold = originalThreshold
+	}()
+	config.AppConfig.DormantThreshold = 30
+
+	now := time.Now().UTC()
+	commits := []struct {
+		author string
+		email  string
+	}{
+		{"Test User", "test@example.com"},
+		{"Test User", "test@work.example.com"},
+		{"T. User", "old@example.com"},
+		{"Other User", "other@example.com"},
+	}
+	for i, c := range commits {
+		filename := filepath.Join(repoPath, fmt.Sprintf("identity_%d.txt", i))
+		if err := os.WriteFile(filename, []byte(c.email), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		date := now.AddDate(0, 0, -i).Format(time.RFC3339)
+		for _, cmd := range [][]string{
+			{"git", "add", "."},
+			{"git", "commit", "-q", "--author", fmt.Sprintf("%s <%s>", c.author, c.email), "-m", "commit " + c.email},
+		} {
+			command := exec.Command(cmd[0], cmd[1:]...)
+			command.Dir = repoPath
+			command.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+			if err := command.Run(); err != nil {
+				t.Fatalf("Failed to run %v: %v", cmd, err)
+			}
+		}
+	}
+
+	// The work email is a configured identity, the old name is mapped by .mailmap
+	config.AppConfig.Identities = []string{"TEST@work.example.com"}
+	mailmap := "Test User <test@example.com> T. User <old@example.com>\n"
+	if err := os.WriteFile(filepath.Join(repoPath, ".mailmap"), []byte(mailmap), 0644); err != nil {
+		t.Fatalf("Failed to write .mailmap: %v", err)
+	}
+
+	entries, err := readCommits(context.Background(), repoPath, logOptions{Authors: authorIdentities("test@example.com")})
+	if err != nil {
+		t.Fatalf("readCommits failed: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 commits across identities, got %d", len(entries))
+	}
+	for _, entry := range entries {
+		history := entry.toHistory()
+		if entry.AuthorEmail == "old@example.com" && history.Identity != "Test User <test@example.com>" {
+			t.Errorf("Expected mailmapped identity, got %q", history.Identity)
+		}
+	}
+
+	fallback, err := readCommitsGit(context.Background(), repoPath, logOptions{Authors: authorIdentities("test@example.com")})
+	if err != nil {
+		t.Fatalf("readCommitsGit failed: %v", err)
+	}
+	if len(fallback) != len(entries) {
+		t.Errorf("Expected git fallback to find %d commits, got %d", len(entries), len(fallback))
+	}
+
+	matcher := NewIdentityMatcher("", []string{"/^Other/"})
+	if !matcher.MatchesIdentity("Other User <other@example.com>") || matcher.MatchesIdentity("Test User <test@example.com>") {
+		t.Errorf("Regex identity matched incorrectly")
+	}
+}
+
+func TestIncrementalRefreshIdentities(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	originalIdentities := config.AppConfig.Identities
+	originalThreshold := config.AppConfig.DormantThreshold
+	originalDetailed := config.AppConfig.DetailedStats
+	defer func() {
+		config.AppConfig.Identities = originalIdentities
+		config.AppConfig.DormantThreshold = originalThreshold
+		config.AppConfig.DetailedStats = originalDetailed
+	}()
+	config.AppConfig.Identities = nil
+	config.AppConfig.DormantThreshold = 30
+	config.AppConfig.DetailedStats = true
+
+	now := time.Now().UTC()
+	for i, email := range []string{"test@example.com", "test@work.example.com"} {
+		filename := filepath.Join(repoPath, fmt.Sprintf("identity_%d.txt", i))
+		if err := os.WriteFile(filename, []byte(email), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		date := now.AddDate(0, 0, -i).Format(time.RFC3339)
+		for _, cmd := range [][]string{
+			{"git", "add", "."},
+			{"git", "commit", "-q", "--author", "Test User <" + email + ">", "-m", "commit " + email},
+		} {
+			command := exec.Command(cmd[0], cmd[1:]...)
+			command.Dir = repoPath
+			command.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+			if err := command.Run(); err != nil {
+				t.Fatalf("Failed to run %v: %v", cmd, err)
+			}
+		}
+	}
+
+	meta := fetchRepoMeta(context.Background(), repoPath, "test@example.com")
+	if meta.CommitCount != 1 {
+		t.Fatalf("Expected 1 commit without identities, got %d", meta.CommitCount)
+	}
+
+	// Adding an identity reads the commits made with it, though no ref moved
+	config.AppConfig.Identities = []string{"test@work.example.com"}
+	added := UpdateRepoMeta(context.Background(), meta, "test@example.com")
+	if added.CommitCount != 2 || len(added.CommitHistory) != 2 {
+		t.Errorf("Expected 2 commits after adding an identity, got %d (%d in history)",
+			added.CommitCount, len(added.CommitHistory))
+	}
+
+	// Removing it drops them again
+	config.AppConfig.Identities = nil
+	removed := UpdateRepoMeta(context.Background(), added, "test@example.com")
+	if removed.CommitCount != 1 || len(removed.CommitHistory) != 1 {
+		t.Errorf("Expected 1 commit after removing the identity, got %d (%d in history)",
+			removed.CommitCount, len(removed.CommitHistory))
+	}
+
+	// So does changing the author
+	other := UpdateRepoMeta(context.Background(), removed, "test@work.example.com")
+	if other.CommitCount != 1 || len(other.CommitHistory) != 1 || other.CommitHistory[0].MessageHead != "commit test@work.example.com" {
+		t.Errorf("Expected only the work commit for the other author, got %d commits", other.CommitCount)
+	}
+}
+
+func TestExclusions(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{
+		"build", "buildkite-agent", "tools/build", "app/tmp/cache", "clients/acme",
+		"clients/keep", "archive/old", "archive/current",
+	} {
+		if err := exec.Command("git", "init", "-q", filepath.Join(root, dir)).Run(); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	writeIgnore := func(dir, content string) {
+		if err := os.WriteFile(filepath.Join(root, dir, IgnoreFile), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write ignore file: %v", err)
+		}
+	}
+	writeIgnore("", "# clients are private\nclients/*\n!clients/keep\n")
+	writeIgnore("archive", "*\n!current/\n")
+
+	exclusions := NewExclusions([]string{root}, []string{"/build", "**/tmp", "node_modules"},
+		[]string{filepath.Join(root, "tools") + "/"})
+
+	tests := []struct {
+		path     string
+		excluded bool
+		source   string
+		via      string
+	}{
+		{"build", true, "excluded_patterns", ""},
+		{"buildkite-agent", false, "", ""},
+		{"tools/build", true, "excluded_paths", "tools"},
+		{"app/tmp/cache", true, "excluded_patterns", "app/tmp"},
+		{"clients/acme", true, filepath.Join(root, IgnoreFile), ""},
+		{"clients/keep", false, filepath.Join(root, IgnoreFile), ""},
+		{"archive/old", true, filepath.Join(root, "archive", IgnoreFile), ""},
+		{"archive/current", false, filepath.Join(root, "archive", IgnoreFile), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			explanation := exclusions.Explain(filepath.Join(root, tt.path))
+			if explanation.Root != root {
+				t.Errorf("Expected root %s, got %q", root, explanation.Root)
+			}
+			if explanation.Excluded != tt.excluded {
+				t.Errorf("Expected excluded=%v, got %+v", tt.excluded, explanation)
+			}
+			source := ""
+			if explanation.Rule != nil {
+				source = explanation.Rule.Source
+			}
+			if source != tt.source {
+				t.Errorf("Expected rule from %q, got %q", tt.source, source)
+			}
+			via := ""
+			if explanation.Via != "" {
+				via, _ = filepath.Rel(root, explanation.Via)
+			}
+			if via != tt.via {
+				t.Errorf("Expected match via %q, got %q", tt.via, via)
+			}
+		})
+	}
+
+	// Discovery honors the same rules, including the ignore files it finds
+	var tracked []string
+	DiscoverRepositories([]string{root}, DiscoverOptions{
+		Exclusions: NewExclusions([]string{root}, []string{"/build", "**/tmp"}, []string{filepath.Join(root, "tools")}),
+	}, func(repo DiscoveredRepo) {
+		if repo.ExcludedBy == nil {
+			rel, _ := filepath.Rel(root, repo.Path)
+			tracked = append(tracked, filepath.ToSlash(rel))
+		}
+	}, func(path string, err error) {
+		t.Errorf("Unexpected skipped directory %s: %v", path, err)
+	})
+	expected := []string{"archive/current", "buildkite-agent", "clients/keep"}
+	if strings.Join(tracked, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected tracked repositories %v, got %v", expected, tracked)
+	}
+}
+
+func TestCoAuthoredCommits(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	originalThreshold := config.AppConfig.DormantThreshold
+	originalDetailed := config.AppConfig.DetailedStats
+	originalCount := config.AppConfig.CoAuthorSettings.CountTowardStreaks
+	defer func() {
+		config.AppConfig.DormantThreshold = originalThreshold
+		config.AppConfig.DetailedStats = originalDetailed
+		config.AppConfig.CoAuthorSettings.CountTowardStreaks = originalCount
+	}()
+	config.AppConfig.DormantThreshold = 30
+	config.AppConfig.DetailedStats = true
+
+	now := time.Now().UTC()
+	createTestCommit(t, repoPath, now.AddDate(0, 0, -2), "solo work")
+	// Yesterday Pat drove and credited Test User
+	date := now.AddDate(0, 0, -1).Format(time.RFC3339)
+	command := exec.Command("git", "commit", "-q", "--allow-empty", "-m",
+		"feat: pair on parser\n\nCo-authored-by: Test User <test@example.com>")
+	command.Dir = repoPath
+	command.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Pat Partner", "GIT_AUTHOR_EMAIL=pat@example.com",
+		"GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+	if output, err := command.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to commit as Pat: %v\n%s", err, output)
+	}
+	createTestCommit(t, repoPath, now, "more solo work")
+
+	config.AppConfig.CoAuthorSettings.CountTowardStreaks = false
+	meta := fetchRepoMeta(context.Background(), repoPath, "Test User")
+	if meta.CommitCount != 2 || meta.CurrentStreak != 1 {
+		t.Errorf("Expected 2 commits and a streak of 1 without co-authored commits, got %d and %d",
+			meta.CommitCount, meta.CurrentStreak)
+	}
+	// The detailed history always credits co-authors
+	if len(meta.CommitHistory) != 3 {
+		t.Fatalf("Expected 3 commits in history, got %d", len(meta.CommitHistory))
+	}
+	paired := meta.CommitHistory[1]
+	if !paired.CoAuthored || paired.Identity != "Pat Partner <pat@example.com>" {
+		t.Errorf("Expected Pat's commit to be marked co-authored, got %+v", paired)
+	}
+
+	// Changing the setting rescans, now counting the paired day
+	config.AppConfig.CoAuthorSettings.CountTowardStreaks = true
+	counted := UpdateRepoMeta(context.Background(), meta, "Test User")
+	if counted.CommitCount != 3 || counted.CurrentStreak != 3 {
+		t.Errorf("Expected 3 commits and a streak of 3 with co-authored commits, got %d and %d",
+			counted.CommitCount, counted.CurrentStreak)
+	}
+
+	matcher := NewIdentityMatcher("Test User", nil)
+	if matcher.MatchesCommit(paired) || !matcher.MatchesCoAuthor(CoAuthors(paired)) {
+		t.Error("Expected Test User to match the paired commit only as a co-author")
+	}
+}
+
+func TestGroupProjects(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	originalThreshold := config.AppConfig.DormantThreshold
+	originalDetailed := config.AppConfig.DetailedStats
+	originalCanonical := config.AppConfig.ProjectSettings.CanonicalPaths
+	defer func() {
+		config.AppConfig.DormantThreshold = originalThreshold
+		config.AppConfig.DetailedStats = originalDetailed
+		config.AppConfig.ProjectSettings.CanonicalPaths = originalCanonical
+	}()
+	config.AppConfig.DormantThreshold = 30
+	config.AppConfig.DetailedStats = true
+	config.AppConfig.ProjectSettings.CanonicalPaths = nil
+
+	now := time.Now().UTC()
+	createTestCommit(t, repoPath, now.AddDate(0, 0, -2), "first")
+	createTestCommit(t, repoPath, now.AddDate(0, 0, -1), "second")
+
+	// A fork adds a commit of its own on top of the shared history
+	forkPath := filepath.Join(t.TempDir(), "fork")
+	if output, err := exec.Command("git", "clone", "-q", repoPath, forkPath).CombinedOutput(); err != nil {
+		t.Fatalf("Failed to clone: %v\n%s", err, output)
+	}
+	for _, cmd := range [][]string{
+		{"git", "config", "user.name", "Test User"},
+		{"git", "config", "user.email", "test@example.com"},
+	} {
+		command := exec.Command(cmd[0], cmd[1:]...)
+		command.Dir = forkPath
+		if err := command.Run(); err != nil {
+			t.Fatalf("Failed to configure fork: %v", err)
+		}
+	}
+	createTestCommit(t, forkPath, now, "fork only")
+
+	unrelated, cleanupUnrelated := setupTestRepo(t)
+	defer cleanupUnrelated()
+	createTestCommit(t, unrelated, now, "elsewhere")
+
+	repos := map[string]RepoMetadata{}
+	for _, path := range []string{repoPath, forkPath, unrelated} {
+		repos[path] = fetchRepoMeta(context.Background(), path, "Test User")
+	}
+	if repos[repoPath].RootCommit == "" || repos[repoPath].RootCommit != repos[forkPath].RootCommit {
+		t.Fatalf("Expected clones to share a root commit, got %q and %q",
+			repos[repoPath].RootCommit, repos[forkPath].RootCommit)
+	}
+
+	projects := GroupProjects(repos)
+	if len(projects) != 2 {
+		t.Fatalf("Expected 2 projects, got %d", len(projects))
+	}
+	var project Project
+	for _, p := range projects {
+		if len(p.Clones) == 2 {
+			project = p
+		}
+	}
+	// The fork has the most recent commit, so it is canonical by default
+	if project.Path != forkPath {
+		t.Errorf("Expected the most recently active clone %s to be canonical, got %s", forkPath, project.Path)
+	}
+	if project.Meta.CommitCount != 3 || len(project.Meta.CommitHistory) != 3 {
+		t.Errorf("Expected 3 unique commits, got %d dates and %d in history",
+			project.Meta.CommitCount, len(project.Meta.CommitHistory))
+	}
+	if project.Meta.CurrentStreak != 3 {
+		t.Errorf("Expected a merged streak of 3, got %d", project.Meta.CurrentStreak)
+	}
+
+	// A configured canonical path wins over recent activity
+	config.AppConfig.ProjectSettings.CanonicalPaths = []string{"/nonexistent/", repoPath}
+	for _, p := range GroupProjects(repos) {
+		if len(p.Clones) == 2 && (p.Path != repoPath || p.Clones[1] != forkPath) {
+			t.Errorf("Expected %s to be canonical, got %v", repoPath, p.Clones)
+		}
+	}
+}
+
+func TestPatchIDDedup(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	originalThreshold := config.AppConfig.DormantThreshold
+	originalDetailed := config.AppConfig.DetailedStats
+	originalDedup := config.AppConfig.ScanSettings.DedupPatchIDs
+	defer func() {
+		config.AppConfig.DormantThreshold = originalThreshold
+		config.AppConfig.DetailedStats = originalDetailed
+		config.AppConfig.ScanSettings.DedupPatchIDs = originalDedup
+	}()
+	config.AppConfig.DormantThreshold = 30
+	config.AppConfig.DetailedStats = true
+
+	git := func(date time.Time, args ...string) {
+		command := exec.Command("git", args...)
+		command.Dir = repoPath
+		command.Env = append(os.Environ(),
+			"GIT_AUTHOR_DATE="+date.Format(time.RFC3339), "GIT_COMMITTER_DATE="+date.Format(time.RFC3339))
+		if output, err := command.CombinedOutput(); err != nil {
+			t.Fatalf("Failed to run git %v: %v\n%s", args, err, output)
+		}
+	}
+	// backport copies the change at the tip of feature onto the current branch
+	backport := func(date time.Time) {
+		git(date, "cherry-pick", "--no-commit", "feature")
+		git(date, "commit", "-q", "-m", "backport", "--date", date.Format(time.RFC3339))
+	}
+
+	now := time.Now().UTC()
+	createTestCommit(t, repoPath, now.AddDate(0, 0, -5), "base")
+	git(now, "checkout", "-q", "-b", "feature")
+	featureDate := now.AddDate(0, 0, -4)
+	createTestCommit(t, repoPath, featureDate, "feature work")
+	git(now, "checkout", "-q", "-")
+	createTestCommit(t, repoPath, now.AddDate(0, 0, -2), "main work")
+	backport(now.AddDate(0, 0, -1))
+
+	config.AppConfig.ScanSettings.DedupPatchIDs = false
+	meta := fetchRepoMeta(context.Background(), repoPath, "Test User")
+	if meta.CommitCount != 4 || meta.CollapsedCommits != 0 {
+		t.Errorf("Expected 4 commits without dedup, got %d (%d collapsed)", meta.CommitCount, meta.CollapsedCommits)
+	}
+
+	// Turning dedup on rescans and keeps the earlier feature commit
+	config.AppConfig.ScanSettings.DedupPatchIDs = true
+	deduped := UpdateRepoMeta(context.Background(), meta, "Test User")
+	if deduped.CommitCount != 3 || deduped.CollapsedCommits != 1 || len(deduped.CommitHistory) != 3 {
+		t.Fatalf("Expected 3 commits with 1 collapsed, got %d dates, %d in history, %d collapsed",
+			deduped.CommitCount, len(deduped.CommitHistory), deduped.CollapsedCommits)
+	}
+	for _, date := range deduped.CommitDates {
+		if DayKey(date) == DayKey(now.AddDate(0, 0, -1)) {
+			t.Errorf("Expected the backport's date to be dropped in favor of the original, got %v", deduped.CommitDates)
+		}
+	}
+
+	// Copies arriving in a later refresh are collapsed too
+	git(now, "checkout", "-q", "feature")
+	createTestCommit(t, repoPath, now.AddDate(0, 0, -3), "more feature work")
+	git(now, "checkout", "-q", "-")
+	backport(now)
+	refreshed := UpdateRepoMeta(context.Background(), deduped, "Test User")
+	if refreshed.CommitCount != 4 || refreshed.CollapsedCommits != 2 || len(refreshed.CommitHistory) != 4 {
+		t.Errorf("Expected 4 commits with 2 collapsed after refresh, got %d dates, %d in history, %d collapsed",
+			refreshed.CommitCount, len(refreshed.CommitHistory), refreshed.CollapsedCommits)
+	}
+}
+
+func TestScanReport(t *testing.T) {
+	root := t.TempDir()
+	initRepo := func(name, user string) string {
+		repoPath := filepath.Join(root, name)
+		for _, cmd := range [][]string{
+			{"git", "init", "-q", repoPath},
+			{"git", "-C", repoPath, "config", "user.name", user},
+			{"git", "-C", repoPath, "config", "user.email", "test@example.com"},
+		} {
+			if output, err := exec.Command(cmd[0], cmd[1:]...).CombinedOutput(); err != nil {
+				t.Fatalf("Failed to run %v: %v\n%s", cmd, err, output)
+			}
+		}
+		createTestCommit(t, repoPath, time.Now().UTC(), "commit in "+name)
+		return repoPath
+	}
+	initRepo("alpha", "Test User")
+	initRepo("excluded", "Test User")
+	initRepo("others", "Someone Else")
+	broken := initRepo("broken", "Test User")
+	// Refs still point at commits, but the objects are gone
+	if err := os.RemoveAll(filepath.Join(broken, ".git", "objects")); err != nil {
+		t.Fatalf("Failed to remove objects: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(broken, ".git", "objects"), 0755); err != nil {
+		t.Fatalf("Failed to recreate objects dir: %v", err)
+	}
+
+	originalThreshold := config.AppConfig.DormantThreshold
+	defer func() { config.AppConfig.DormantThreshold = originalThreshold }()
+	config.AppConfig.DormantThreshold = 30
+
+	missing := filepath.Join(root, "missing")
+	exclusions := NewExclusions([]string{root, missing}, []string{"excluded"}, nil)
+	repos, report, err := ScanDirectories(context.Background(), []string{root, missing}, "Test User", exclusions, nil, nil)
+	if err != nil {
+		t.Fatalf("ScanDirectories failed: %v", err)
+	}
+	if len(repos) != 1 || filepath.Base(repos[0].Path) != "alpha" {
+		t.Errorf("Expected only alpha to be returned, got %d repos", len(repos))
+	}
+
+	statuses := make(map[string]RepoStatus)
+	for _, repo := range report.Repos {
+		statuses[filepath.Base(repo.Path)] = repo.Status
+		if repo.Status != StatusScanned && repo.Reason == "" {
+			t.Errorf("Expected a reason for %s (%s)", repo.Path, repo.Status)
+		}
+	}
+	expected := map[string]RepoStatus{
+		"alpha":    StatusScanned,
+		"broken":   StatusFailed,
+		"excluded": StatusExcluded,
+		"others":   StatusSkipped,
+		"missing":  StatusFailed,
+	}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("Expected outcomes %v, got %v", expected, statuses)
+	}
+	if report.Count(StatusFailed) != 2 || len(report.Failed()) != 2 {
+		t.Errorf("Expected 2 failures, got %d", report.Count(StatusFailed))
+	}
+
+	// Nothing readable at all is an error
+	if _, _, err := ScanDirectories(context.Background(), []string{missing}, "Test User", nil, nil, nil); err == nil {
+		t.Error("Expected an error when no scan directory can be read")
+	}
+}
+
+func TestScanInterrupted(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"alpha", "bravo", "charlie"} {
+		repoPath := filepath.Join(root, name)
+		for _, cmd := range [][]string{
+			{"git", "init", "-q", repoPath},
+			{"git", "-C", repoPath, "config", "user.name", "Test User"},
+			{"git", "-C", repoPath, "config", "user.email", "test@example.com"},
+		} {
+			if output, err := exec.Command(cmd[0], cmd[1:]...).CombinedOutput(); err != nil {
+				t.Fatalf("Failed to run %v: %v\n%s", cmd, err, output)
+			}
+		}
+		createTestCommit(t, repoPath, time.Now().UTC(), "commit in "+name)
+	}
+
+	originalConcurrency := config.AppConfig.ScanSettings.Concurrency
+	originalThreshold := config.AppConfig.DormantThreshold
+	defer func() {
+		config.AppConfig.ScanSettings.Concurrency = originalConcurrency
+		config.AppConfig.DormantThreshold = originalThreshold
+	}()
+	config.AppConfig.ScanSettings.Concurrency = 1
+	config.AppConfig.DormantThreshold = 30
+
+	// Cancel as soon as the first repository has been read
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repos, report, err := ScanDirectories(ctx, []string{root}, "Test User", nil, nil, func(p ScanProgress) {
+		if p.Scanned == 1 {
+			cancel()
+		}
+	})
+	if err == nil {
+		t.Error("Expected an error from an interrupted scan")
+	}
+	if len(repos) != 1 || filepath.Base(repos[0].Path) != "alpha" {
+		t.Fatalf("Expected the completed repository alpha to be returned, got %d repos", len(repos))
+	}
+	if report.Count(StatusScanned) != 1 || report.Count(StatusInterrupted) != 2 {
+		t.Errorf("Expected 1 scanned and 2 interrupted repositories, got %+v", report.Repos)
+	}
+}
+
+func TestChangeFilters(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	originalThreshold := config.AppConfig.DormantThreshold
+	originalDetailed := config.AppConfig.DetailedStats
+	originalChanges := config.AppConfig.ChangeSettings
+	defer func() {
+		config.AppConfig.DormantThreshold = originalThreshold
+		config.AppConfig.DetailedStats = originalDetailed
+		config.AppConfig.ChangeSettings = originalChanges
+	}()
+	config.AppConfig.DormantThreshold = 30
+	config.AppConfig.DetailedStats = true
+	config.AppConfig.ChangeSettings = originalChanges
+	config.AppConfig.ChangeSettings.ExcludedFiles = []string{"go.sum", "vendor/"}
+	config.AppConfig.ChangeSettings.WeightedFiles = nil
+	config.AppConfig.ChangeSettings.OutlierFactor = 20
+	config.AppConfig.ChangeSettings.OutlierMinLines = 100
+	config.AppConfig.ChangeSettings.ExcludeOutliers = true
+
+	filter := configuredChangeFilter()
+	for file, want := range map[string]float64{
+		"go.sum":             0,
+		"tools/go.sum":       0,
+		"vendor/lib/lib.go":  0,
+		"a/vendor/lib.go":    0,
+		"main.go":            1,
+		"vendored/lib.go":    1,
+		"docs/go.sum.md":     1,
+		"vendor_notes/x.txt": 1,
+	} {
+		if got := filter.weight(file); got != want {
+			t.Errorf("weight(%q) = %v, want %v", file, got, want)
+		}
+	}
+
+	// commitFiles writes files with the given number of lines and commits them
+	commitFiles := func(date time.Time, message string, files map[string]int) {
+		for name, lines := range files {
+			path := filepath.Join(repoPath, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			content := strings.Repeat(message+"\n", lines)
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, args := range [][]string{{"add", "."}, {"commit", "-q", "-m", message}} {
+			command := exec.Command("git", args...)
+			command.Dir = repoPath
+			command.Env = append(os.Environ(),
+				"GIT_AUTHOR_DATE="+date.Format(time.RFC3339), "GIT_COMMITTER_DATE="+date.Format(time.RFC3339))
+			if output, err := command.CombinedOutput(); err != nil {
+				t.Fatalf("Failed to run git %v: %v\n%s", args, err, output)
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	for i := 6; i > 2; i-- {
+		createTestCommit(t, repoPath, now.AddDate(0, 0, -i), fmt.Sprintf("work %d", i))
+	}
+	commitFiles(now.AddDate(0, 0, -2), "bump deps", map[string]int{
+		"main.go": 2, "go.sum": 500, "vendor/lib/lib.go": 300,
+	})
+	commitFiles(now.AddDate(0, 0, -1), "import", map[string]int{"generated.txt": 400})
+
+	meta := fetchRepoMeta(context.Background(), repoPath, "Test User")
+	if meta.ChangeRules == "" {
+		t.Error("Expected the change rules to be recorded")
+	}
+	byMessage := make(map[string]CommitHistory)
+	for _, commit := range meta.CommitHistory {
+		byMessage[commit.MessageHead] = commit
+	}
+
+	bump := byMessage["bump deps"]
+	if bump.Additions != 2 {
+		t.Errorf("Expected 2 counted additions for the dependency bump, got %d", bump.Additions)
+	}
+	if additions, _ := bump.RawChanges(); additions != 802 {
+		t.Errorf("Expected 802 raw additions for the dependency bump, got %d", additions)
+	}
+	if bump.Outlier {
+		t.Error("Expected the dependency bump not to be an outlier once filtered")
+	}
+
+	imported := byMessage["import"]
+	if !imported.Outlier {
+		t.Errorf("Expected the 400 line import to be flagged as an outlier, got %+v", imported)
+	}
+	if additions, deletions := imported.CountedChanges(); additions != 0 || deletions != 0 {
+		t.Errorf("Expected excluded outliers to count no lines, got +%d/-%d", additions, deletions)
+	}
+	config.AppConfig.ChangeSettings.ExcludeOutliers = false
+	if additions, _ := imported.CountedChanges(); additions != 400 {
+		t.Errorf("Expected flagged outliers to count when not excluded, got %d", additions)
+	}
+
+	// Changing the rules rescans with the new ones
+	config.AppConfig.ChangeSettings.ExcludedFiles = nil
+	rescanned := UpdateRepoMeta(context.Background(), meta, "Test User")
+	for _, commit := range rescanned.CommitHistory {
+		if commit.MessageHead == "bump deps" && commit.Additions != 802 {
+			t.Errorf("Expected 802 additions without rules, got %d", commit.Additions)
+		}
+	}
+}
+
+func TestTreeLineCounts(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	originalSettings := config.AppConfig.LanguageSettings
+	defer func() { config.AppConfig.LanguageSettings = originalSettings }()
+	config.AppConfig.LanguageSettings.ExcludedExtensions = nil
+	config.AppConfig.LanguageSettings.ExcludedLanguages = nil
+	config.AppConfig.LanguageSettings.MinimumLines = 1
+
+	files := map[string]string{
+		// 6 lines: 2 code, 3 comment, 1 blank
+		"main.go": "// Package main\npackage main\n\n/* entry\n   point */\nfunc main() {}\n",
+		// 4 lines: 2 code, 1 comment, 1 blank
+		"run.py":   "# run it\nimport os\n\nos.exit(0)\n",
+		"logo.png": "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(repoPath, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{{"add", "."}, {"commit", "-q", "-m", "add files"}} {
+		command := exec.Command("git", args...)
+		command.Dir = repoPath
+		if output, err := command.CombinedOutput(); err != nil {
+			t.Fatalf("Failed to run git %v: %v\n%s", args, err, output)
+		}
+	}
+
+	// Uncommitted edits and untracked files are not counted
+	if err := os.WriteFile(filepath.Join(repoPath, "main.go"), []byte(strings.Repeat("x\n", 100)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repoPath, "extra.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	memo := NewBlobMemo(nil)
+	ctx := WithBlobMemo(context.Background(), memo)
+	stats, err := fetchLanguageStats(ctx, repoPath)
+	if err != nil {
+		t.Fatalf("fetchLanguageStats failed: %v", err)
+	}
+	expected := map[string]int{"Go": 6, "Python": 4}
+	if !reflect.DeepEqual(stats.languages, expected) {
+		t.Errorf("Expected languages %v, got %v", expected, stats.languages)
+	}
+	if stats.code != 4 || stats.comment != 4 || stats.blank != 2 {
+		t.Errorf("Expected 4 code, 4 comment and 2 blank lines, got %d, %d and %d", stats.code, stats.comment, stats.blank)
+	}
+	if stats.tree == "" {
+		t.Error("Expected the counted tree to be recorded")
+	}
+	records := memo.Records(time.Hour)
+	if len(records) != 3 {
+		t.Errorf("Expected 3 memoized blobs, got %d", len(records))
+	}
+
+	// Memoized blobs are not read again
+	output, err := exec.Command("git", "-C", repoPath, "rev-parse", "HEAD:main.go").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := strings.TrimSpace(string(output))
+	seeded := records[hash]
+	seeded.Stats.Lines = 1000
+	records[hash] = seeded
+	stats, err = fetchLanguageStats(WithBlobMemo(context.Background(), NewBlobMemo(records)), repoPath)
+	if err != nil || stats.languages["Go"] != 1000 {
+		t.Errorf("Expected the memoized count of main.go to be used, got %v, %v", stats.languages, err)
+	}
+
+	// Records no scan needed for longer than maxAge are dropped
+	stale := map[string]BlobRecord{"deadbeef": {Used: time.Now().AddDate(0, 0, -60)}}
+	if kept := NewBlobMemo(stale).Records(24 * time.Hour); len(kept) != 0 {
+		t.Errorf("Expected stale records to be dropped, got %v", kept)
+	}
+
+	// The git fallback lists the same tree
+	tree, native, reader, err := headTreeNative(repoPath)
+	if err != nil {
+		t.Fatalf("Native tree listing failed: %v", err)
+	}
+	reader.Close()
+	gitTree, listed, batch, err := headTreeGit(context.Background(), repoPath)
+	if err != nil {
+		t.Fatalf("Git tree listing failed: %v", err)
+	}
+	content, err := batch.readBlob(hash)
+	batch.Close()
+	if err != nil || string(content) != files["main.go"] {
+		t.Errorf("Expected the committed main.go from the batch reader, got %q, %v", content, err)
+	}
+	if gitTree != tree || !reflect.DeepEqual(listed, native) {
+		t.Errorf("Expected git to list %s %v, got %s %v", tree, native, gitTree, listed)
+	}
+}
+
+func TestCountSourceLines(t *testing.T) {
+	tests := []struct {
+		content        string
+		syntax         string
+		blank, comment int
+	}{
+		{"a := 1 // trailing\n// whole line\n", "c", 0, 1},
+		{"/* one */ /* two */\n/* open\nstill\nclosed */ code()\n", "c", 0, 3},
+		{"-- line\n--[[ block\n]]\nprint(1)", "lua", 0, 3},
+		{"<!-- note -->\n<p>hi</p>\n", "markup", 0, 1},
+		{"# not a comment\n", "", 0, 0},
+		{"\"\"\"Docstring.\n\nMore.\n\"\"\"\nx = 1\n", "python", 1, 3},
+		{"\n\n", "c", 2, 0},
+		{"", "c", 0, 0},
+	}
+	for _, test := range tests {
+		blank, comment := countSourceLines([]byte(test.content), test.syntax)
+		if blank != test.blank || comment != test.comment {
+			t.Errorf("countSourceLines(%q, %q) = %d blank, %d comment; want %d, %d",
+				test.content, test.syntax, blank, comment, test.blank, test.comment)
+		}
+		if lines := countLines([]byte(test.content)); lines != len(sourceLines([]byte(test.content))) {
+			t.Errorf("countLines(%q) = %d, want %d", test.content, lines, len(sourceLines([]byte(test.content))))
+		}
+	}
+}
+
+func TestRepoRelocation(t *testing.T) {
+	for remote, want := range map[string]string{
+		"git@github.com:Me/tool.git":          "github.com/Me/tool",
+		"ssh://git@GitHub.com:22/Me/tool.git": "github.com/Me/tool",
+		"https://github.com/Me/tool/":         "github.com/Me/tool",
+		"/srv/git/tool.git":                   "/srv/git/tool",
+	} {
+		if got := normalizeRemoteURL(remote); got != want {
+			t.Errorf("normalizeRemoteURL(%q) = %q, want %q", remote, got, want)
+		}
+	}
+
+	root := t.TempDir()
+	initRepo := func(name, message string) string {
+		repoPath := filepath.Join(root, name)
+		for _, cmd := range [][]string{
+			{"git", "init", "-q", repoPath},
+			{"git", "-C", repoPath, "config", "user.name", "Test User"},
+			{"git", "-C", repoPath, "config", "user.email", "test@example.com"},
+			{"git", "-C", repoPath, "remote", "add", "upstream", "https://example.com/other/" + name},
+			{"git", "-C", repoPath, "remote", "add", "origin", "git@example.com:me/" + name + ".git"},
+		} {
+			if output, err := exec.Command(cmd[0], cmd[1:]...).CombinedOutput(); err != nil {
+				t.Fatalf("Failed to run %v: %v\n%s", cmd, err, output)
+			}
+		}
+		createTestCommit(t, repoPath, time.Now().UTC().AddDate(0, 0, -1), message)
+		return repoPath
+	}
+	oldPath := initRepo("tool", "first")
+	initRepo("other", "unrelated")
+
+	originalThreshold := config.AppConfig.DormantThreshold
+	defer func() { config.AppConfig.DormantThreshold = originalThreshold }()
+	config.AppConfig.DormantThreshold = 30
+
+	scanAll := func(previous map[string]RepoMetadata) (map[string]RepoMetadata, ScanReport) {
+		repos, report, err := ScanDirectories(context.Background(), []string{root}, "Test User",
+			NewExclusions([]string{root}, nil, nil), previous, nil)
+		if err != nil {
+			t.Fatalf("ScanDirectories failed: %v", err)
+		}
+		byPath := make(map[string]RepoMetadata)
+		for _, repo := range repos {
+			byPath[repo.Path] = repo
+		}
+		return byPath, report
+	}
+	first, _ := scanAll(nil)
+	if remote := first[oldPath].RemoteURL; remote != "example.com/me/tool" {
+		t.Errorf("Expected the origin remote to be recorded, got %q", remote)
+	}
+
+	// Rename the checkout and mark its cached entry, to tell a migrated
+	// entry from a fresh scan
+	newPath := filepath.Join(root, "renamed")
+	if err := os.Rename(oldPath, newPath); err != nil {
+		t.Fatal(err)
+	}
+	cached := first[oldPath]
+	cached.Contributors = map[string]int{"migrated": 1}
+	first[oldPath] = cached
+
+	second, report := scanAll(first)
+	moved, ok := second[newPath]
+	if !ok {
+		t.Fatalf("Expected the renamed repository to be scanned, got %v", second)
+	}
+	if _, stale := second[oldPath]; stale {
+		t.Error("Expected the old path to be dropped")
+	}
+	if moved.Fingerprint() != cached.Fingerprint() || moved.CommitCount != cached.CommitCount {
+		t.Errorf("Expected the moved repository to keep its identity and commits, got %q with %d commits",
+			moved.Fingerprint(), moved.CommitCount)
+	}
+	if moved.Contributors["migrated"] != 1 {
+		t.Error("Expected the cached entry to be refreshed instead of scanning from scratch")
+	}
+	for _, outcome := range report.Repos {
+		want := ""
+		if outcome.Path == newPath {
+			want = oldPath
+		}
+		if outcome.MovedFrom != want {
+			t.Errorf("Expected %s to be moved from %q, got %q", outcome.Path, want, outcome.MovedFrom)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package scan
+
+import "strings"
+
+// commentSyntax describes how a family of languages writes comments
+type commentSyntax struct {
+	line  []string    // markers starting a comment that runs to the end of the line
+	block [][2]string // opening and closing markers of block comments
+}
+
+// commentSyntaxes are the comment syntaxes by name
+var commentSyntaxes = map[string]commentSyntax{
+	"c":          {line: []string{"//"}, block: [][2]string{{"/*", "*/"}}},
+	"css":        {block: [][2]string{{"/*", "*/"}}},
+	"hash":       {line: []string{"#"}},
+	"hash-c":     {line: []string{"#", "//"}, block: [][2]string{{"/*", "*/"}}},
+	"python":     {line: []string{"#"}, block: [][2]string{{`"""`, `"""`}, {"'''", "'''"}}},
+	"julia":      {line: []string{"#"}, block: [][2]string{{"#=", "=#"}}},
+	"nim":        {line: []string{"#"}, block: [][2]string{{"#[", "]#"}}},
+	"nix":        {line: []string{"#"}, block: [][2]string{{"/*", "*/"}}},
+	"powershell": {line: []string{"#"}, block: [][2]string{{"<#", "#>"}}},
+	"ruby":       {line: []string{"#"}, block: [][2]string{{"=begin", "=end"}}},
+	"lua":        {line: []string{"--"}, block: [][2]string{{"--[[", "]]"}}},
+	"haskell":    {line: []string{"--"}, block: [][2]string{{"{-", "-}"}}},
+	"sql":        {line: []string{"--"}, block: [][2]string{{"/*", "*/"}}},
+	"ml":         {block: [][2]string{{"(*", "*)"}}},
+	"fsharp":     {line: []string{"//"}, block: [][2]string{{"(*", "*)"}}},
+	"lisp":       {line: []string{";"}, block: [][2]string{{"#|", "|#"}}},
+	"semicolon":  {line: []string{";", "#"}},
+	"percent":    {line: []string{"%"}},
+	"markup":     {block: [][2]string{{"<!--", "-->"}}},
+	"vim":        {line: []string{`"`}},
+	"basic":      {line: []string{"'"}},
+	"batch":      {line: []string{"REM ", "rem ", "@REM ", "@rem ", "::"}},
+}
+
+// languageComments names the comment syntax of each language. Languages
+// without comments, like JSON, are missing.
+var languageComments = map[string]string{
+	"C": "c", "C++": "c", "C#": "c", "Objective-C": "c", "Objective-C++": "c", "Go": "c",
+	"Java": "c", "JavaScript": "c", "TypeScript": "c", "TSX": "c", "Kotlin": "c", "Scala": "c",
+	"Swift": "c", "Rust": "c", "Dart": "c", "Groovy": "c", "Solidity": "c", "Zig": "c", "V": "c",
+	"D": "c", "JSON with Comments": "c", "JSON5": "c", "Protocol Buffer": "c", "Less": "c",
+	"SCSS": "c", "Sass": "c", "templ": "c",
+	"CSS": "css",
+	"PHP": "hash-c", "HCL": "hash-c",
+	"Python": "python", "Starlark": "python",
+	"Julia": "julia", "Nim": "nim", "Nix": "nix", "PowerShell": "powershell",
+	"Ruby": "ruby", "Crystal": "hash", "Shell": "hash", "Fish": "hash", "Perl": "hash", "R": "hash",
+	"Makefile": "hash", "Dockerfile": "hash", "YAML": "hash", "TOML": "hash", "CMake": "hash",
+	"Elixir": "hash", "Awk": "hash", "Tcl": "hash", "Meson": "hash", "Just": "hash", "Nginx": "hash",
+	"Pip Requirements": "hash", "Procfile": "hash", "GraphQL": "hash",
+	"Lua": "lua", "Haskell": "haskell", "Elm": "haskell", "SQL": "sql",
+	"OCaml": "ml", "F#": "fsharp",
+	"Clojure": "lisp", "Common Lisp": "lisp", "Emacs Lisp": "lisp", "Racket": "lisp", "Scheme": "lisp",
+	"INI": "semicolon", "Assembly": "semicolon",
+	"Erlang": "percent", "TeX": "percent",
+	"HTML": "markup", "HTML+ERB": "markup", "XML": "markup", "XSLT": "markup", "SVG": "markup",
+	"Vue": "markup", "Svelte": "markup", "Markdown": "markup", "MDX": "markup",
+	"Vim Script": "vim", "Visual Basic .NET": "basic", "Batchfile": "batch",
+}
+
+// countSourceLines splits content, line by line as countLines counts them,
+// into blank lines and lines holding only comments in the named syntax. The
+// remaining lines are code.
+func countSourceLines(content []byte, syntax string) (blank, comment int) {
+	comments, known := commentSyntaxes[syntax]
+	closing := "" // end marker of the block comment being read
+	for _, line := range sourceLines(content) {
+		text := strings.TrimSpace(line)
+		if text == "" {
+			blank++
+			continue
+		}
+		if !known {
+			continue
+		}
+
+		onlyComment := true
+		if closing != "" {
+			end := strings.Index(text, closing)
+			if end < 0 {
+				comment++
+				continue
+			}
+			text = strings.TrimSpace(text[end+len(closing):])
+			closing = ""
+		}
+		if text != "" {
+			onlyComment, closing = comments.commentOnly(text)
+		}
+		if onlyComment {
+			comment++
+		}
+	}
+	return blank, comment
+}
+
+// sourceLines splits content into lines. The newline ending the last line
+// does not start another one.
+func sourceLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
+
+// commentOnly reports whether a trimmed line holds nothing but comments,
+// and returns the closing marker when it ends inside a block comment
+func (s commentSyntax) commentOnly(text string) (bool, string) {
+	for text != "" {
+		opened := false
+		// Blocks go first: Lua's "--[[" starts with its line marker
+		for _, block := range s.block {
+			if !strings.HasPrefix(text, block[0]) {
+				continue
+			}
+			rest := text[len(block[0]):]
+			end := strings.Index(rest, block[1])
+			if end < 0 {
+				return true, block[1]
+			}
+			text = strings.TrimSpace(rest[end+len(block[1]):])
+			opened = true
+			break
+		}
+		if opened {
+			continue
+		}
+		for _, marker := range s.line {
+			if strings.HasPrefix(text, marker) {
+				return true, ""
+			}
+		}
+		return false, ""
+	}
+	return true, ""
+}
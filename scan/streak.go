@@ -0,0 +1,191 @@
+package scan
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/AccursedGalaxy/streakode/config"
+)
+
+// StreakInfo holds the current and longest streaks in days
+type StreakInfo struct {
+	Current int
+	Longest int
+
+	FrozenDays []string // days of the current streak covered by a freeze
+	Freezes    int      // freezes saved up for future missed days
+}
+
+// Activity is a commit as seen by the streak rules
+type Activity struct {
+	Date  time.Time
+	Lines int // changed lines, negative when unknown
+}
+
+// StreakPolicy decides which days extend, pause or break a streak
+type StreakPolicy struct {
+	WeekendsOff bool
+	RestDays    map[string]bool
+	FreezeEvery int
+	MaxFreezes  int
+	MinCommits  int
+	MinLines    int
+}
+
+// ConfiguredStreakPolicy returns the streak rules from the configuration
+func ConfiguredStreakPolicy() StreakPolicy {
+	settings := config.AppConfig.StreakSettings
+	policy := StreakPolicy{
+		WeekendsOff: settings.WeekendsOff,
+		RestDays:    make(map[string]bool),
+		FreezeEvery: settings.FreezeEvery,
+		MaxFreezes:  settings.MaxFreezes,
+		MinCommits:  settings.MinCommits,
+		MinLines:    settings.MinLines,
+	}
+	for _, value := range settings.RestDays {
+		start, end, err := config.ParseDayRange(value)
+		if err != nil {
+			continue
+		}
+		for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+			policy.RestDays[day.Format(dayKeyLayout)] = true
+		}
+	}
+	return policy
+}
+
+// NeedsLines reports whether days are judged by changed lines
+func (p StreakPolicy) NeedsLines() bool {
+	return p.MinLines > 0
+}
+
+// AllowsGaps reports whether a streak can survive a day without commits
+func (p StreakPolicy) AllowsGaps() bool {
+	return p.WeekendsOff || len(p.RestDays) > 0 || p.FreezeEvery > 0
+}
+
+// isRestDay reports whether day neither extends nor breaks a streak
+func (p StreakPolicy) isRestDay(day time.Time) bool {
+	if p.WeekendsOff && (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday) {
+		return true
+	}
+	return p.RestDays[day.Format(dayKeyLayout)]
+}
+
+// dayActivity is the work done on a single day
+type dayActivity struct {
+	commits int
+	lines   int
+	known   bool // false when a commit's line count is unknown
+}
+
+// counts reports whether a day's activity meets the minimum thresholds.
+// Line thresholds are skipped when line counts are unknown.
+func (p StreakPolicy) counts(a dayActivity) bool {
+	if a.commits == 0 || a.commits < p.MinCommits {
+		return false
+	}
+	return !p.NeedsLines() || !a.known || a.lines >= p.MinLines
+}
+
+// Calculate applies the policy to a list of commits. Days are walked from the
+// first active day to today: active days extend the streak and earn freezes,
+// rest days are skipped, and a missed day either uses a freeze or ends the
+// streak. Today never breaks a streak since the day is not over yet.
+func (p StreakPolicy) Calculate(activity []Activity) StreakInfo {
+	if len(activity) == 0 {
+		return StreakInfo{}
+	}
+
+	days := make(map[string]dayActivity)
+	var first time.Time
+	for _, a := range activity {
+		key := DayKey(a.Date)
+		day := days[key]
+		day.commits++
+		if day.commits == 1 {
+			day.known = true
+		}
+		if a.Lines < 0 {
+			day.known = false
+		} else {
+			day.lines += a.Lines
+		}
+		days[key] = day
+
+		if date, err := parseDayKey(key); err == nil && (first.IsZero() || date.Before(first)) {
+			first = date
+		}
+	}
+
+	if config.AppConfig.Debug {
+		fmt.Printf("Debug: Processing %d commits across %d days\n", len(activity), len(days))
+	}
+
+	today, err := parseDayKey(Today())
+	if err != nil || first.IsZero() {
+		return StreakInfo{}
+	}
+
+	var info StreakInfo
+	run, activeDays := 0, 0
+	for day := first; !day.After(today); day = day.AddDate(0, 0, 1) {
+		key := day.Format(dayKeyLayout)
+		switch {
+		case p.counts(days[key]):
+			run++
+			activeDays++
+			if p.FreezeEvery > 0 && activeDays%p.FreezeEvery == 0 && info.Freezes < p.MaxFreezes {
+				info.Freezes++
+			}
+		case p.isRestDay(day) || day.Equal(today):
+			// Neither extends nor breaks the streak
+		case run > 0 && info.Freezes > 0:
+			info.Freezes--
+			info.FrozenDays = append(info.FrozenDays, key)
+			if config.AppConfig.Debug {
+				fmt.Printf("Debug: Freeze used on %s\n", key)
+			}
+		default:
+			if run > 0 && config.AppConfig.Debug {
+				fmt.Printf("Debug: Streak break found after %d days\n", run)
+			}
+			run = 0
+			info.FrozenDays = nil
+		}
+		if run > info.Longest {
+			info.Longest = run
+		}
+	}
+	info.Current = run
+	if run == 0 {
+		info.FrozenDays = nil
+	}
+
+	if config.AppConfig.Debug {
+		fmt.Printf("Debug: Final streaks - Current: %d, Longest: %d, Freezes left: %d\n",
+			info.Current, info.Longest, info.Freezes)
+	}
+
+	return info
+}
+
+// CalculateStreaks applies the configured streak rules to commit dates whose
+// line counts are unknown
+func CalculateStreaks(dates []time.Time) StreakInfo {
+	return ConfiguredStreakPolicy().Calculate(activityOf(dates, nil))
+}
+
+// activityOf pairs commit dates with their changed lines. Lines may be nil
+// or shorter than dates when counts are unknown.
+func activityOf(dates []time.Time, lines []int) []Activity {
+	activity := make([]Activity, len(dates))
+	for i, date := range dates {
+		activity[i] = Activity{Date: date, Lines: -1}
+		if i < len(lines) {
+			activity[i].Lines = lines[i]
+		}
+	}
+	return activity
+}
@@ -0,0 +1,44 @@
+package scan
+
+import (
+	"path"
+	"strings"
+)
+
+// matchGlob matches a slash separated path against a git style glob. "*", "?"
+// and "[...]" stay within one path segment, while a "**" segment matches any
+// number of directories.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				// A trailing "**" matches everything inside, not the directory itself
+				return len(name) > 0
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 || !matchSegment(pattern[0], name[0]) {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchSegment matches a single path segment. Git writes negated character
+// classes as "[!...]", path.Match expects "[^...]".
+func matchSegment(pattern, name string) bool {
+	pattern = strings.ReplaceAll(pattern, "[!", "[^")
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}